@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/shravan20/qafka/pkg/client"
+)
+
+// queueFile is a YAML document declaring queues:
+//
+//	manager: payments
+//	queues:
+//	  - name: orders
+//	    type: fifo
+//	    dead_letter_queue: orders-dlq
+//	    retry_policy: {strategy: exponential, delay_seconds: 5, max_retries: 5}
+//	    config: {lease_seconds: 60}
+type queueFile struct {
+	Manager string             `yaml:"manager"`
+	Queues  []client.QueueSpec `yaml:"queues"`
+}
+
+// runApply implements the plan and apply commands:
+//
+//	kqctl plan -f PATH... [--manager NAME] [--prune] [--adopt]
+//	kqctl apply -f PATH... [--manager NAME] [--prune] [--adopt] [--delete-mode MODE]
+//
+// PATH is a YAML file, a directory of them, or - for stdin. plan only prints the
+// changes apply would make.
+func runApply(ctx context.Context, args []string, dryRun bool) error {
+	name := "apply"
+	if dryRun {
+		name = "plan"
+	}
+
+	fs, g := newFlagSet(name)
+	var (
+		manager    = fs.String("manager", "", "manager owning the declared queues, overriding the files")
+		prune      = fs.Bool("prune", false, "delete queues of the manager that are not declared")
+		adopt      = fs.Bool("adopt", false, "take over existing unmanaged queues that are declared")
+		deleteMode = fs.String("delete-mode", "", "how pruned queues are deleted: refuse, cascade or archive")
+		paths      stringList
+	)
+	fs.Var(&paths, "f", "YAML file or directory of queue declarations, - for stdin (repeatable)")
+	fs.Var(&paths, "file", "YAML file or directory of queue declarations, - for stdin (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := wantArgs(pos); err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("-f is required")
+	}
+
+	req, err := loadQueueFiles(paths)
+	if err != nil {
+		return err
+	}
+	if *manager != "" {
+		req.Manager = *manager
+	}
+	req.Prune = *prune
+	req.Adopt = *adopt
+	req.DeleteMode = *deleteMode
+
+	c, p, err := g.setup()
+	if err != nil {
+		return err
+	}
+
+	plan, err := c.ApplyQueues(ctx, req, dryRun)
+	if err != nil {
+		return err
+	}
+	return printPlan(p, plan)
+}
+
+// loadQueueFiles reads the declarations in paths into one request. Every file must
+// name the same manager, or none.
+func loadQueueFiles(paths []string) (*client.ApplyQueuesRequest, error) {
+	var files []string
+	for _, path := range paths {
+		if path == "-" {
+			files = append(files, path)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	req := &client.ApplyQueuesRequest{Queues: []client.QueueSpec{}}
+	for _, file := range files {
+		docs, err := readQueueFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if doc.Manager != "" && req.Manager != "" && doc.Manager != req.Manager {
+				return nil, fmt.Errorf("%s: manager %q differs from %q declared before", file, doc.Manager, req.Manager)
+			}
+			if doc.Manager != "" {
+				req.Manager = doc.Manager
+			}
+			req.Queues = append(req.Queues, doc.Queues...)
+		}
+	}
+	return req, nil
+}
+
+// readQueueFile decodes every YAML document of a file, rejecting unknown fields
+func readQueueFile(path string) ([]queueFile, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	dec := yaml.NewDecoder(in)
+	dec.KnownFields(true)
+
+	var docs []queueFile
+	for {
+		var doc queueFile
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func printPlan(p *printer, plan *client.ApplyPlan) error {
+	if p.format != outputTable {
+		return p.encode(plan)
+	}
+
+	counts := make(map[string]int)
+	for _, change := range plan.Changes {
+		counts[change.Action]++
+	}
+
+	verb := "Applied"
+	if plan.DryRun {
+		verb = "Plan"
+	}
+	fmt.Fprintf(p.w, "%s for manager %q: %d to create, %d to update, %d to delete, %d unchanged\n",
+		verb, plan.Manager, counts[client.ApplyCreate], counts[client.ApplyUpdate], counts[client.ApplyDelete], counts[client.ApplyUnchanged])
+
+	symbols := map[string]string{
+		client.ApplyCreate: "+",
+		client.ApplyUpdate: "~",
+		client.ApplyDelete: "-",
+	}
+	for _, change := range plan.Changes {
+		symbol, ok := symbols[change.Action]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(p.w, "\n%s %s %s\n", symbol, change.Action, change.Name)
+		fields := change.Fields
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+		for _, f := range fields {
+			if change.Action == client.ApplyCreate {
+				fmt.Fprintf(p.w, "    %s: %s\n", f.Field, f.To)
+			} else {
+				fmt.Fprintf(p.w, "    %s: %s -> %s\n", f.Field, orDash(f.From), f.To)
+			}
+		}
+	}
+
+	if plan.DryRun && len(plan.Changes) > counts[client.ApplyUnchanged] {
+		fmt.Fprintln(p.w, "\nRun kqctl apply with the same arguments to make these changes.")
+	}
+	return nil
+}
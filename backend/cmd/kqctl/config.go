@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultServer is used when no context names a server
+const defaultServer = "http://localhost:8080"
+
+// config lists the servers kqctl knows, by context name
+type config struct {
+	CurrentContext string       `yaml:"current-context,omitempty" json:"current-context,omitempty"`
+	Contexts       []*kqContext `yaml:"contexts" json:"contexts"`
+	path           string
+}
+
+// kqContext is a named server with the headers sent to it
+type kqContext struct {
+	Name    string            `yaml:"name" json:"name"`
+	Server  string            `yaml:"server" json:"server"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("KQCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".kqctl", "config"), nil
+}
+
+// loadConfig reads the config file, returning an empty config if there is none
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *config) save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// Headers may carry credentials
+	if err := os.WriteFile(cfg.path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+func (cfg *config) find(name string) *kqContext {
+	for _, kctx := range cfg.Contexts {
+		if kctx.Name == name {
+			return kctx
+		}
+	}
+	return nil
+}
+
+// runConfig implements the config command:
+//
+//	kqctl config get-contexts                          list contexts
+//	kqctl config current-context                       print the current context
+//	kqctl config use-context NAME                      make NAME the current context
+//	kqctl config set-context NAME --server URL         add or change a context
+//	kqctl config delete-context NAME                   remove a context
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kqctl config get-contexts | current-context | use-context | set-context | delete-context")
+	}
+
+	// --server of the flags every command accepts sets the server of set-context
+	fs, g := newFlagSet("config " + args[0])
+	var headers stringList
+	fs.Var(&headers, "header", "header sent with every request, as KEY=VALUE (repeatable)")
+	pos, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(g.output)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "get-contexts":
+		if err := wantArgs(pos); err != nil {
+			return err
+		}
+		return printList(p, cfg.Contexts, []column[*kqContext]{
+			{"CURRENT", func(c *kqContext) string {
+				if c.Name == cfg.CurrentContext {
+					return "*"
+				}
+				return ""
+			}},
+			{"NAME", func(c *kqContext) string { return c.Name }},
+			{"SERVER", func(c *kqContext) string { return c.Server }},
+		})
+
+	case "current-context":
+		if err := wantArgs(pos); err != nil {
+			return err
+		}
+		if cfg.CurrentContext == "" {
+			return errors.New("no current context")
+		}
+		fmt.Println(cfg.CurrentContext)
+		return nil
+
+	case "use-context":
+		if err := wantArgs(pos, "NAME"); err != nil {
+			return err
+		}
+		if cfg.find(pos[0]) == nil {
+			return fmt.Errorf("context %q not found", pos[0])
+		}
+		cfg.CurrentContext = pos[0]
+		if err := cfg.save(); err != nil {
+			return err
+		}
+		fmt.Printf("switched to context %q\n", pos[0])
+		return nil
+
+	case "set-context":
+		if err := wantArgs(pos, "NAME"); err != nil {
+			return err
+		}
+		kctx := cfg.find(pos[0])
+		if kctx == nil {
+			if g.server == "" {
+				return errors.New("--server is required for a new context")
+			}
+			kctx = &kqContext{Name: pos[0]}
+			cfg.Contexts = append(cfg.Contexts, kctx)
+		}
+		if g.server != "" {
+			kctx.Server = g.server
+		}
+		hdrs, err := keyValues(headers)
+		if err != nil {
+			return err
+		}
+		if hdrs != nil {
+			kctx.Headers = hdrs
+		}
+		if cfg.CurrentContext == "" {
+			cfg.CurrentContext = kctx.Name
+		}
+		if err := cfg.save(); err != nil {
+			return err
+		}
+		fmt.Printf("context %q set\n", kctx.Name)
+		return nil
+
+	case "delete-context":
+		if err := wantArgs(pos, "NAME"); err != nil {
+			return err
+		}
+		for i, kctx := range cfg.Contexts {
+			if kctx.Name != pos[0] {
+				continue
+			}
+			cfg.Contexts = append(cfg.Contexts[:i], cfg.Contexts[i+1:]...)
+			if cfg.CurrentContext == pos[0] {
+				cfg.CurrentContext = ""
+			}
+			if err := cfg.save(); err != nil {
+				return err
+			}
+			fmt.Printf("context %q deleted\n", pos[0])
+			return nil
+		}
+		return fmt.Errorf("context %q not found", pos[0])
+
+	default:
+		return fmt.Errorf("unknown config command %q", args[0])
+	}
+}
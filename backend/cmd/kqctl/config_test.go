@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // contents of the config file, none when empty
+		want    *config
+		wantErr bool
+	}{
+		{name: "no file", want: &config{}},
+		{
+			name: "contexts",
+			file: `current-context: prod
+contexts:
+  - name: local
+    server: http://localhost:8080
+  - name: prod
+    server: https://qafka.example.com
+    headers:
+      Authorization: Bearer secret
+`,
+			want: &config{
+				CurrentContext: "prod",
+				Contexts: []*kqContext{
+					{Name: "local", Server: "http://localhost:8080"},
+					{Name: "prod", Server: "https://qafka.example.com", Headers: map[string]string{"Authorization": "Bearer secret"}},
+				},
+			},
+		},
+		{name: "invalid yaml", file: "contexts: [", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			t.Setenv("KQCTL_CONFIG", path)
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := loadConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("loadConfig() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+
+			tt.want.path = path
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("loadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config")
+	t.Setenv("KQCTL_CONFIG", path)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	cfg.CurrentContext = "local"
+	cfg.Contexts = []*kqContext{{Name: "local", Server: "http://localhost:8080", Headers: map[string]string{"X-Token": "t"}}}
+	if err := cfg.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("config written with mode %o, want 600", perm)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Fatalf("loadConfig() after save = %+v, want %+v", loaded, cfg)
+	}
+	if loaded.find("local") == nil || loaded.find("prod") != nil {
+		t.Fatal("find() does not match the saved contexts")
+	}
+}
@@ -0,0 +1,230 @@
+// Command kqctl manages the queues, messages and workers of a Qafka server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/shravan20/qafka/pkg/client"
+)
+
+const usage = `kqctl manages the queues, messages and workers of a Qafka server.
+
+Usage:
+  kqctl <command> [arguments] [flags]
+
+Queues (QUEUE is a queue ID or name):
+  queues list [--deleted]
+  queues create NAME --type fifo|lifo|priority|delay [--config JSON|@FILE] [--description TEXT]
+  queues describe QUEUE
+  queues delete QUEUE [--mode refuse|cascade|archive]
+  queues purge QUEUE [--status STATUS]... [--wait]
+  queues redrive QUEUE [--id ID]... [--error-contains TEXT]
+
+Declared queues (FILE is a YAML file, a directory of them, or - for stdin):
+  plan -f FILE... [--manager NAME] [--prune] [--adopt]
+  apply -f FILE... [--manager NAME] [--prune] [--adopt] [--delete-mode refuse|cascade|archive]
+
+Messages:
+  produce QUEUE [--file FILE] [--lines] [--priority N] [--header KEY=VALUE]...
+                [--delay DURATION] [--dedup-id ID] [--group KEY]
+  consume QUEUE [--count N] [--selector SELECTOR] [--wait DURATION]
+  tail QUEUE [--selector SELECTOR] [--interval DURATION]
+
+Workers:
+  workers list [--queue QUEUE]
+  workers describe ID
+
+Contexts:
+  config get-contexts
+  config current-context
+  config use-context NAME
+  config set-context NAME --server URL [--header KEY=VALUE]...
+  config delete-context NAME
+
+Flags of every command:
+  -o, --output table|json|yaml   output format (default table)
+  --context NAME                 context to use instead of the current one
+  --server URL                   server to talk to, overriding the context
+
+Contexts are stored in ~/.kqctl/config, or in the file named by KQCTL_CONFIG.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "kqctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errors.New("missing command")
+	}
+
+	switch args[0] {
+	case "queues", "queue":
+		return runQueues(ctx, args[1:])
+	case "plan":
+		return runApply(ctx, args[1:], true)
+	case "apply":
+		return runApply(ctx, args[1:], false)
+	case "produce":
+		return runProduce(ctx, args[1:])
+	case "consume":
+		return runConsume(ctx, args[1:])
+	case "tail":
+		return runTail(ctx, args[1:])
+	case "workers", "worker":
+		return runWorkers(ctx, args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, see kqctl help", args[0])
+	}
+}
+
+// globals holds the flags every command accepts
+type globals struct {
+	output  string
+	context string
+	server  string
+}
+
+// newFlagSet returns the flag set of a command with the flags every command accepts
+func newFlagSet(name string) (*flag.FlagSet, *globals) {
+	fs := flag.NewFlagSet("kqctl "+name, flag.ContinueOnError)
+	g := &globals{}
+	fs.StringVar(&g.output, "o", "table", "output format: table, json or yaml")
+	fs.StringVar(&g.output, "output", "table", "output format: table, json or yaml")
+	fs.StringVar(&g.context, "context", "", "context to use instead of the current one")
+	fs.StringVar(&g.server, "server", "", "server to talk to, overriding the context")
+	return fs, g
+}
+
+// setup returns the client and printer the flags ask for
+func (g *globals) setup() (*client.Client, *printer, error) {
+	p, err := newPrinter(g.output)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := g.context
+	if name == "" {
+		name = cfg.CurrentContext
+	}
+
+	server := defaultServer
+	var opts []client.Option
+	if kctx := cfg.find(name); kctx != nil {
+		server = kctx.Server
+		for key, value := range kctx.Headers {
+			opts = append(opts, client.WithHeader(key, value))
+		}
+	} else if g.context != "" {
+		return nil, nil, fmt.Errorf("context %q not found", g.context)
+	}
+	if g.server != "" {
+		server = g.server
+	}
+
+	return client.New(server, opts...), p, nil
+}
+
+// parseArgs parses args with fs, allowing flags after positional arguments, and
+// returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Everything after a "--" is positional
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// wantArgs checks that a command got exactly the positional arguments named in names
+func wantArgs(args []string, names ...string) error {
+	if len(args) == len(names) {
+		return nil
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	return fmt.Errorf("expected %s", strings.Join(names, " "))
+}
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// keyValues parses KEY=VALUE flags into a map
+func keyValues(list stringList) (map[string]string, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]string, len(list))
+	for _, kv := range list {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %q, expected KEY=VALUE", kv)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// resolveQueue finds a queue by ID or name
+func resolveQueue(ctx context.Context, c *client.Client, ref string) (*client.Queue, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return c.GetQueue(ctx, id)
+	}
+
+	queues, err := c.ListQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, queue := range queues {
+		if queue.Name == ref {
+			return queue, nil
+		}
+	}
+	return nil, fmt.Errorf("queue %q not found", ref)
+}
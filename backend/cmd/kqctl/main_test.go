@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		want       []string
+		wantOutput string
+		wantServer string
+		wantErr    bool
+	}{
+		{name: "no arguments", args: nil, wantOutput: "table"},
+		{name: "positional only", args: []string{"orders", "5"}, want: []string{"orders", "5"}, wantOutput: "table"},
+		{name: "flags first", args: []string{"-o", "json", "orders"}, want: []string{"orders"}, wantOutput: "json"},
+		{name: "flags after positional", args: []string{"orders", "--output", "yaml"}, want: []string{"orders"}, wantOutput: "yaml"},
+		{
+			name:       "flags between positional",
+			args:       []string{"orders", "--server=http://q:8080", "5", "-o", "json"},
+			want:       []string{"orders", "5"},
+			wantOutput: "json",
+			wantServer: "http://q:8080",
+		},
+		{name: "double dash ends flags", args: []string{"orders", "--", "-o", "json"}, want: []string{"orders", "-o", "json"}, wantOutput: "table"},
+		{name: "unknown flag", args: []string{"orders", "--colour"}, wantErr: true},
+		{name: "missing flag value", args: []string{"orders", "-o"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, g := newFlagSet("test")
+			fs.SetOutput(io.Discard)
+
+			got, err := parseArgs(fs, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseArgs(%q) = %q, want error", tt.args, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs(%q) error = %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+			if g.output != tt.wantOutput || g.server != tt.wantServer {
+				t.Fatalf("parseArgs(%q) set output %q and server %q, want %q and %q", tt.args, g.output, g.server, tt.wantOutput, tt.wantServer)
+			}
+		})
+	}
+}
+
+func TestWantArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		names   []string
+		wantErr bool
+	}{
+		{name: "none wanted", args: nil},
+		{name: "unexpected", args: []string{"x"}, wantErr: true},
+		{name: "exact", args: []string{"orders"}, names: []string{"QUEUE"}},
+		{name: "missing", args: nil, names: []string{"QUEUE"}, wantErr: true},
+		{name: "too many", args: []string{"a", "b"}, names: []string{"QUEUE"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := wantArgs(tt.args, tt.names...); (err != nil) != tt.wantErr {
+				t.Fatalf("wantArgs(%q, %q) error = %v, want error %v", tt.args, tt.names, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    stringList
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", list: nil, want: nil},
+		{name: "pairs", list: stringList{"a=1", "b=x=y"}, want: map[string]string{"a": "1", "b": "x=y"}},
+		{name: "empty value", list: stringList{"a="}, want: map[string]string{"a": ""}},
+		{name: "later wins", list: stringList{"a=1", "a=2"}, want: map[string]string{"a": "2"}},
+		{name: "missing equals", list: stringList{"a"}, wantErr: true},
+		{name: "missing key", list: stringList{"=1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyValues(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keyValues(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("keyValues(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
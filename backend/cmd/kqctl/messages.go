@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shravan20/qafka/pkg/client"
+)
+
+// produceBatchSize is the most messages kqctl produces in one request, the server's batch limit
+const produceBatchSize = 100
+
+var messageColumns = []column[*client.Message]{
+	{"ID", func(m *client.Message) string { return strconv.FormatInt(m.ID, 10) }},
+	{"STATUS", func(m *client.Message) string { return m.Status }},
+	{"PRIORITY", func(m *client.Message) string { return strconv.Itoa(m.Priority) }},
+	{"SCHEDULED", func(m *client.Message) string { return formatTime(m.ScheduledAt) }},
+	{"CREATED", func(m *client.Message) string { return formatTime(&m.CreatedAt) }},
+}
+
+// runProduce implements the produce command. The payload is read from --file, or
+// from stdin; with --lines every non-empty line is produced as its own message.
+func runProduce(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("produce")
+	var (
+		file     = fs.String("file", "", "read the payload from this file instead of stdin")
+		lines    = fs.Bool("lines", false, "produce every non-empty line as a message")
+		priority = fs.Int("priority", 0, "message priority")
+		delay    = fs.Duration("delay", 0, "deliver the messages this long after producing them")
+		dedupID  = fs.String("dedup-id", "", "deduplication key of the message")
+		groupKey = fs.String("group", "", "group key; messages of a group are delivered in order")
+		headers  stringList
+	)
+	fs.Var(&headers, "header", "message header as KEY=VALUE (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := wantArgs(pos, "QUEUE"); err != nil {
+		return err
+	}
+	if *lines && *dedupID != "" {
+		return errors.New("--dedup-id cannot be used with --lines")
+	}
+
+	hdrs, err := keyValues(headers)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "" && *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", *file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var payloads []string
+	if *lines {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				payloads = append(payloads, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read payloads: %w", err)
+		}
+	} else {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return fmt.Errorf("failed to read payload: %w", err)
+		}
+		payloads = []string{strings.TrimSuffix(string(data), "\n")}
+	}
+	if len(payloads) == 0 || payloads[0] == "" {
+		return errors.New("empty payload")
+	}
+
+	c, p, err := g.setup()
+	if err != nil {
+		return err
+	}
+	queue, err := resolveQueue(ctx, c, pos[0])
+	if err != nil {
+		return err
+	}
+
+	var delaySeconds *int
+	if *delay > 0 {
+		seconds := int(delay.Seconds())
+		delaySeconds = &seconds
+	}
+	newRequest := func(payload string) client.CreateMessageRequest {
+		return client.CreateMessageRequest{
+			QueueID:      queue.ID,
+			Payload:      payload,
+			Priority:     *priority,
+			DelaySeconds: delaySeconds,
+			Headers:      hdrs,
+			DedupID:      *dedupID,
+			GroupKey:     *groupKey,
+		}
+	}
+
+	if !*lines {
+		req := newRequest(payloads[0])
+		message, err := c.Produce(ctx, &req)
+		if err != nil {
+			return err
+		}
+		return printList(p, []*client.Message{message}, messageColumns)
+	}
+
+	var produced []*client.Message
+	failed := 0
+	for start := 0; start < len(payloads); start += produceBatchSize {
+		end := min(start+produceBatchSize, len(payloads))
+		reqs := make([]client.CreateMessageRequest, 0, end-start)
+		for _, payload := range payloads[start:end] {
+			reqs = append(reqs, newRequest(payload))
+		}
+
+		results, err := c.ProduceBatch(ctx, queue.ID, reqs)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Error != "" {
+				fmt.Fprintf(os.Stderr, "line %d: %s\n", start+result.Index+1, result.Error)
+				failed++
+				continue
+			}
+			produced = append(produced, result.Message)
+		}
+	}
+
+	if err := printList(p, produced, messageColumns); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d messages failed", failed, len(payloads))
+	}
+	return nil
+}
+
+// runConsume implements the consume command. It registers a worker, prints and
+// acks messages until --count messages were consumed or it is interrupted, and
+// deregisters the worker.
+func runConsume(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("consume")
+	var (
+		count    = fs.Int("count", 1, "stop after this many messages, 0 to consume until interrupted")
+		selector = fs.String("selector", "", "only consume messages whose headers match, e.g. type=invoice")
+		wait     = fs.Duration("wait", 20*time.Second, "how long each poll waits for a message")
+	)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := wantArgs(pos, "QUEUE"); err != nil {
+		return err
+	}
+
+	c, p, err := g.setup()
+	if err != nil {
+		return err
+	}
+	queue, err := resolveQueue(ctx, c, pos[0])
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	name := "kqctl"
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+
+	// One message at a time, so the consumer stops right after the last one
+	out := newMessageStream(p)
+	consumed := 0
+	consumer := client.NewConsumer(c, client.ConsumerConfig{
+		QueueID:  queue.ID,
+		Name:     name,
+		Selector: *selector,
+		PollWait: *wait,
+	}, func(_ context.Context, msg *client.Message) error {
+		if err := out.print(msg); err != nil {
+			return err
+		}
+		consumed++
+		if *count > 0 && consumed >= *count {
+			stop()
+		}
+		return nil
+	})
+	return consumer.Run(ctx)
+}
+
+// runTail implements the tail command. It prints the latest messages of a queue,
+// then every new message until interrupted, without claiming any.
+func runTail(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("tail")
+	var (
+		lines    = fs.Int("lines", 10, "number of existing messages to print first")
+		selector = fs.String("selector", "", "only print messages whose headers match")
+		interval = fs.Duration("interval", time.Second, "how often to check for new messages")
+	)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := wantArgs(pos, "QUEUE"); err != nil {
+		return err
+	}
+
+	c, p, err := g.setup()
+	if err != nil {
+		return err
+	}
+	queue, err := resolveQueue(ctx, c, pos[0])
+	if err != nil {
+		return err
+	}
+
+	out := newMessageStream(p)
+	var lastID int64
+	first := true
+	for {
+		// Messages are listed newest first
+		messages, err := c.ListMessages(ctx, queue.ID, produceBatchSize, *selector)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+		if first {
+			messages = messages[max(0, len(messages)-*lines):]
+			first = false
+		}
+		for _, msg := range messages {
+			if msg.ID <= lastID {
+				continue
+			}
+			if err := out.print(msg); err != nil {
+				return err
+			}
+			lastID = msg.ID
+		}
+
+		if !sleep(ctx, *interval) {
+			return nil
+		}
+	}
+}
+
+// messageStream prints messages one at a time as they arrive
+type messageStream struct {
+	p      *printer
+	header bool
+}
+
+func newMessageStream(p *printer) *messageStream {
+	return &messageStream{p: p}
+}
+
+func (s *messageStream) print(msg *client.Message) error {
+	switch s.p.format {
+	case outputJSON:
+		return s.p.encode(msg)
+	case outputYAML:
+		if s.header {
+			fmt.Fprintln(s.p.w, "---")
+		}
+		s.header = true
+		return s.p.encode(msg)
+	}
+
+	if !s.header {
+		fmt.Fprintf(s.p.w, "%-10s %-19s %-8s %s\n", "ID", "CREATED", "PRIORITY", "PAYLOAD")
+		s.header = true
+	}
+	_, err := fmt.Fprintf(s.p.w, "%-10d %-19s %-8d %s\n", msg.ID, formatTime(&msg.CreatedAt), msg.Priority, msg.Payload)
+	return err
+}
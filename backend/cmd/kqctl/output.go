@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// printer writes command results in the format chosen with --output
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+func newPrinter(format string) (*printer, error) {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return &printer{format: format, w: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("invalid output format %q, expected table, json or yaml", format)
+	}
+}
+
+// column is one column of a table of T
+type column[T any] struct {
+	header string
+	value  func(T) string
+}
+
+// printList prints items as a table with columns, or encoded as a whole
+func printList[T any](p *printer, items []T, columns []column[T]) error {
+	if p.format != outputTable {
+		if items == nil {
+			items = []T{}
+		}
+		return p.encode(items)
+	}
+
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.header
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+
+	values := make([]string, len(columns))
+	for _, item := range items {
+		for i, col := range columns {
+			values[i] = col.value(item)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	return w.Flush()
+}
+
+// field is one line of a table describing a single resource
+type field struct {
+	name  string
+	value string
+}
+
+// printDetails prints a single resource as NAME: value lines, or v encoded
+func printDetails(p *printer, v any, fields []field) error {
+	if p.format != outputTable {
+		return p.encode(v)
+	}
+
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s:\t%s\n", f.name, f.value)
+	}
+	return w.Flush()
+}
+
+// encode writes v as JSON or YAML. YAML is converted from the JSON encoding so
+// both formats use the same field names, in the same order.
+func (p *printer) encode(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	if p.format == outputJSON {
+		_, err := fmt.Fprintln(p.w, string(data))
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	blockStyle(&node)
+
+	enc := yaml.NewEncoder(p.w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return enc.Close()
+}
+
+// blockStyle clears the JSON flow style and quoting of a parsed document so it is
+// written as block YAML
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatID(id *int64) string {
+	if id == nil {
+		return "-"
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testItem struct {
+	ID   int64    `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+var testColumns = []column[*testItem]{
+	{"ID", func(i *testItem) string { return formatID(&i.ID) }},
+	{"NAME", func(i *testItem) string { return orDash(i.Name) }},
+}
+
+func TestPrintList(t *testing.T) {
+	items := []*testItem{{ID: 1, Name: "orders", Tags: []string{"a", "b"}}, {ID: 22, Name: ""}}
+
+	tests := []struct {
+		format string
+		items  []*testItem
+		want   string
+	}{
+		{
+			format: outputTable,
+			items:  items,
+			want:   "ID  NAME\n1   orders\n22  -\n",
+		},
+		{
+			format: outputTable,
+			items:  nil,
+			want:   "ID  NAME\n",
+		},
+		{
+			format: outputJSON,
+			items:  items,
+			want: `[
+  {
+    "id": 1,
+    "name": "orders",
+    "tags": [
+      "a",
+      "b"
+    ]
+  },
+  {
+    "id": 22,
+    "name": ""
+  }
+]
+`,
+		},
+		{
+			format: outputJSON,
+			items:  nil,
+			want:   "[]\n",
+		},
+		{
+			format: outputYAML,
+			items:  items,
+			want: `- id: 1
+  name: orders
+  tags:
+    - a
+    - b
+- id: 22
+  name: ""
+`,
+		},
+		{
+			format: outputYAML,
+			items:  nil,
+			want:   "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		name := tt.format
+		if tt.items == nil {
+			name += " empty"
+		}
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := &printer{format: tt.format, w: &buf}
+			if err := printList(p, tt.items, testColumns); err != nil {
+				t.Fatalf("printList() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("printList() wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintDetails(t *testing.T) {
+	item := &testItem{ID: 1, Name: "orders"}
+	fields := []field{{"ID", "1"}, {"Name", "orders"}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: outputTable, want: "ID:    1\nName:  orders\n"},
+		{format: outputJSON, want: "{\n  \"id\": 1,\n  \"name\": \"orders\"\n}\n"},
+		{format: outputYAML, want: "id: 1\nname: orders\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := &printer{format: tt.format, w: &buf}
+			if err := printDetails(p, item, fields); err != nil {
+				t.Fatalf("printDetails() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("printDetails() wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPrinter(t *testing.T) {
+	for _, format := range []string{outputTable, outputJSON, outputYAML} {
+		if _, err := newPrinter(format); err != nil {
+			t.Fatalf("newPrinter(%q) error = %v", format, err)
+		}
+	}
+	if _, err := newPrinter("xml"); err == nil {
+		t.Fatal("newPrinter(\"xml\") did not fail")
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shravan20/qafka/pkg/client"
+)
+
+var queueColumns = []column[*client.Queue]{
+	{"ID", func(q *client.Queue) string { return strconv.FormatInt(q.ID, 10) }},
+	{"NAME", func(q *client.Queue) string { return q.Name }},
+	{"TYPE", func(q *client.Queue) string { return q.Type }},
+	{"STATE", func(q *client.Queue) string { return q.State }},
+	{"DLQ", func(q *client.Queue) string { return formatID(q.DeadLetterQueueID) }},
+	{"VERSION", func(q *client.Queue) string { return strconv.FormatInt(q.Version, 10) }},
+	{"CREATED", func(q *client.Queue) string { return formatTime(&q.CreatedAt) }},
+}
+
+// runQueues implements the queues command:
+//
+//	kqctl queues list [--deleted]
+//	kqctl queues create NAME --type TYPE [--config JSON|@FILE] [--description TEXT]
+//	kqctl queues describe QUEUE
+//	kqctl queues delete QUEUE [--mode MODE]
+//	kqctl queues purge QUEUE [--status STATUS]... [--wait]
+//	kqctl queues redrive QUEUE [--id ID]... [--error-contains TEXT]
+func runQueues(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kqctl queues list | create | describe | delete | purge | redrive")
+	}
+
+	fs, g := newFlagSet("queues " + args[0])
+	var (
+		deleted       = fs.Bool("deleted", false, "list deleted queues that can still be restored")
+		queueType     = fs.String("type", "", "queue type: fifo, lifo, priority or delay")
+		queueConfig   = fs.String("config", "", "queue config as JSON, or @FILE to read it from a file")
+		description   = fs.String("description", "", "queue description")
+		mode          = fs.String("mode", "", "delete mode: refuse, cascade or archive")
+		wait          = fs.Bool("wait", false, "wait for the purge to finish")
+		errorContains = fs.String("error-contains", "", "only redrive messages whose last error contains this text")
+		statuses      stringList
+		ids           stringList
+	)
+	fs.Var(&statuses, "status", "only purge messages with this status (repeatable)")
+	fs.Var(&ids, "id", "only redrive this message (repeatable)")
+	pos, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	c, p, err := g.setup()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		if err := wantArgs(pos); err != nil {
+			return err
+		}
+		var queues []*client.Queue
+		if *deleted {
+			queues, err = c.ListDeletedQueues(ctx)
+		} else {
+			queues, err = c.ListQueues(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		return printList(p, queues, queueColumns)
+
+	case "create":
+		if err := wantArgs(pos, "NAME"); err != nil {
+			return err
+		}
+		if *queueType == "" {
+			return errors.New("--type is required")
+		}
+		cfg, err := readArg(*queueConfig)
+		if err != nil {
+			return err
+		}
+		queue, err := c.CreateQueue(ctx, &client.CreateQueueRequest{
+			Name:        pos[0],
+			Description: *description,
+			Type:        *queueType,
+			Config:      cfg,
+		})
+		if err != nil {
+			return err
+		}
+		return printList(p, []*client.Queue{queue}, queueColumns)
+
+	case "describe", "get":
+		if err := wantArgs(pos, "QUEUE"); err != nil {
+			return err
+		}
+		queue, err := resolveQueue(ctx, c, pos[0])
+		if err != nil {
+			return err
+		}
+		workers, err := c.ListWorkers(ctx, queue.ID)
+		if err != nil {
+			return err
+		}
+		return describeQueue(p, queue, workers)
+
+	case "delete", "rm":
+		if err := wantArgs(pos, "QUEUE"); err != nil {
+			return err
+		}
+		queue, err := resolveQueue(ctx, c, pos[0])
+		if err != nil {
+			return err
+		}
+		queue, err = c.DeleteQueue(ctx, queue.ID, *mode)
+		if err != nil {
+			return err
+		}
+		if p.format != outputTable {
+			return p.encode(queue)
+		}
+		fmt.Printf("queue %q deleted\n", queue.Name)
+		return nil
+
+	case "purge":
+		if err := wantArgs(pos, "QUEUE"); err != nil {
+			return err
+		}
+		queue, err := resolveQueue(ctx, c, pos[0])
+		if err != nil {
+			return err
+		}
+		op, err := c.PurgeQueue(ctx, queue.ID, &client.PurgeQueueRequest{Statuses: statuses})
+		if err != nil {
+			return err
+		}
+		for *wait && op.Status == client.BulkOperationRunning {
+			if !sleep(ctx, 500*time.Millisecond) {
+				return ctx.Err()
+			}
+			if op, err = c.GetOperation(ctx, op.ID); err != nil {
+				return err
+			}
+		}
+		return printOperation(p, op)
+
+	case "redrive":
+		if err := wantArgs(pos, "QUEUE"); err != nil {
+			return err
+		}
+		queue, err := resolveQueue(ctx, c, pos[0])
+		if err != nil {
+			return err
+		}
+		req := &client.RedriveRequest{ErrorContains: *errorContains}
+		for _, idStr := range ids {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid message ID %q", idStr)
+			}
+			req.MessageIDs = append(req.MessageIDs, id)
+		}
+		redriven, err := c.Redrive(ctx, queue.ID, req)
+		if err != nil {
+			return err
+		}
+		if p.format != outputTable {
+			return p.encode(map[string]int64{"redriven": redriven})
+		}
+		fmt.Printf("redrove %d messages to queue %q\n", redriven, queue.Name)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown queues command %q", args[0])
+	}
+}
+
+// queueDescription is a queue with the workers consuming it
+type queueDescription struct {
+	*client.Queue
+	Workers []*client.Worker `json:"workers"`
+}
+
+func describeQueue(p *printer, queue *client.Queue, workers []*client.Worker) error {
+	if p.format != outputTable {
+		if workers == nil {
+			workers = []*client.Worker{}
+		}
+		return p.encode(queueDescription{queue, workers})
+	}
+
+	err := printDetails(p, queue, []field{
+		{"ID", strconv.FormatInt(queue.ID, 10)},
+		{"Name", queue.Name},
+		{"Description", orDash(queue.Description)},
+		{"Type", queue.Type},
+		{"State", queue.State},
+		{"Dead letter queue", formatID(queue.DeadLetterQueueID)},
+		{"Config", orDash(queue.Config)},
+		{"Version", strconv.FormatInt(queue.Version, 10)},
+		{"Created", formatTime(&queue.CreatedAt)},
+		{"Updated", formatTime(&queue.UpdatedAt)},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.w)
+	fmt.Fprintln(p.w, "Workers:")
+	return printList(p, workers, workerColumns)
+}
+
+func printOperation(p *printer, op *client.BulkOperation) error {
+	return printDetails(p, op, []field{
+		{"Operation", op.ID},
+		{"Action", op.Action},
+		{"Status", op.Status},
+		{"Processed", strconv.FormatInt(op.Processed, 10)},
+		{"Error", orDash(op.Error)},
+		{"Started", formatTime(&op.StartedAt)},
+		{"Finished", formatTime(op.FinishedAt)},
+	})
+}
+
+// readArg returns arg, or the contents of the file it names when it starts with @
+func readArg(arg string) (string, error) {
+	path, ok := strings.CutPrefix(arg, "@")
+	if !ok {
+		return arg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// sleep waits for d, reporting false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shravan20/qafka/pkg/client"
+)
+
+var workerColumns = []column[*client.Worker]{
+	{"ID", func(w *client.Worker) string { return strconv.FormatInt(w.ID, 10) }},
+	{"NAME", func(w *client.Worker) string { return w.Name }},
+	{"QUEUE", func(w *client.Worker) string { return strconv.FormatInt(w.QueueID, 10) }},
+	{"STATUS", func(w *client.Worker) string { return w.Status }},
+	{"IN FLIGHT", func(w *client.Worker) string { return strconv.Itoa(len(w.CurrentMessageIDs)) }},
+	{"PROCESSED", func(w *client.Worker) string { return strconv.FormatInt(w.ProcessedCount, 10) }},
+	{"FAILED", func(w *client.Worker) string { return strconv.FormatInt(w.FailedCount, 10) }},
+	{"LAST PING", func(w *client.Worker) string { return formatAge(w.LastPing) }},
+}
+
+var workerEventColumns = []column[*client.WorkerEvent]{
+	{"TIME", func(e *client.WorkerEvent) string { return formatTime(&e.CreatedAt) }},
+	{"TYPE", func(e *client.WorkerEvent) string { return e.Type }},
+	{"MESSAGES", func(e *client.WorkerEvent) string { return strconv.FormatInt(e.Messages, 10) }},
+	{"DETAIL", func(e *client.WorkerEvent) string { return orDash(e.Detail) }},
+}
+
+// runWorkers implements the workers command:
+//
+//	kqctl workers list [--queue QUEUE]
+//	kqctl workers describe ID
+func runWorkers(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kqctl workers list | describe")
+	}
+
+	fs, g := newFlagSet("workers " + args[0])
+	queueRef := fs.String("queue", "", "only list the workers of this queue")
+	pos, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	c, p, err := g.setup()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		if err := wantArgs(pos); err != nil {
+			return err
+		}
+		var queueID int64
+		if *queueRef != "" {
+			queue, err := resolveQueue(ctx, c, *queueRef)
+			if err != nil {
+				return err
+			}
+			queueID = queue.ID
+		}
+		workers, err := c.ListWorkers(ctx, queueID)
+		if err != nil {
+			return err
+		}
+		return printList(p, workers, workerColumns)
+
+	case "describe", "get":
+		if err := wantArgs(pos, "ID"); err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(pos[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid worker ID %q", pos[0])
+		}
+		worker, err := c.GetWorker(ctx, id)
+		if err != nil {
+			return err
+		}
+		events, err := c.GetWorkerEvents(ctx, id, 0)
+		if err != nil {
+			return err
+		}
+		return describeWorker(p, worker, events)
+
+	default:
+		return fmt.Errorf("unknown workers command %q", args[0])
+	}
+}
+
+// workerDescription is a worker with its lifecycle events
+type workerDescription struct {
+	*client.Worker
+	Events []*client.WorkerEvent `json:"events"`
+}
+
+func describeWorker(p *printer, worker *client.Worker, events []*client.WorkerEvent) error {
+	if p.format != outputTable {
+		if events == nil {
+			events = []*client.WorkerEvent{}
+		}
+		return p.encode(workerDescription{worker, events})
+	}
+
+	messageIDs := make([]string, len(worker.CurrentMessageIDs))
+	for i, id := range worker.CurrentMessageIDs {
+		messageIDs[i] = strconv.FormatInt(id, 10)
+	}
+
+	err := printDetails(p, worker, []field{
+		{"ID", strconv.FormatInt(worker.ID, 10)},
+		{"Name", worker.Name},
+		{"Queue", strconv.FormatInt(worker.QueueID, 10)},
+		{"Status", worker.Status},
+		{"In flight", orDash(strings.Join(messageIDs, ", "))},
+		{"Processed", strconv.FormatInt(worker.ProcessedCount, 10)},
+		{"Failed", strconv.FormatInt(worker.FailedCount, 10)},
+		{"Last ping", formatTime(&worker.LastPing)},
+		{"Registered", formatTime(&worker.CreatedAt)},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.w)
+	fmt.Fprintln(p.w, "Events:")
+	return printList(p, events, workerEventColumns)
+}
+
+// formatAge formats how long ago t was, e.g. 12s or 3m
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return strconv.Itoa(int(age.Seconds())) + "s"
+	case age < time.Hour:
+		return strconv.Itoa(int(age.Minutes())) + "m"
+	case age < 24*time.Hour:
+		return strconv.Itoa(int(age.Hours())) + "h"
+	default:
+		return strconv.Itoa(int(age.Hours()/24)) + "d"
+	}
+}
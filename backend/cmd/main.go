@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"log"
+	"os"
 
 	"github.com/shravan20/qafka/internal/api"
 	"github.com/shravan20/qafka/internal/config"
 	"github.com/shravan20/qafka/internal/database"
 	"github.com/shravan20/qafka/internal/services"
+	"github.com/shravan20/qafka/internal/storage"
+	"github.com/shravan20/qafka/internal/storage/memory"
+	"github.com/shravan20/qafka/internal/storage/postgres"
 
 	"github.com/go-fuego/fuego"
 	"github.com/joho/godotenv"
@@ -36,34 +41,83 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Initialize database
-	db, err := database.Connect(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		catalog  storage.Catalog
+		backend  storage.Backend
+		notifier services.Notifier
+		leaderDB *sql.DB // elects the replica running schedules, nil in memory mode
+	)
 
-	// Run migrations
-	if err := database.RunMigrations(context.Background(), db); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+	switch cfg.Storage {
+	case config.StorageMemory:
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			log.Fatal("migrate needs STORAGE=postgres")
+		}
+
+		log.Println("Using in-memory storage: run a single replica, nothing survives a restart")
+		catalog = memory.NewCatalog()
+		backend = memory.New()
+		notifier = services.NewLocalNotifier()
+
+	case config.StoragePostgres:
+		// Initialize database
+		db, err := database.Connect(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatal("Failed to connect to database:", err)
+		}
+		defer db.Close()
+
+		// Apply, revert or inspect migrations and exit
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			if err := runMigrate(ctx, db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		// Run migrations
+		if err := database.RunMigrations(ctx, db); err != nil {
+			log.Fatal("Failed to run migrations:", err)
+		}
+
+		pgNotifier := services.NewPostgresNotifier(db)
+		go pgNotifier.Run(ctx)
+
+		catalog = postgres.NewCatalog(db)
+		backend = postgres.New(db)
+		notifier = pgNotifier
+		leaderDB = db.DB
+
+	default:
+		log.Fatalf("Unknown STORAGE %q: use postgres or memory", cfg.Storage)
 	}
 
 	// Initialize services
-	queueService := services.NewQueueService(db)
-	monitoringService := services.NewMonitoringService()
+	queueService := services.NewQueueService(catalog, backend, notifier, cfg)
+	monitoringService := services.NewMonitoringService(queueService)
+	scheduleService := services.NewScheduleService(catalog, queueService)
+
+	// Start background workers
+	go services.NewLeaseReaper(queueService, cfg.LeaseReaperInterval).Run(ctx)
+	go services.NewScheduler(queueService, monitoringService, cfg.SchedulerInterval).Run(ctx)
+	go services.NewScheduleRunner(scheduleService, leaderDB, cfg.SchedulerInterval).Run(ctx)
+	go services.NewWorkerSupervisor(queueService, cfg.SupervisorInterval).Run(ctx)
 
 	// Create Fuego app
 	app := fuego.NewServer(
 		fuego.WithPort(cfg.APIPort),
 		fuego.WithCORS(fuego.CORSConfig{
 			AllowOrigins: cfg.CORSOrigins,
-			AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowHeaders: []string{"*"},
 		}),
 	)
 
 	// Setup routes
-	api.SetupRoutes(app, queueService, monitoringService)
+	api.SetupRoutes(app, queueService, scheduleService, monitoringService)
 
 	// Setup Swagger documentation
 	api.SetupSwagger(app)
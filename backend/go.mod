@@ -9,7 +9,10 @@ require (
 	github.com/uptrace/bun/extra/bundebug v1.1.16
 	github.com/joho/godotenv v1.5.1
 	github.com/prometheus/client_golang v1.17.0
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/swaggo/swag v1.16.2
 	github.com/swaggo/http-swagger v1.3.4
 	github.com/swaggo/files v1.0.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -2,18 +2,25 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-fuego/fuego"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/selector"
 	"github.com/shravan20/qafka/internal/services"
 )
 
-func SetupRoutes(app *fuego.Server, queueService *services.QueueService, monitoringService *services.MonitoringService) {
+// maxBatchSize caps the number of messages produced or claimed by a single request
+const maxBatchSize = 100
+
+func SetupRoutes(app *fuego.Server, queueService *services.QueueService, scheduleService *services.ScheduleService, monitoringService *services.MonitoringService) {
 	// Health check
 	fuego.Get(app, "/health", func(c fuego.ContextNoBody) (any, error) {
 		return map[string]string{"status": "healthy"}, nil
@@ -31,6 +38,12 @@ func SetupRoutes(app *fuego.Server, queueService *services.QueueService, monitor
 	// Worker routes
 	setupWorkerRoutes(v1, queueService, monitoringService)
 
+	// Bulk operation routes
+	setupOperationRoutes(v1, queueService)
+
+	// Schedule routes
+	setupScheduleRoutes(v1, scheduleService)
+
 	// Metrics endpoint
 	app.Handle(http.MethodGet, "/metrics", promhttp.Handler().ServeHTTP)
 }
@@ -44,13 +57,37 @@ func SetupSwagger(app *fuego.Server) {
 func setupQueueRoutes(group *fuego.Group, queueService *services.QueueService, monitoringService *services.MonitoringService) {
 	// Get all queues
 	// @Summary Get all queues
-	// @Description Get a list of all queues
+	// @Description Get a list of all queues, or with deleted=true of the deleted queues that can still be restored
 	// @Tags queues
 	// @Accept json
 	// @Produce json
+	// @Param deleted query bool false "List deleted queues instead"
 	// @Success 200 {array} models.Queue
 	// @Router /api/v1/queues [get]
 	fuego.Get(group, "/queues", func(c fuego.ContextNoBody) (any, error) {
+		deleted := false
+		if deletedStr := c.QueryParam("deleted"); deletedStr != "" {
+			var err error
+			deleted, err = strconv.ParseBool(deletedStr)
+			if err != nil {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid deleted parameter",
+				}
+			}
+		}
+
+		if deleted {
+			queues, err := queueService.GetDeletedQueues(context.Background())
+			if err != nil {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusInternalServerError,
+					Message:    "Failed to get queues",
+				}
+			}
+			return queues, nil
+		}
+
 		queues, err := queueService.GetQueues(context.Background())
 		if err != nil {
 			return nil, fuego.HTTPError{
@@ -58,6 +95,7 @@ func setupQueueRoutes(group *fuego.Group, queueService *services.QueueService, m
 				Message:    "Failed to get queues",
 			}
 		}
+
 		return queues, nil
 	})
 
@@ -68,6 +106,7 @@ func setupQueueRoutes(group *fuego.Group, queueService *services.QueueService, m
 	// @Accept json
 	// @Produce json
 	// @Param queue body models.CreateQueueRequest true "Queue creation request"
+	// @Param dry_run query bool false "Validate the request and return the queue without creating it"
 	// @Success 201 {object} models.Queue
 	// @Router /api/v1/queues [post]
 	fuego.Post(group, "/queues", func(c fuego.ContextWithBody[models.CreateQueueRequest]) (*models.Queue, error) {
@@ -79,7 +118,32 @@ func setupQueueRoutes(group *fuego.Group, queueService *services.QueueService, m
 			}
 		}
 
-		queue, err := queueService.CreateQueue(context.Background(), &body)
+		dryRun := false
+		if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+			dryRun, err = strconv.ParseBool(dryRunStr)
+			if err != nil {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid dry_run parameter",
+				}
+			}
+		}
+
+		var queue *models.Queue
+		if dryRun {
+			queue, err = queueService.ValidateQueue(context.Background(), &body)
+		} else {
+			queue, err = queueService.CreateQueue(context.Background(), &body)
+		}
+		if validationErr := queueValidationError(err); validationErr != nil {
+			return nil, validationErr
+		}
+		if errors.Is(err, services.ErrQueueExists) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusConflict,
+				Message:    err.Error(),
+			}
+		}
 		if err != nil {
 			return nil, fuego.HTTPError{
 				StatusCode: http.StatusInternalServerError,
@@ -117,19 +181,144 @@ func setupQueueRoutes(group *fuego.Group, queueService *services.QueueService, m
 			}
 		}
 
+		c.Response().Header().Set("ETag", queueETag(queue))
+		return queue, nil
+	})
+
+	// Update queue
+	// @Summary Update a queue
+	// @Description Change the description, config or is_active of a queue. Send the queue's ETag in If-Match to reject the update when someone else changed the queue first.
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param If-Match header string false "ETag or version the update is based on"
+	// @Param dry_run query bool false "Validate the update and return the result without saving it"
+	// @Param queue body models.UpdateQueueRequest true "Fields to change"
+	// @Success 200 {object} models.Queue
+	// @Failure 409 {object} fuego.HTTPError
+	// @Router /api/v1/queues/{id} [patch]
+	fuego.Patch(group, "/queues/{id}", func(c fuego.ContextWithBody[models.UpdateQueueRequest]) (*models.Queue, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		ifVersion, err := parseIfMatch(c.Request().Header.Get("If-Match"))
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid If-Match header",
+			}
+		}
+
+		dryRun := false
+		if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+			dryRun, err = strconv.ParseBool(dryRunStr)
+			if err != nil {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid dry_run parameter",
+				}
+			}
+		}
+
+		queue, err := queueService.UpdateQueue(context.Background(), id, &body, ifVersion, dryRun)
+		if validationErr := queueValidationError(err); validationErr != nil {
+			return nil, validationErr
+		}
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if errors.Is(err, services.ErrVersionConflict) || errors.Is(err, services.ErrQueueExists) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusConflict,
+				Message:    err.Error(),
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to update queue",
+			}
+		}
+
+		c.Response().Header().Set("ETag", queueETag(queue))
 		return queue, nil
 	})
 
+	// Pause queue
+	// @Summary Pause a queue
+	// @Description Stop handing out claims. A paused queue still accepts produces.
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Success 200 {object} models.Queue
+	// @Router /api/v1/queues/{id}/pause [post]
+	fuego.Post(group, "/queues/{id}/pause", queueStateHandler(queueService.PauseQueue))
+
+	// Resume queue
+	// @Summary Resume a queue
+	// @Description Return a paused or draining queue to the active state
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Success 200 {object} models.Queue
+	// @Router /api/v1/queues/{id}/resume [post]
+	fuego.Post(group, "/queues/{id}/resume", queueStateHandler(queueService.ResumeQueue))
+
+	// Drain queue
+	// @Summary Drain a queue
+	// @Description Reject new produces while consumers empty the queue
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Success 200 {object} models.Queue
+	// @Router /api/v1/queues/{id}/drain [post]
+	fuego.Post(group, "/queues/{id}/drain", queueStateHandler(queueService.DrainQueue))
+
+	// Purge queue
+	// @Summary Purge a queue
+	// @Description Delete the messages of a queue, optionally only those with the given statuses or ages. The purge runs in the background in chunks; poll the returned operation for progress.
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param purge body models.PurgeQueueRequest false "Purge filters"
+	// @Success 200 {object} models.BulkOperation
+	// @Router /api/v1/queues/{id}/purge [post]
+	fuego.Post(group, "/queues/{id}/purge", bulkHandler(queueService.PurgeQueue))
+
 	// Delete queue
 	// @Summary Delete a queue
-	// @Description Delete a queue by ID
+	// @Description Delete a queue by ID. The queue stops accepting produces and claims at once but can be restored until the grace period ends, when its messages are deleted or, in archive mode, archived. In refuse mode, the default, a queue holding messages is not deleted.
 	// @Tags queues
 	// @Accept json
 	// @Produce json
 	// @Param id path int true "Queue ID"
-	// @Success 204
+	// @Param mode query string false "refuse, cascade or archive"
+	// @Success 200 {object} models.Queue
+	// @Failure 409 "Queue not empty or used as a dead letter queue"
 	// @Router /api/v1/queues/{id} [delete]
-	fuego.Delete(group, "/queues/{id}", func(c fuego.ContextNoBody) (any, error) {
+	fuego.Delete(group, "/queues/{id}", func(c fuego.ContextNoBody) (*models.Queue, error) {
 		idStr := c.PathParam("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
@@ -139,15 +328,93 @@ func setupQueueRoutes(group *fuego.Group, queueService *services.QueueService, m
 			}
 		}
 
-		err = queueService.DeleteQueue(context.Background(), id)
-		if err != nil {
+		queue, err := queueService.DeleteQueue(context.Background(), id, c.QueryParam("mode"))
+		switch {
+		case errors.Is(err, services.ErrInvalidDeleteMode):
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    err.Error(),
+			}
+		case errors.Is(err, services.ErrQueueNotFound):
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		case errors.Is(err, services.ErrQueueNotEmpty), errors.Is(err, services.ErrQueueInUse):
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusConflict,
+				Message:    err.Error(),
+			}
+		case err != nil:
 			return nil, fuego.HTTPError{
 				StatusCode: http.StatusInternalServerError,
 				Message:    "Failed to delete queue",
 			}
 		}
 
-		return nil, nil
+		return queue, nil
+	})
+
+	// Restore queue
+	// @Summary Restore a deleted queue
+	// @Description Undo the deletion of a queue whose grace period has not ended yet
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Success 200 {object} models.Queue
+	// @Router /api/v1/queues/{id}/restore [post]
+	fuego.Post(group, "/queues/{id}/restore", queueStateHandler(queueService.RestoreQueue))
+
+	// Apply queue declarations
+	// @Summary Apply declared queues
+	// @Description Diff the queues declared by a manager against the existing queues, then create, update and, with prune, delete queues to match. Queues the manager does not own are left alone unless adopt takes over unmanaged ones. Applying the same declarations again changes nothing.
+	// @Tags queues
+	// @Accept json
+	// @Produce json
+	// @Param apply body models.ApplyQueuesRequest true "Queue declarations"
+	// @Param dry_run query bool false "Only return the plan of changes"
+	// @Success 200 {object} models.ApplyPlan
+	// @Failure 409 "A queue changed concurrently, or a pruned queue is not empty or still in use"
+	// @Router /api/v1/queues:apply [post]
+	fuego.Post(group, "/queues:apply", func(c fuego.ContextWithBody[models.ApplyQueuesRequest]) (*models.ApplyPlan, error) {
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		dryRun := false
+		if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+			dryRun, err = strconv.ParseBool(dryRunStr)
+			if err != nil {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid dry_run parameter",
+				}
+			}
+		}
+
+		plan, err := queueService.ApplyQueues(context.Background(), &body, dryRun)
+		var configErr *models.ConfigError
+		switch {
+		case errors.Is(err, services.ErrInvalidQueueSpec) && errors.As(err, &configErr):
+			return nil, validationError{Message: "Invalid queue declarations", Errors: configErr.Errors}
+		case errors.Is(err, services.ErrVersionConflict), errors.Is(err, services.ErrQueueNotEmpty), errors.Is(err, services.ErrQueueInUse), errors.Is(err, services.ErrQueueExists):
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusConflict,
+				Message:    err.Error(),
+			}
+		case err != nil:
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to apply queues",
+			}
+		}
+
+		return plan, nil
 	})
 }
 
@@ -160,6 +427,7 @@ func setupMessageRoutes(group *fuego.Group, queueService *services.QueueService,
 	// @Produce json
 	// @Param queue_id query int false "Queue ID filter"
 	// @Param limit query int false "Limit number of results"
+	// @Param selector query string false "Header selector, e.g. type=invoice AND region=eu"
 	// @Success 200 {array} models.Message
 	// @Router /api/v1/messages [get]
 	fuego.Get(group, "/messages", func(c fuego.ContextNoBody) (any, error) {
@@ -190,7 +458,12 @@ func setupMessageRoutes(group *fuego.Group, queueService *services.QueueService,
 			}
 		}
 
-		messages, err := queueService.GetMessages(context.Background(), queueID, limit)
+		sel, err := parseSelector(c.QueryParam("selector"))
+		if err != nil {
+			return nil, err
+		}
+
+		messages, err := queueService.GetMessages(context.Background(), queueID, limit, sel)
 		if err != nil {
 			return nil, fuego.HTTPError{
 				StatusCode: http.StatusInternalServerError,
@@ -203,11 +476,12 @@ func setupMessageRoutes(group *fuego.Group, queueService *services.QueueService,
 
 	// Create message
 	// @Summary Create a new message
-	// @Description Add a new message to a queue
+	// @Description Add a new message to a queue. A message with a future scheduled_at or delay_seconds, or produced to a queue with a default delay, is held as scheduled until due.
 	// @Tags messages
 	// @Accept json
 	// @Produce json
 	// @Param message body models.CreateMessageRequest true "Message creation request"
+	// @Param Idempotency-Key header string false "Deduplication key, used when the body has no dedup_id"
 	// @Success 201 {object} models.Message
 	// @Router /api/v1/messages [post]
 	fuego.Post(group, "/messages", func(c fuego.ContextWithBody[models.CreateMessageRequest]) (*models.Message, error) {
@@ -219,7 +493,26 @@ func setupMessageRoutes(group *fuego.Group, queueService *services.QueueService,
 			}
 		}
 
+		if body.DedupID == "" {
+			body.DedupID = c.Request().Header.Get("Idempotency-Key")
+		}
+
 		message, err := queueService.CreateMessage(context.Background(), &body)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if admissionErr := admissionError(err); admissionErr != nil {
+			return nil, admissionErr
+		}
+		if errors.Is(err, services.ErrInvalidMessage) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    err.Error(),
+			}
+		}
 		if err != nil {
 			return nil, fuego.HTTPError{
 				StatusCode: http.StatusInternalServerError,
@@ -232,42 +525,1242 @@ func setupMessageRoutes(group *fuego.Group, queueService *services.QueueService,
 
 		return message, nil
 	})
-}
 
-func setupWorkerRoutes(group *fuego.Group, queueService *services.QueueService, monitoringService *services.MonitoringService) {
-	// Get workers
-	// @Summary Get workers
-	// @Description Get workers for a queue
-	// @Tags workers
+	// Batch create messages
+	// @Summary Produce a batch of messages
+	// @Description Add up to 100 messages to a queue with a single bulk insert, reporting a result per item
+	// @Tags messages
 	// @Accept json
 	// @Produce json
-	// @Param queue_id query int false "Queue ID filter"
-	// @Success 200 {array} models.Worker
-	// @Router /api/v1/workers [get]
-	fuego.Get(group, "/workers", func(c fuego.ContextNoBody) (any, error) {
-		queueIDStr := c.QueryParam("queue_id")
+	// @Param id path int true "Queue ID"
+	// @Param batch body models.BatchCreateMessagesRequest true "Batch produce request"
+	// @Success 200 {object} models.BatchCreateMessagesResponse
+	// @Router /api/v1/queues/{id}/messages:batch [post]
+	fuego.Post(group, "/queues/{id}/messages:batch", func(c fuego.ContextWithBody[models.BatchCreateMessagesRequest]) (*models.BatchCreateMessagesResponse, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
 
-		var queueID int64
-		var err error
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
 
-		if queueIDStr != "" {
-			queueID, err = strconv.ParseInt(queueIDStr, 10, 64)
-			if err != nil {
-				return nil, fuego.HTTPError{
-					StatusCode: http.StatusBadRequest,
-					Message:    "Invalid queue_id parameter",
-				}
+		if len(body.Messages) == 0 || len(body.Messages) > maxBatchSize {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "A batch must contain between 1 and " + strconv.Itoa(maxBatchSize) + " messages",
 			}
 		}
 
-		workers, err := queueService.GetWorkers(context.Background(), queueID)
+		results, err := queueService.CreateMessages(context.Background(), id, body.Messages)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if admissionErr := admissionError(err); admissionErr != nil {
+			return nil, admissionErr
+		}
 		if err != nil {
 			return nil, fuego.HTTPError{
 				StatusCode: http.StatusInternalServerError,
-				Message:    "Failed to get workers",
+				Message:    "Failed to create messages",
 			}
 		}
 
-		return workers, nil
+		created := 0
+		for _, result := range results {
+			if result.Message != nil {
+				created++
+			}
+		}
+		monitoringService.AddMessageCounter("queue_"+strconv.FormatInt(id, 10), "created", float64(created))
+
+		return &models.BatchCreateMessagesResponse{Results: results}, nil
 	})
+
+	// Claim next message
+	// @Summary Claim the next message
+	// @Description Atomically claim the highest-priority eligible message of a queue for a worker
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param claim body models.ClaimMessageRequest true "Claim request"
+	// @Success 200 {object} models.ClaimedMessage
+	// @Failure 404 "No message available"
+	// @Failure 409 "Worker stopped or registered for another queue"
+	// @Router /api/v1/queues/{id}/claim [post]
+	fuego.Post(group, "/queues/{id}/claim", func(c fuego.ContextWithBody[models.ClaimMessageRequest]) (*models.ClaimedMessage, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		sel, err := parseSelector(body.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		message, err := queueService.ClaimMessage(context.Background(), id, body.WorkerID, sel)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if admissionErr := admissionError(err); admissionErr != nil {
+			return nil, admissionErr
+		}
+		if workerErr := claimWorkerError(err); workerErr != nil {
+			return nil, workerErr
+		}
+		if errors.Is(err, services.ErrNoMessageAvailable) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "No message available",
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to claim message",
+			}
+		}
+
+		monitoringService.IncrementMessageCounter("queue_"+strconv.FormatInt(id, 10), "claimed")
+
+		return &models.ClaimedMessage{Message: message, LeaseToken: message.LeaseToken}, nil
+	})
+
+	// Batch claim messages
+	// @Summary Claim a batch of messages
+	// @Description Atomically claim up to max eligible messages of a queue in a single transaction
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param claim body models.BatchClaimRequest true "Batch claim request"
+	// @Success 200 {array} models.ClaimedMessage
+	// @Failure 409 "Worker stopped or registered for another queue"
+	// @Router /api/v1/queues/{id}/claim:batch [post]
+	fuego.Post(group, "/queues/{id}/claim:batch", func(c fuego.ContextWithBody[models.BatchClaimRequest]) ([]*models.ClaimedMessage, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		if body.Max == 0 {
+			body.Max = 1
+		}
+		if body.Max < 1 || body.Max > maxBatchSize {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "max must be between 1 and " + strconv.Itoa(maxBatchSize),
+			}
+		}
+
+		sel, err := parseSelector(body.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		messages, err := queueService.ClaimMessages(context.Background(), id, body.WorkerID, body.Max, sel)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if admissionErr := admissionError(err); admissionErr != nil {
+			return nil, admissionErr
+		}
+		if workerErr := claimWorkerError(err); workerErr != nil {
+			return nil, workerErr
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to claim messages",
+			}
+		}
+
+		monitoringService.AddMessageCounter("queue_"+strconv.FormatInt(id, 10), "claimed", float64(len(messages)))
+
+		return models.NewClaimedMessages(messages), nil
+	})
+
+	// Long-poll for messages
+	// @Summary Wait for the next messages
+	// @Description Claim up to max messages, holding the request open for up to wait until one becomes eligible
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param worker_id query int true "Worker claiming the messages"
+	// @Param wait query string false "Maximum time to wait, e.g. 20s"
+	// @Param max query int false "Maximum number of messages to claim (default 1)"
+	// @Param selector query string false "Header selector, e.g. type=invoice AND region=eu"
+	// @Success 200 {array} models.ClaimedMessage
+	// @Failure 409 "Worker stopped or registered for another queue"
+	// @Router /api/v1/queues/{id}/messages/next [get]
+	fuego.Get(group, "/queues/{id}/messages/next", func(c fuego.ContextNoBody) ([]*models.ClaimedMessage, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		workerID, err := strconv.ParseInt(c.QueryParam("worker_id"), 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid worker_id parameter",
+			}
+		}
+
+		var wait time.Duration
+		if waitStr := c.QueryParam("wait"); waitStr != "" {
+			wait, err = time.ParseDuration(waitStr)
+			if err != nil || wait < 0 {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid wait parameter",
+				}
+			}
+		}
+
+		max := 1
+		if maxStr := c.QueryParam("max"); maxStr != "" {
+			max, err = strconv.Atoi(maxStr)
+			if err != nil || max < 1 || max > maxBatchSize {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid max parameter",
+				}
+			}
+		}
+
+		sel, err := parseSelector(c.QueryParam("selector"))
+		if err != nil {
+			return nil, err
+		}
+
+		messages, err := queueService.WaitForMessages(c.Request().Context(), id, workerID, max, sel, wait)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if admissionErr := admissionError(err); admissionErr != nil {
+			return nil, admissionErr
+		}
+		if workerErr := claimWorkerError(err); workerErr != nil {
+			return nil, workerErr
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to get messages",
+			}
+		}
+
+		monitoringService.AddMessageCounter("queue_"+strconv.FormatInt(id, 10), "claimed", float64(len(messages)))
+
+		return models.NewClaimedMessages(messages), nil
+	})
+
+	// Redrive dead letter queue
+	// @Summary Redrive dead-lettered messages
+	// @Description Move dead-lettered messages back to their source queue, optionally filtered by ID, error text or age
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Source queue ID"
+	// @Param redrive body models.RedriveRequest true "Redrive request"
+	// @Success 200 {object} models.RedriveResponse
+	// @Router /api/v1/queues/{id}/redrive [post]
+	fuego.Post(group, "/queues/{id}/redrive", func(c fuego.ContextWithBody[models.RedriveRequest]) (*models.RedriveResponse, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		redriven, err := queueService.RedriveMessages(context.Background(), id, &body)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if errors.Is(err, services.ErrNoDeadLetterQueue) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Queue has no dead letter queue",
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to redrive messages",
+			}
+		}
+
+		monitoringService.AddMessageCounter("queue_"+strconv.FormatInt(id, 10), "redriven", float64(redriven))
+
+		return &models.RedriveResponse{Redriven: redriven}, nil
+	})
+
+	// Bulk delete messages
+	// @Summary Delete the messages matching a filter
+	// @Description Delete messages by status, age, ID or header selector. Runs in the background in chunks; poll the returned operation for progress.
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param filter body models.MessageFilterRequest true "Message filter"
+	// @Success 200 {object} models.BulkOperation
+	// @Router /api/v1/queues/{id}/messages:delete [post]
+	fuego.Post(group, "/queues/{id}/messages:delete", bulkHandler(queueService.DeleteMessages))
+
+	// Bulk requeue messages
+	// @Summary Requeue the messages matching a filter
+	// @Description Return completed, failed and dead-lettered messages matching the filter to pending with a fresh retry budget. Dead letter queues are refused with 400; redrive their messages from the source queue. Runs in the background in chunks; poll the returned operation for progress.
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param filter body models.MessageFilterRequest true "Message filter"
+	// @Success 200 {object} models.BulkOperation
+	// @Router /api/v1/queues/{id}/messages:requeue [post]
+	fuego.Post(group, "/queues/{id}/messages:requeue", bulkHandler(queueService.RequeueMessages))
+
+	// Bulk reprioritize messages
+	// @Summary Reprioritize the messages matching a filter
+	// @Description Set the priority of the messages matching the filter. Runs in the background in chunks; poll the returned operation for progress.
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Queue ID"
+	// @Param reprioritize body models.ReprioritizeMessagesRequest true "Message filter and new priority"
+	// @Success 200 {object} models.BulkOperation
+	// @Router /api/v1/queues/{id}/messages:reprioritize [post]
+	fuego.Post(group, "/queues/{id}/messages:reprioritize", bulkHandler(queueService.ReprioritizeMessages))
+
+	// Extend lease
+	// @Summary Extend a message lease
+	// @Description Push back the lease deadline of a claimed message for long-running jobs
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Message ID"
+	// @Param lease body models.ExtendLeaseRequest true "Lease extension request"
+	// @Success 200 {object} models.Message
+	// @Failure 409 "Lease not held"
+	// @Router /api/v1/messages/{id}/lease [post]
+	fuego.Post(group, "/messages/{id}/lease", func(c fuego.ContextWithBody[models.ExtendLeaseRequest]) (*models.Message, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid message ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		extension := time.Duration(body.LeaseSeconds) * time.Second
+		message, err := queueService.ExtendLease(context.Background(), id, body.LeaseToken, extension)
+		if err != nil {
+			return nil, messageError(err, "Failed to extend lease")
+		}
+
+		return message, nil
+	})
+
+	// Acknowledge message
+	// @Summary Acknowledge a message
+	// @Description Mark a claimed message as completed
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Message ID"
+	// @Param ack body models.AckMessageRequest true "Ack request"
+	// @Success 200 {object} models.Message
+	// @Failure 409 "Lease not held"
+	// @Router /api/v1/messages/{id}/ack [post]
+	fuego.Post(group, "/messages/{id}/ack", func(c fuego.ContextWithBody[models.AckMessageRequest]) (*models.Message, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid message ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		message, err := queueService.AckMessage(context.Background(), id, body.LeaseToken)
+		if err != nil {
+			return nil, messageError(err, "Failed to acknowledge message")
+		}
+
+		queueName := "queue_" + strconv.FormatInt(message.QueueID, 10)
+		monitoringService.IncrementMessageCounter(queueName, models.MessageStatusCompleted)
+		if message.ClaimedAt != nil && message.ProcessedAt != nil {
+			monitoringService.ObserveProcessingTime(queueName, message.ProcessedAt.Sub(*message.ClaimedAt).Seconds())
+		}
+
+		return message, nil
+	})
+
+	// Negatively acknowledge message
+	// @Summary Nack a message
+	// @Description Report a processing failure; the message is retried per the queue's retry policy until its retries run out
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Message ID"
+	// @Param nack body models.NackMessageRequest true "Nack request"
+	// @Success 200 {object} models.Message
+	// @Failure 409 "Lease not held"
+	// @Router /api/v1/messages/{id}/nack [post]
+	fuego.Post(group, "/messages/{id}/nack", func(c fuego.ContextWithBody[models.NackMessageRequest]) (*models.Message, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid message ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		var requeueDelay *time.Duration
+		if body.RequeueDelaySeconds != nil {
+			if *body.RequeueDelaySeconds < 0 {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "requeue_delay_seconds must not be negative",
+				}
+			}
+			delay := time.Duration(*body.RequeueDelaySeconds) * time.Second
+			requeueDelay = &delay
+		}
+
+		message, err := queueService.NackMessage(context.Background(), id, body.LeaseToken, body.Error, requeueDelay)
+		if err != nil {
+			return nil, messageError(err, "Failed to nack message")
+		}
+
+		if message.Status == models.MessageStatusDeadLettered && message.OriginalQueueID != nil {
+			monitoringService.IncrementMessageCounter("queue_"+strconv.FormatInt(*message.OriginalQueueID, 10), models.MessageStatusDeadLettered)
+		} else {
+			monitoringService.IncrementMessageCounter("queue_"+strconv.FormatInt(message.QueueID, 10), "nacked")
+		}
+
+		return message, nil
+	})
+
+	// Release message
+	// @Summary Release a message
+	// @Description Hand a claimed message back to its queue without counting a retry
+	// @Tags messages
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Message ID"
+	// @Param release body models.ReleaseMessageRequest true "Release request"
+	// @Success 200 {object} models.Message
+	// @Failure 409 "Lease not held"
+	// @Router /api/v1/messages/{id}/release [post]
+	fuego.Post(group, "/messages/{id}/release", func(c fuego.ContextWithBody[models.ReleaseMessageRequest]) (*models.Message, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid message ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		message, err := queueService.ReleaseMessage(context.Background(), id, body.LeaseToken)
+		if err != nil {
+			return nil, messageError(err, "Failed to release message")
+		}
+
+		monitoringService.IncrementMessageCounter("queue_"+strconv.FormatInt(message.QueueID, 10), "released")
+
+		return message, nil
+	})
+}
+
+// parseSelector parses a header selector, reporting syntax errors as bad requests
+func parseSelector(s string) (*selector.Selector, error) {
+	sel, err := selector.Parse(s)
+	if err != nil {
+		return nil, fuego.HTTPError{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+	}
+	return sel, nil
+}
+
+// queueStateHandler returns a handler moving the queue in the path to a new state with apply
+func queueStateHandler(apply func(ctx context.Context, id int64) (*models.Queue, error)) func(c fuego.ContextWithBody[any]) (*models.Queue, error) {
+	return func(c fuego.ContextWithBody[any]) (*models.Queue, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		queue, err := apply(context.Background(), id)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to change queue state",
+			}
+		}
+
+		c.Response().Header().Set("ETag", queueETag(queue))
+		return queue, nil
+	}
+}
+
+// bulkHandler returns a handler starting a bulk operation on the queue in the path with start
+func bulkHandler[T any](start func(ctx context.Context, queueID int64, req *T) (*models.BulkOperation, error)) func(c fuego.ContextWithBody[T]) (*models.BulkOperation, error) {
+	return func(c fuego.ContextWithBody[T]) (*models.BulkOperation, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid queue ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		op, err := start(context.Background(), id, &body)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if errors.Is(err, services.ErrInvalidFilter) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    err.Error(),
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to start bulk operation",
+			}
+		}
+
+		return op, nil
+	}
+}
+
+// queueETag returns the entity tag of a queue, derived from its version
+func queueETag(queue *models.Queue) string {
+	return `"` + strconv.FormatInt(queue.Version, 10) + `"`
+}
+
+// parseIfMatch returns the queue version named by an If-Match header, which may
+// carry an ETag or a bare version. It returns zero when the header is empty or "*".
+func parseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	header = strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid If-Match header")
+	}
+	return version, nil
+}
+
+// validationError is a bad request response listing every invalid field
+type validationError struct {
+	Message string              `json:"error"`
+	Errors  []models.FieldError `json:"errors"`
+}
+
+func (e validationError) Error() string { return e.Message }
+
+// Status is the HTTP status fuego responds with
+func (e validationError) Status() int { return http.StatusBadRequest }
+
+// queueValidationError maps an invalid queue type or configuration to a
+// validationError. It returns nil for any other error.
+func queueValidationError(err error) error {
+	var configErr *models.ConfigError
+	switch {
+	case errors.As(err, &configErr):
+		return validationError{Message: "Invalid queue config", Errors: configErr.Errors}
+	case errors.Is(err, services.ErrInvalidQueueType):
+		return validationError{
+			Message: "Invalid queue type",
+			Errors:  []models.FieldError{{Field: "type", Message: err.Error()}},
+		}
+	case errors.Is(err, services.ErrInvalidQueueConfig):
+		return validationError{
+			Message: "Invalid queue config",
+			Errors:  []models.FieldError{{Field: "config", Message: err.Error()}},
+		}
+	default:
+		return nil
+	}
+}
+
+// admissionError maps the errors of a queue refusing messages or claims, because
+// of its state or its size and rate limits, to HTTP errors. It returns nil for any
+// other error.
+func admissionError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrQueueDraining):
+		return fuego.HTTPError{
+			StatusCode: http.StatusConflict,
+			Message:    err.Error(),
+		}
+	case errors.Is(err, services.ErrMessageTooLarge):
+		return fuego.HTTPError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    err.Error(),
+		}
+	case errors.Is(err, services.ErrQueueFull), errors.Is(err, services.ErrRateLimited):
+		return fuego.HTTPError{
+			StatusCode: http.StatusTooManyRequests,
+			Message:    err.Error(),
+		}
+	default:
+		return nil
+	}
+}
+
+// claimWorkerError maps the errors of a claim by an unknown or stopped worker, or
+// by a worker of another queue, to HTTP errors. It returns nil for any other error.
+func claimWorkerError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrWorkerNotFound):
+		return fuego.HTTPError{
+			StatusCode: http.StatusNotFound,
+			Message:    "Worker not found",
+		}
+	case errors.Is(err, services.ErrWorkerStopped), errors.Is(err, services.ErrWorkerQueueMismatch):
+		return fuego.HTTPError{
+			StatusCode: http.StatusConflict,
+			Message:    err.Error(),
+		}
+	default:
+		return nil
+	}
+}
+
+// messageError maps message lifecycle errors to HTTP errors
+func messageError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		return fuego.HTTPError{
+			StatusCode: http.StatusNotFound,
+			Message:    "Message not found",
+		}
+	case errors.Is(err, services.ErrLeaseNotHeld):
+		return fuego.HTTPError{
+			StatusCode: http.StatusConflict,
+			Message:    "Lease not held by caller",
+		}
+	default:
+		return fuego.HTTPError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    fallback,
+		}
+	}
+}
+
+func setupWorkerRoutes(group *fuego.Group, queueService *services.QueueService, monitoringService *services.MonitoringService) {
+	// Get workers
+	// @Summary Get workers
+	// @Description Get workers for a queue
+	// @Tags workers
+	// @Accept json
+	// @Produce json
+	// @Param queue_id query int false "Queue ID filter"
+	// @Success 200 {array} models.Worker
+	// @Router /api/v1/workers [get]
+	fuego.Get(group, "/workers", func(c fuego.ContextNoBody) (any, error) {
+		queueIDStr := c.QueryParam("queue_id")
+
+		var queueID int64
+		var err error
+
+		if queueIDStr != "" {
+			queueID, err = strconv.ParseInt(queueIDStr, 10, 64)
+			if err != nil {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid queue_id parameter",
+				}
+			}
+		}
+
+		workers, err := queueService.GetWorkers(context.Background(), queueID)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to get workers",
+			}
+		}
+
+		return workers, nil
+	})
+
+	// Register worker
+	// @Summary Register a worker
+	// @Description Register a worker consuming a queue. The worker must then send heartbeats to stay registered.
+	// @Tags workers
+	// @Accept json
+	// @Produce json
+	// @Param worker body models.RegisterWorkerRequest true "Worker registration request"
+	// @Success 201 {object} models.Worker
+	// @Router /api/v1/workers [post]
+	fuego.Post(group, "/workers", func(c fuego.ContextWithBody[models.RegisterWorkerRequest]) (*models.Worker, error) {
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		worker, err := queueService.RegisterWorker(context.Background(), body.Name, body.QueueID)
+		if errors.Is(err, services.ErrQueueNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Queue not found",
+			}
+		}
+		if err != nil {
+			return nil, workerError(err, "Failed to register worker")
+		}
+
+		return worker, nil
+	})
+
+	// Get specific worker
+	// @Summary Get a worker by ID
+	// @Description Get a specific worker by its ID
+	// @Tags workers
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Worker ID"
+	// @Success 200 {object} models.Worker
+	// @Router /api/v1/workers/{id} [get]
+	fuego.Get(group, "/workers/{id}", func(c fuego.ContextNoBody) (*models.Worker, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid worker ID",
+			}
+		}
+
+		worker, err := queueService.GetWorker(context.Background(), id)
+		if err != nil {
+			return nil, workerError(err, "Failed to get worker")
+		}
+
+		return worker, nil
+	})
+
+	// Worker heartbeat
+	// @Summary Send a worker heartbeat
+	// @Description Report that a worker is alive, with its status and the messages it is processing. A worker silent for longer than the worker timeout is stopped and its messages requeued. A stopped worker gets 409 and must register again.
+	// @Tags workers
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Worker ID"
+	// @Param heartbeat body models.WorkerHeartbeatRequest true "Heartbeat"
+	// @Success 200 {object} models.Worker
+	// @Failure 409 {object} fuego.HTTPError
+	// @Router /api/v1/workers/{id}/heartbeat [post]
+	fuego.Post(group, "/workers/{id}/heartbeat", func(c fuego.ContextWithBody[models.WorkerHeartbeatRequest]) (*models.Worker, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid worker ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		worker, err := queueService.UpdateWorkerPing(context.Background(), id, &body)
+		if err != nil {
+			return nil, workerError(err, "Failed to record heartbeat")
+		}
+
+		return worker, nil
+	})
+
+	// Get worker events
+	// @Summary Get the events of a worker
+	// @Description Get the lifecycle events of a worker, newest first: registration, deregistration, and lapsed heartbeats with the number of in-flight messages requeued
+	// @Tags workers
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Worker ID"
+	// @Param limit query int false "Limit number of results"
+	// @Success 200 {array} models.WorkerEvent
+	// @Router /api/v1/workers/{id}/events [get]
+	fuego.Get(group, "/workers/{id}/events", func(c fuego.ContextNoBody) ([]*models.WorkerEvent, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid worker ID",
+			}
+		}
+
+		limit := 50
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid limit parameter",
+				}
+			}
+		}
+
+		events, err := queueService.GetWorkerEvents(context.Background(), id, limit)
+		if err != nil {
+			return nil, workerError(err, "Failed to get worker events")
+		}
+
+		return events, nil
+	})
+
+	// Deregister worker
+	// @Summary Deregister a worker
+	// @Description Mark a worker as stopped when it shuts down gracefully. Messages it still holds go back to their queue without counting a retry.
+	// @Tags workers
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Worker ID"
+	// @Success 200 {object} models.Worker
+	// @Router /api/v1/workers/{id} [delete]
+	fuego.Delete(group, "/workers/{id}", func(c fuego.ContextNoBody) (*models.Worker, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid worker ID",
+			}
+		}
+
+		worker, err := queueService.DeregisterWorker(context.Background(), id)
+		if err != nil {
+			return nil, workerError(err, "Failed to deregister worker")
+		}
+
+		return worker, nil
+	})
+}
+
+// workerError maps worker errors to HTTP errors
+func workerError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrWorkerNotFound):
+		return fuego.HTTPError{
+			StatusCode: http.StatusNotFound,
+			Message:    "Worker not found",
+		}
+	case errors.Is(err, services.ErrWorkerStopped):
+		return fuego.HTTPError{
+			StatusCode: http.StatusConflict,
+			Message:    err.Error(),
+		}
+	case errors.Is(err, services.ErrInvalidWorker):
+		return fuego.HTTPError{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+	default:
+		return fuego.HTTPError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    fallback,
+		}
+	}
+}
+
+func setupOperationRoutes(group *fuego.Group, queueService *services.QueueService) {
+	// Get bulk operation
+	// @Summary Get a bulk operation
+	// @Description Report the progress of a purge or bulk message operation started on this server instance
+	// @Tags operations
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Operation ID"
+	// @Success 200 {object} models.BulkOperation
+	// @Router /api/v1/operations/{id} [get]
+	fuego.Get(group, "/operations/{id}", func(c fuego.ContextNoBody) (*models.BulkOperation, error) {
+		op, err := queueService.GetBulkOperation(context.Background(), c.PathParam("id"))
+		if errors.Is(err, services.ErrOperationNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Operation not found",
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to get operation",
+			}
+		}
+
+		return op, nil
+	})
+
+	// Cancel bulk operation
+	// @Summary Cancel a bulk operation
+	// @Description Stop a running bulk operation after its current chunk. Chunks already committed stay applied.
+	// @Tags operations
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Operation ID"
+	// @Success 200 {object} models.BulkOperation
+	// @Router /api/v1/operations/{id}/cancel [post]
+	fuego.Post(group, "/operations/{id}/cancel", func(c fuego.ContextWithBody[any]) (*models.BulkOperation, error) {
+		op, err := queueService.CancelBulkOperation(context.Background(), c.PathParam("id"))
+		if errors.Is(err, services.ErrOperationNotFound) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Operation not found",
+			}
+		}
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to cancel operation",
+			}
+		}
+
+		return op, nil
+	})
+}
+
+func setupScheduleRoutes(group *fuego.Group, scheduleService *services.ScheduleService) {
+	// Get all schedules
+	// @Summary Get all schedules
+	// @Description Get a list of all cron schedules
+	// @Tags schedules
+	// @Accept json
+	// @Produce json
+	// @Success 200 {array} models.Schedule
+	// @Router /api/v1/schedules [get]
+	fuego.Get(group, "/schedules", func(c fuego.ContextNoBody) ([]*models.Schedule, error) {
+		schedules, err := scheduleService.GetSchedules(context.Background())
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "Failed to get schedules",
+			}
+		}
+
+		return schedules, nil
+	})
+
+	// Create schedule
+	// @Summary Create a new schedule
+	// @Description Enqueue a message rendered from payload_template to a queue on every tick of a cron expression. The template can use {{.Schedule}}, {{.ScheduledFor}} and {{.Unix}}. catch_up decides what happens to ticks missed while no scheduler was running: skip drops them, latest runs the most recent one and all runs every one.
+	// @Tags schedules
+	// @Accept json
+	// @Produce json
+	// @Param schedule body models.CreateScheduleRequest true "Schedule creation request"
+	// @Success 201 {object} models.Schedule
+	// @Router /api/v1/schedules [post]
+	fuego.Post(group, "/schedules", func(c fuego.ContextWithBody[models.CreateScheduleRequest]) (*models.Schedule, error) {
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		schedule, err := scheduleService.CreateSchedule(context.Background(), &body)
+		if errors.Is(err, services.ErrScheduleExists) {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusConflict,
+				Message:    err.Error(),
+			}
+		}
+		if err != nil {
+			return nil, scheduleError(err, "Failed to create schedule")
+		}
+
+		return schedule, nil
+	})
+
+	// Get specific schedule
+	// @Summary Get a schedule by ID
+	// @Description Get a specific schedule by its ID
+	// @Tags schedules
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Schedule ID"
+	// @Success 200 {object} models.Schedule
+	// @Router /api/v1/schedules/{id} [get]
+	fuego.Get(group, "/schedules/{id}", func(c fuego.ContextNoBody) (*models.Schedule, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid schedule ID",
+			}
+		}
+
+		schedule, err := scheduleService.GetSchedule(context.Background(), id)
+		if err != nil {
+			return nil, scheduleError(err, "Failed to get schedule")
+		}
+
+		return schedule, nil
+	})
+
+	// Update schedule
+	// @Summary Update a schedule
+	// @Description Change the fields of a schedule. Changing its cron expression or timezone, or enabling it, moves its next run to the next tick from now.
+	// @Tags schedules
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Schedule ID"
+	// @Param schedule body models.UpdateScheduleRequest true "Fields to change"
+	// @Success 200 {object} models.Schedule
+	// @Router /api/v1/schedules/{id} [patch]
+	fuego.Patch(group, "/schedules/{id}", func(c fuego.ContextWithBody[models.UpdateScheduleRequest]) (*models.Schedule, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid schedule ID",
+			}
+		}
+
+		body, err := c.Body()
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid request body",
+			}
+		}
+
+		schedule, err := scheduleService.UpdateSchedule(context.Background(), id, &body)
+		if err != nil {
+			return nil, scheduleError(err, "Failed to update schedule")
+		}
+
+		return schedule, nil
+	})
+
+	// Delete schedule
+	// @Summary Delete a schedule
+	// @Description Delete a schedule and its run history. Messages it already enqueued are kept.
+	// @Tags schedules
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Schedule ID"
+	// @Success 200 {object} models.Schedule
+	// @Router /api/v1/schedules/{id} [delete]
+	fuego.Delete(group, "/schedules/{id}", func(c fuego.ContextNoBody) (*models.Schedule, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid schedule ID",
+			}
+		}
+
+		schedule, err := scheduleService.DeleteSchedule(context.Background(), id)
+		if err != nil {
+			return nil, scheduleError(err, "Failed to delete schedule")
+		}
+
+		return schedule, nil
+	})
+
+	// Get schedule runs
+	// @Summary Get the runs of a schedule
+	// @Description Get the run history of a schedule, newest first: when each tick ran, the message it enqueued or why it failed
+	// @Tags schedules
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Schedule ID"
+	// @Param limit query int false "Limit number of results"
+	// @Success 200 {array} models.ScheduleRun
+	// @Router /api/v1/schedules/{id}/runs [get]
+	fuego.Get(group, "/schedules/{id}/runs", func(c fuego.ContextNoBody) ([]*models.ScheduleRun, error) {
+		idStr := c.PathParam("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fuego.HTTPError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid schedule ID",
+			}
+		}
+
+		limit := 50
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 {
+				return nil, fuego.HTTPError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid limit parameter",
+				}
+			}
+		}
+
+		runs, err := scheduleService.GetScheduleRuns(context.Background(), id, limit)
+		if err != nil {
+			return nil, scheduleError(err, "Failed to get schedule runs")
+		}
+
+		return runs, nil
+	})
+}
+
+// scheduleError maps schedule errors to HTTP errors
+func scheduleError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		return fuego.HTTPError{
+			StatusCode: http.StatusNotFound,
+			Message:    "Schedule not found",
+		}
+	case errors.Is(err, services.ErrInvalidSchedule):
+		return fuego.HTTPError{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+	default:
+		return fuego.HTTPError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    fallback,
+		}
+	}
 }
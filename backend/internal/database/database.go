@@ -4,13 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/uptrace/bun/extra/bundebug"
-
-	"github.com/shravan20/qafka/internal/models"
 )
 
 func Connect(databaseURL string) (*bun.DB, error) {
@@ -34,43 +33,20 @@ func Connect(databaseURL string) (*bun.DB, error) {
 	return db, nil
 }
 
+// RunMigrations applies every pending migration. See Migrator.
 func RunMigrations(ctx context.Context, db *bun.DB) error {
-	// Create tables
-	models := []interface{}{
-		(*models.Queue)(nil),
-		(*models.Message)(nil),
-		(*models.Worker)(nil),
-	}
-
-	for _, model := range models {
-		_, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to create table for %T: %w", model, err)
-		}
-	}
-
-	// Create indexes for better performance
-	if err := createIndexes(ctx, db); err != nil {
-		return fmt.Errorf("failed to create indexes: %w", err)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func createIndexes(ctx context.Context, db *bun.DB) error {
-	indexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_messages_queue_id ON messages(queue_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_messages_status ON messages(status)`,
-		`CREATE INDEX IF NOT EXISTS idx_messages_scheduled_at ON messages(scheduled_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_messages_priority ON messages(priority DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_workers_queue_id ON workers(queue_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_workers_status ON workers(status)`,
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
 	}
 
-	for _, indexSQL := range indexes {
-		if _, err := db.ExecContext(ctx, indexSQL); err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
-		}
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
 	}
 
 	return nil
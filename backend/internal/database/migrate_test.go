@@ -0,0 +1,133 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shravan20/qafka/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	script := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{name: "empty", files: fstest.MapFS{}, want: []Migration{}},
+		{
+			name: "pairs up and down scripts in version order",
+			files: fstest.MapFS{
+				"0002_add_index.down.sql": script("down 2"),
+				"0001_initial.up.sql":     script("up 1"),
+				"0002_add_index.up.sql":   script("up 2"),
+				"0001_initial.down.sql":   script("down 1"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "initial", Up: "up 1", Down: "down 1"},
+				{Version: 2, Name: "add_index", Up: "up 2", Down: "down 2"},
+			},
+		},
+		{
+			name: "orders by version, not by name",
+			files: fstest.MapFS{
+				"9_ninth.up.sql": script("up"), "9_ninth.down.sql": script("down"),
+				"10_tenth.up.sql": script("up"), "10_tenth.down.sql": script("down"),
+				"1_a.up.sql": script("up"), "1_a.down.sql": script("down"),
+				"2_a.up.sql": script("up"), "2_a.down.sql": script("down"),
+				"3_a.up.sql": script("up"), "3_a.down.sql": script("down"),
+				"4_a.up.sql": script("up"), "4_a.down.sql": script("down"),
+				"5_a.up.sql": script("up"), "5_a.down.sql": script("down"),
+				"6_a.up.sql": script("up"), "6_a.down.sql": script("down"),
+				"7_a.up.sql": script("up"), "7_a.down.sql": script("down"),
+				"8_a.up.sql": script("up"), "8_a.down.sql": script("down"),
+			},
+		},
+		{
+			name: "ignores other files",
+			files: fstest.MapFS{
+				"0001_initial.up.sql":   script("up"),
+				"0001_initial.down.sql": script("down"),
+				"migrations.go":         script("package migrations"),
+				"README.md":             script("docs"),
+			},
+			want: []Migration{{Version: 1, Name: "initial", Up: "up", Down: "down"}},
+		},
+		{
+			name:    "up without down",
+			files:   fstest.MapFS{"0001_initial.up.sql": script("up")},
+			wantErr: true,
+		},
+		{
+			name:    "down without up",
+			files:   fstest.MapFS{"0001_initial.down.sql": script("down")},
+			wantErr: true,
+		},
+		{
+			name: "one version with two names",
+			files: fstest.MapFS{
+				"0001_initial.up.sql": script("up"),
+				"0001_other.down.sql": script("down"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "gap between versions",
+			files: fstest.MapFS{
+				"0001_initial.up.sql":   script("up"),
+				"0001_initial.down.sql": script("down"),
+				"0003_third.up.sql":     script("up"),
+				"0003_third.down.sql":   script("down"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "not starting at one",
+			files: fstest.MapFS{
+				"0002_second.up.sql":   script("up"),
+				"0002_second.down.sql": script("down"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadMigrations(tt.files)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadMigrations() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMigrations() error = %v", err)
+			}
+
+			if tt.want != nil {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("LoadMigrations() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			for i, m := range got {
+				if m.Version != int64(i+1) {
+					t.Fatalf("LoadMigrations()[%d] has version %d, want %d", i, m.Version, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestShippedMigrations(t *testing.T) {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("LoadMigrations(migrations.FS) error = %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("LoadMigrations(migrations.FS) found no migrations")
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -10,52 +11,213 @@ import (
 type Queue struct {
 	bun.BaseModel `bun:"table:queues"`
 
-	ID          int64     `bun:"id,pk,autoincrement" json:"id"`
-	Name        string    `bun:"name,notnull,unique" json:"name"`
-	Description string    `bun:"description" json:"description"`
-	Type        string    `bun:"type,notnull" json:"type"`        // fifo, priority, delay, etc.
-	Config      string    `bun:"config,type:jsonb" json:"config"` // JSON configuration
-	IsActive    bool      `bun:"is_active,notnull,default:true" json:"is_active"`
-	CreatedAt   time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt   time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	ID                int64      `bun:"id,pk,autoincrement" json:"id"`
+	Name              string     `bun:"name,notnull,unique" json:"name"`
+	Description       string     `bun:"description" json:"description"`
+	Type              string     `bun:"type,notnull" json:"type"`                                    // fifo, lifo, priority or delay
+	Config            string     `bun:"config,type:jsonb" json:"config"`                             // JSON configuration
+	IsActive          bool       `bun:"is_active,notnull,default:true" json:"is_active"`             // true while the queue is in the active state
+	State             string     `bun:"state,notnull,default:'active'" json:"state"`                 // active, paused or draining
+	DeadLetterQueueID *int64     `bun:"dead_letter_queue_id" json:"dead_letter_queue_id,omitempty"`  // queue receiving messages that exhaust their retries
+	Version           int64      `bun:"version,notnull,default:1" json:"version"`                    // incremented by every update, served as the ETag
+	DeleteMode        string     `bun:"delete_mode,nullzero" json:"delete_mode,omitempty"`           // how the messages of a deleted queue are disposed of
+	ManagedBy         string     `bun:"managed_by,nullzero" json:"managed_by,omitempty"`             // manager that declared the queue with apply, empty for unmanaged queues
+	DeletedAt         *time.Time `bun:"deleted_at,soft_delete,nullzero" json:"deleted_at,omitempty"` // set while a deleted queue can still be restored
+	CreatedAt         time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt         time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
 
+// Queue states
+const (
+	QueueStateActive   = "active"   // accepts produces and hands out claims
+	QueueStatePaused   = "paused"   // accepts produces, hands out no claims
+	QueueStateDraining = "draining" // rejects produces, hands out claims until empty
+)
+
+// Queue delete modes
+const (
+	QueueDeleteRefuse  = "refuse"  // only delete a queue holding no messages
+	QueueDeleteCascade = "cascade" // delete the queue's messages with it
+	QueueDeleteArchive = "archive" // move the queue's messages to archived_messages first
+)
+
 // Message represents a message in a queue
 type Message struct {
 	bun.BaseModel `bun:"table:messages"`
 
-	ID           int64      `bun:"id,pk,autoincrement" json:"id"`
-	QueueID      int64      `bun:"queue_id,notnull" json:"queue_id"`
-	Queue        *Queue     `bun:"rel:belongs-to,join:queue_id=id" json:"queue,omitempty"`
-	Payload      string     `bun:"payload,notnull" json:"payload"`
-	Priority     int        `bun:"priority,notnull,default:0" json:"priority"`
-	Status       string     `bun:"status,notnull,default:'pending'" json:"status"` // pending, processing, completed, failed
-	ScheduledAt  *time.Time `bun:"scheduled_at" json:"scheduled_at,omitempty"`
-	ProcessedAt  *time.Time `bun:"processed_at" json:"processed_at,omitempty"`
-	FailedAt     *time.Time `bun:"failed_at" json:"failed_at,omitempty"`
-	RetryCount   int        `bun:"retry_count,notnull,default:0" json:"retry_count"`
-	MaxRetries   int        `bun:"max_retries,notnull,default:3" json:"max_retries"`
-	ErrorMessage string     `bun:"error_message" json:"error_message,omitempty"`
-	CreatedAt    time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt    time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	ID              int64             `bun:"id,pk,autoincrement" json:"id"`
+	QueueID         int64             `bun:"queue_id,notnull" json:"queue_id"`
+	Queue           *Queue            `bun:"rel:belongs-to,join:queue_id=id" json:"queue,omitempty"`
+	Payload         string            `bun:"payload,notnull" json:"payload"`
+	Priority        int               `bun:"priority,notnull,default:0" json:"priority"`
+	Status          string            `bun:"status,notnull,default:'pending'" json:"status"` // scheduled, pending, processing, completed, failed or dead_lettered
+	ScheduledAt     *time.Time        `bun:"scheduled_at" json:"scheduled_at,omitempty"`
+	ProcessedAt     *time.Time        `bun:"processed_at" json:"processed_at,omitempty"`
+	FailedAt        *time.Time        `bun:"failed_at" json:"failed_at,omitempty"`
+	RetryCount      int               `bun:"retry_count,notnull,default:0" json:"retry_count"`
+	MaxRetries      int               `bun:"max_retries,notnull,default:3" json:"max_retries"`
+	ErrorMessage    string            `bun:"error_message" json:"error_message,omitempty"`
+	WorkerID        *int64            `bun:"worker_id" json:"worker_id,omitempty"` // worker currently holding the message
+	ClaimedAt       *time.Time        `bun:"claimed_at" json:"claimed_at,omitempty"`
+	LeaseToken      string            `bun:"lease_token,nullzero" json:"-"` // proves ownership of the current claim, only revealed to its claimant
+	LeaseExpiresAt  *time.Time        `bun:"lease_expires_at" json:"lease_expires_at,omitempty"`
+	OriginalQueueID *int64            `bun:"original_queue_id" json:"original_queue_id,omitempty"` // source queue of a dead-lettered message
+	DeadLetteredAt  *time.Time        `bun:"dead_lettered_at" json:"dead_lettered_at,omitempty"`
+	ErrorHistory    []MessageError    `bun:"error_history,type:jsonb,nullzero" json:"error_history,omitempty"`
+	Headers         map[string]string `bun:"headers,type:jsonb" json:"headers,omitempty"`   // attributes consumers can select on
+	DedupID         string            `bun:"dedup_id,nullzero" json:"dedup_id,omitempty"`   // producer-chosen key deduplicating retried produces
+	GroupKey        string            `bun:"group_key,nullzero" json:"group_key,omitempty"` // messages of a group are delivered one at a time, in order
+	CreatedAt       time.Time         `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt       time.Time         `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// ClaimedMessage is a message handed out by a claim, with the lease token its
+// claimant settles it with. No other response reveals the token.
+type ClaimedMessage struct {
+	*Message
+	LeaseToken string `json:"lease_token"`
+}
+
+// NewClaimedMessages pairs claimed messages with their lease tokens
+func NewClaimedMessages(messages []*Message) []*ClaimedMessage {
+	claimed := make([]*ClaimedMessage, len(messages))
+	for i, message := range messages {
+		claimed[i] = &ClaimedMessage{Message: message, LeaseToken: message.LeaseToken}
+	}
+	return claimed
+}
+
+// Message statuses
+const (
+	MessageStatusScheduled    = "scheduled" // waiting for its scheduled_at, promoted to pending by the scheduler
+	MessageStatusPending      = "pending"
+	MessageStatusProcessing   = "processing"
+	MessageStatusCompleted    = "completed"
+	MessageStatusFailed       = "failed"
+	MessageStatusDeadLettered = "dead_lettered"
+)
+
+// MessageError records one failed delivery attempt of a message
+type MessageError struct {
+	Attempt  int       `json:"attempt"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// ArchivedMessage is a message of a queue deleted in archive mode, kept as it was when the queue was purged
+type ArchivedMessage struct {
+	bun.BaseModel `bun:"table:archived_messages"`
+
+	ID         int64           `bun:"id,pk" json:"id"`
+	QueueID    int64           `bun:"queue_id,notnull" json:"queue_id"`
+	QueueName  string          `bun:"queue_name,notnull" json:"queue_name"`
+	Message    json.RawMessage `bun:"message,type:jsonb,notnull" json:"message"`
+	ArchivedAt time.Time       `bun:"archived_at,nullzero,notnull,default:current_timestamp" json:"archived_at"`
+}
+
+// MessageDedup reserves a deduplication key of a queue until ExpiresAt
+type MessageDedup struct {
+	bun.BaseModel `bun:"table:message_dedup"`
+
+	QueueID   int64     `bun:"queue_id,pk"`
+	DedupID   string    `bun:"dedup_id,pk"`
+	MessageID *int64    `bun:"message_id"` // message stored under the key
+	ExpiresAt time.Time `bun:"expires_at,notnull"`
 }
 
 // Worker represents a queue worker/consumer
 type Worker struct {
 	bun.BaseModel `bun:"table:workers"`
 
-	ID             int64     `bun:"id,pk,autoincrement" json:"id"`
-	Name           string    `bun:"name,notnull" json:"name"`
-	QueueID        int64     `bun:"queue_id,notnull" json:"queue_id"`
-	Queue          *Queue    `bun:"rel:belongs-to,join:queue_id=id" json:"queue,omitempty"`
-	Status         string    `bun:"status,notnull,default:'idle'" json:"status"` // idle, busy, stopped
-	LastPing       time.Time `bun:"last_ping,nullzero,notnull,default:current_timestamp" json:"last_ping"`
-	ProcessedCount int64     `bun:"processed_count,notnull,default:0" json:"processed_count"`
-	FailedCount    int64     `bun:"failed_count,notnull,default:0" json:"failed_count"`
-	CreatedAt      time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt      time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	ID                int64     `bun:"id,pk,autoincrement" json:"id"`
+	Name              string    `bun:"name,notnull" json:"name"`
+	QueueID           int64     `bun:"queue_id,notnull" json:"queue_id"`
+	Queue             *Queue    `bun:"rel:belongs-to,join:queue_id=id" json:"queue,omitempty"`
+	Status            string    `bun:"status,notnull,default:'idle'" json:"status"` // idle, busy, stopped
+	LastPing          time.Time `bun:"last_ping,nullzero,notnull,default:current_timestamp" json:"last_ping"`
+	CurrentMessageIDs []int64   `bun:"current_message_ids,type:jsonb" json:"current_message_ids,omitempty"` // messages in hand at the last heartbeat
+	ProcessedCount    int64     `bun:"processed_count,notnull,default:0" json:"processed_count"`
+	FailedCount       int64     `bun:"failed_count,notnull,default:0" json:"failed_count"`
+	CreatedAt         time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt         time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Worker statuses
+const (
+	WorkerStatusIdle    = "idle"
+	WorkerStatusBusy    = "busy"
+	WorkerStatusStopped = "stopped" // deregistered; a stopped worker must register again
+)
+
+// WorkerEvent records a change in the lifecycle of a worker
+type WorkerEvent struct {
+	bun.BaseModel `bun:"table:worker_events"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`
+	WorkerID  int64     `bun:"worker_id,notnull" json:"worker_id"`
+	Type      string    `bun:"type,notnull" json:"type"`
+	Detail    string    `bun:"detail,nullzero" json:"detail,omitempty"`
+	Messages  int64     `bun:"messages,notnull,default:0" json:"messages"` // in-flight messages released or requeued
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 }
 
+// Worker event types
+const (
+	WorkerEventRegistered   = "registered"
+	WorkerEventDeregistered = "deregistered" // stopped gracefully, its messages released
+	WorkerEventLapsed       = "lapsed"       // stopped by the supervisor, its messages requeued as failed deliveries
+)
+
+// Schedule enqueues a message to a queue on every tick of a cron expression
+type Schedule struct {
+	bun.BaseModel `bun:"table:schedules"`
+
+	ID              int64             `bun:"id,pk,autoincrement" json:"id"`
+	Name            string            `bun:"name,notnull,unique" json:"name"`
+	Description     string            `bun:"description" json:"description"`
+	CronExpr        string            `bun:"cron_expr,notnull" json:"cron_expr"`             // standard five-field expression or descriptor such as @hourly
+	Timezone        string            `bun:"timezone,notnull,default:'UTC'" json:"timezone"` // IANA zone the expression is evaluated in
+	QueueID         int64             `bun:"queue_id,notnull" json:"queue_id"`
+	Queue           *Queue            `bun:"rel:belongs-to,join:queue_id=id" json:"queue,omitempty"`
+	PayloadTemplate string            `bun:"payload_template,notnull" json:"payload_template"` // text/template rendered for every run
+	Headers         map[string]string `bun:"headers,type:jsonb" json:"headers,omitempty"`
+	Priority        int               `bun:"priority,notnull,default:0" json:"priority"`
+	CatchUp         string            `bun:"catch_up,notnull,default:'latest'" json:"catch_up"` // what happens to runs missed while no scheduler was running
+	Enabled         bool              `bun:"enabled,notnull,default:true" json:"enabled"`
+	NextRunAt       *time.Time        `bun:"next_run_at" json:"next_run_at,omitempty"` // unset while disabled
+	LastRunAt       *time.Time        `bun:"last_run_at" json:"last_run_at,omitempty"`
+	CreatedAt       time.Time         `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt       time.Time         `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Schedule catch-up policies
+const (
+	ScheduleCatchUpSkip   = "skip"   // drop missed runs, run again at the next tick
+	ScheduleCatchUpLatest = "latest" // run once for the most recent missed tick
+	ScheduleCatchUpAll    = "all"    // run once for every missed tick, up to a limit
+)
+
+// ScheduleRun records one tick of a schedule
+type ScheduleRun struct {
+	bun.BaseModel `bun:"table:schedule_runs"`
+
+	ID           int64      `bun:"id,pk,autoincrement" json:"id"`
+	ScheduleID   int64      `bun:"schedule_id,notnull" json:"schedule_id"`
+	ScheduledFor time.Time  `bun:"scheduled_for,notnull" json:"scheduled_for"` // tick the run belongs to
+	Status       string     `bun:"status,notnull" json:"status"`               // running, enqueued or failed
+	MessageID    *int64     `bun:"message_id" json:"message_id,omitempty"`     // message enqueued by the run
+	Error        string     `bun:"error,nullzero" json:"error,omitempty"`
+	StartedAt    time.Time  `bun:"started_at,nullzero,notnull,default:current_timestamp" json:"started_at"`
+	FinishedAt   *time.Time `bun:"finished_at" json:"finished_at,omitempty"`
+}
+
+// Schedule run statuses
+const (
+	ScheduleRunRunning  = "running"
+	ScheduleRunEnqueued = "enqueued"
+	ScheduleRunFailed   = "failed"
+)
+
 // CreateQueueRequest represents the request to create a new queue
 type CreateQueueRequest struct {
 	Name        string `json:"name" validate:"required"`
@@ -64,11 +226,234 @@ type CreateQueueRequest struct {
 	Config      string `json:"config"`
 }
 
+// UpdateQueueRequest represents a partial update of a queue. Omitted fields are left unchanged.
+type UpdateQueueRequest struct {
+	Description *string `json:"description"`
+	Config      *string `json:"config"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+// QueueSpec declares the desired state of a queue in an ApplyQueuesRequest
+type QueueSpec struct {
+	Name            string          `json:"name" validate:"required"`
+	Description     string          `json:"description"`
+	Type            string          `json:"type" validate:"required"`
+	Config          json.RawMessage `json:"config"`            // config object, as accepted by CreateQueueRequest
+	DeadLetterQueue string          `json:"dead_letter_queue"` // shorthand for config.dead_letter_queue
+	RetryPolicy     json.RawMessage `json:"retry_policy"`      // shorthand for config.retry_policy
+}
+
+// ApplyQueuesRequest declares every queue owned by a manager. Applying it creates the
+// queues missing from the queues table and updates those that differ; with Prune it
+// also deletes the manager's queues the request no longer declares.
+type ApplyQueuesRequest struct {
+	Manager    string      `json:"manager"` // owner recorded on the queues, defaults to "default"
+	Queues     []QueueSpec `json:"queues"`
+	Prune      bool        `json:"prune"`       // delete queues of the manager that are not declared
+	Adopt      bool        `json:"adopt"`       // take over existing unmanaged queues that are declared
+	DeleteMode string      `json:"delete_mode"` // how pruned queues are deleted, defaults to refuse
+}
+
+// ApplyPlan lists the changes that bring the queues in line with an ApplyQueuesRequest
+type ApplyPlan struct {
+	Manager string        `json:"manager"`
+	DryRun  bool          `json:"dry_run"` // true when the changes were only planned
+	Changes []ApplyChange `json:"changes"`
+}
+
+// ApplyChange is what apply does to one queue
+type ApplyChange struct {
+	Action  string        `json:"action"` // create, update, delete or unchanged
+	Name    string        `json:"name"`
+	QueueID *int64        `json:"queue_id,omitempty"` // unset for queues still to be created
+	Fields  []FieldChange `json:"fields,omitempty"`   // fields an update changes
+}
+
+// FieldChange is a field an update changes, with its current and desired values
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Apply actions
+const (
+	ApplyCreate    = "create"
+	ApplyUpdate    = "update"
+	ApplyDelete    = "delete"
+	ApplyUnchanged = "unchanged"
+)
+
 // CreateMessageRequest represents the request to create a new message
 type CreateMessageRequest struct {
-	QueueID     int64      `json:"queue_id" validate:"required"`
-	Payload     string     `json:"payload" validate:"required"`
-	Priority    int        `json:"priority"`
-	ScheduledAt *time.Time `json:"scheduled_at"`
-	MaxRetries  int        `json:"max_retries"`
+	QueueID      int64             `json:"queue_id" validate:"required"`
+	Payload      string            `json:"payload" validate:"required"`
+	Priority     int               `json:"priority"`
+	ScheduledAt  *time.Time        `json:"scheduled_at"`
+	DelaySeconds *int              `json:"delay_seconds"` // deliver this long after producing, instead of scheduled_at
+	MaxRetries   *int              `json:"max_retries"`   // unset uses the queue's retry policy; 0 dead-letters on the first failure
+	Headers      map[string]string `json:"headers"`
+	DedupID      string            `json:"dedup_id"`  // repeated produces with the same key within the queue's window return the original message
+	GroupKey     string            `json:"group_key"` // messages sharing a group key are delivered strictly in order, one at a time
+}
+
+// RegisterWorkerRequest represents the request to register a worker consuming a queue
+type RegisterWorkerRequest struct {
+	Name    string `json:"name" validate:"required"`
+	QueueID int64  `json:"queue_id" validate:"required"`
+}
+
+// WorkerHeartbeatRequest represents a worker reporting that it is alive
+type WorkerHeartbeatRequest struct {
+	Status     string  `json:"status"`      // idle or busy, defaults to busy while message_ids is not empty
+	MessageIDs []int64 `json:"message_ids"` // messages the worker is processing
+}
+
+// ClaimMessageRequest represents the request to claim the next message of a queue
+type ClaimMessageRequest struct {
+	WorkerID int64  `json:"worker_id" validate:"required"`
+	Selector string `json:"selector"` // only claim messages whose headers match, e.g. "type=invoice AND region=eu"
+}
+
+// ExtendLeaseRequest represents the request to extend the lease of a claimed message
+type ExtendLeaseRequest struct {
+	LeaseToken   string `json:"lease_token" validate:"required"`
+	LeaseSeconds int    `json:"lease_seconds"` // defaults to the queue's lease duration
+}
+
+// AckMessageRequest represents the request to acknowledge a processed message
+type AckMessageRequest struct {
+	LeaseToken string `json:"lease_token" validate:"required"`
+}
+
+// NackMessageRequest represents the request to report a failed message
+type NackMessageRequest struct {
+	LeaseToken          string `json:"lease_token" validate:"required"`
+	Error               string `json:"error" validate:"required"`
+	RequeueDelaySeconds *int   `json:"requeue_delay_seconds"` // overrides the retry policy's backoff
+}
+
+// ReleaseMessageRequest represents the request to hand a claimed message back to its queue
+type ReleaseMessageRequest struct {
+	LeaseToken string `json:"lease_token" validate:"required"`
+}
+
+// RedriveRequest represents the request to move dead-lettered messages back to their source queue.
+// All filters are optional; without any, every dead-lettered message of the queue is redriven.
+type RedriveRequest struct {
+	MessageIDs    []int64 `json:"message_ids"`
+	ErrorContains string  `json:"error_contains"`  // case-insensitive match on the last error
+	MinAgeSeconds int     `json:"min_age_seconds"` // only messages dead-lettered at least this long ago
+	MaxAgeSeconds int     `json:"max_age_seconds"` // only messages dead-lettered at most this long ago
+}
+
+// RedriveResponse reports the outcome of a redrive
+type RedriveResponse struct {
+	Redriven int64 `json:"redriven"`
+}
+
+// BatchCreateMessagesRequest represents the request to produce several messages to a queue at once
+type BatchCreateMessagesRequest struct {
+	Messages []CreateMessageRequest `json:"messages" validate:"required"`
+}
+
+// BatchMessageResult reports the outcome of one item of a batch produce
+type BatchMessageResult struct {
+	Index   int      `json:"index"`
+	Message *Message `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// BatchCreateMessagesResponse represents the per-item results of a batch produce
+type BatchCreateMessagesResponse struct {
+	Results []BatchMessageResult `json:"results"`
+}
+
+// BatchClaimRequest represents the request to claim several messages at once
+type BatchClaimRequest struct {
+	WorkerID int64  `json:"worker_id" validate:"required"`
+	Max      int    `json:"max"` // defaults to 1
+	Selector string `json:"selector"`
+}
+
+// PurgeQueueRequest represents the request to delete messages of a queue.
+// All filters are optional; without any, every message of the queue is deleted.
+type PurgeQueueRequest struct {
+	Statuses      []string `json:"statuses"`
+	MinAgeSeconds int      `json:"min_age_seconds"` // only messages created at least this long ago
+	MaxAgeSeconds int      `json:"max_age_seconds"` // only messages created at most this long ago
+}
+
+// MessageFilterRequest selects the messages of a queue changed by a bulk operation.
+// All filters are optional; without any, every message of the queue is selected.
+type MessageFilterRequest struct {
+	Statuses      []string `json:"statuses"`
+	MessageIDs    []int64  `json:"message_ids"`
+	Selector      string   `json:"selector"`        // header selector, e.g. "tenant=acme"
+	MinAgeSeconds int      `json:"min_age_seconds"` // only messages created at least this long ago
+	MaxAgeSeconds int      `json:"max_age_seconds"` // only messages created at most this long ago
+}
+
+// ReprioritizeMessagesRequest represents the request to change the priority of the messages matching a filter
+type ReprioritizeMessagesRequest struct {
+	MessageFilterRequest
+	Priority *int `json:"priority" validate:"required"`
+}
+
+// BulkOperation reports the progress of a purge or bulk message operation. Operations
+// run in the background in chunks; poll the operation until its status is no longer running.
+type BulkOperation struct {
+	ID         string     `json:"id"`
+	QueueID    int64      `json:"queue_id"`
+	Action     string     `json:"action"`
+	Status     string     `json:"status"`
+	Processed  int64      `json:"processed"`           // messages changed so far
+	Chunks     int        `json:"chunks"`              // chunks committed so far
+	Remaining  int64      `json:"remaining,omitempty"` // matching messages left locked by other transactions, when partial
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Bulk operation actions
+const (
+	BulkActionDelete       = "delete"
+	BulkActionRequeue      = "requeue"
+	BulkActionReprioritize = "reprioritize"
+)
+
+// Bulk operation statuses
+const (
+	BulkOperationRunning   = "running"
+	BulkOperationCompleted = "completed"
+	BulkOperationFailed    = "failed"
+	BulkOperationCancelled = "cancelled"
+	BulkOperationPartial   = "partial" // gave up on matching messages locked by other transactions
+)
+
+// CreateScheduleRequest represents the request to create a new schedule
+type CreateScheduleRequest struct {
+	Name            string            `json:"name" validate:"required"`
+	Description     string            `json:"description"`
+	CronExpr        string            `json:"cron_expr" validate:"required"`
+	Timezone        string            `json:"timezone"` // defaults to UTC
+	QueueID         int64             `json:"queue_id" validate:"required"`
+	PayloadTemplate string            `json:"payload_template" validate:"required"`
+	Headers         map[string]string `json:"headers"`
+	Priority        int               `json:"priority"`
+	CatchUp         string            `json:"catch_up"` // skip, latest or all, defaults to latest
+	Enabled         *bool             `json:"enabled"`  // defaults to true
+}
+
+// UpdateScheduleRequest represents a partial update of a schedule. Omitted fields are left unchanged.
+type UpdateScheduleRequest struct {
+	Description     *string           `json:"description"`
+	CronExpr        *string           `json:"cron_expr"`
+	Timezone        *string           `json:"timezone"`
+	QueueID         *int64            `json:"queue_id"`
+	PayloadTemplate *string           `json:"payload_template"`
+	Headers         map[string]string `json:"headers"`
+	Priority        *int              `json:"priority"`
+	CatchUp         *string           `json:"catch_up"`
+	Enabled         *bool             `json:"enabled"`
 }
@@ -0,0 +1,203 @@
+package models
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/shravan20/qafka/internal/retry"
+)
+
+// DefaultDedupWindow is how long a deduplication key is remembered when a queue does not configure it
+const DefaultDedupWindow = 5 * time.Minute
+
+// QueueConfigSchema is the JSON Schema every queue configuration must satisfy
+//
+//go:embed queue_config.schema.json
+var QueueConfigSchema string
+
+var queueConfigSchema = jsonschema.MustCompileString("queue_config.schema.json", QueueConfigSchema)
+
+// QueueConfig is the typed form of the JSON stored in Queue.Config
+type QueueConfig struct {
+	LeaseSeconds        int           `json:"lease_seconds,omitempty"`          // visibility timeout of a claimed message
+	RetentionSeconds    int           `json:"retention_seconds,omitempty"`      // how long finished messages are kept, forever when zero
+	MaxMessageSizeBytes int           `json:"max_message_size_bytes,omitempty"` // largest accepted payload, unbounded when zero
+	MaxDepth            int           `json:"max_depth,omitempty"`              // most messages pending or in flight, unbounded when zero
+	RetryPolicy         *retry.Policy `json:"retry_policy,omitempty"`
+	DeadLetterQueue     string        `json:"dead_letter_queue,omitempty"`     // name of the DLQ, defaults to "<name>-dlq"
+	DedupWindowSeconds  int           `json:"dedup_window_seconds,omitempty"`  // how long a dedup_id is remembered
+	DefaultDelaySeconds int           `json:"default_delay_seconds,omitempty"` // delay applied to messages produced without a schedule
+	RateLimit           *RateLimit    `json:"rate_limit,omitempty"`
+}
+
+// RateLimit bounds how fast messages enter and leave a queue. Zero rates are unlimited.
+type RateLimit struct {
+	ProducePerSecond float64 `json:"produce_per_second,omitempty"`
+	ConsumePerSecond float64 `json:"consume_per_second,omitempty"`
+	Burst            int     `json:"burst,omitempty"` // defaults to one second's worth of messages
+}
+
+// FieldError describes why one field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"` // dotted path of the field, empty for the document as a whole
+	Message string `json:"message"`
+}
+
+// ConfigError lists every problem found in a queue configuration
+type ConfigError struct {
+	Errors []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	problems := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		if fe.Field == "" {
+			problems[i] = fe.Message
+		} else {
+			problems[i] = fe.Field + ": " + fe.Message
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// ParseQueueConfig decodes a queue's JSON configuration. An empty string yields the zero config.
+func ParseQueueConfig(raw string) (*QueueConfig, error) {
+	cfg := &QueueConfig{}
+	if raw == "" {
+		return cfg, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), cfg); err != nil {
+		return nil, fmt.Errorf("invalid queue config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// ValidateQueueConfig checks the JSON configuration of the queue with the given name
+// against QueueConfigSchema and decodes it. Problems are reported as a *ConfigError.
+// An empty string is the zero config.
+func ValidateQueueConfig(queueName, raw string) (*QueueConfig, error) {
+	if raw == "" {
+		return &QueueConfig{}, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, &ConfigError{Errors: []FieldError{{Message: "not valid JSON: " + err.Error()}}}
+	}
+
+	var ve *jsonschema.ValidationError
+	if err := queueConfigSchema.Validate(doc); errors.As(err, &ve) {
+		return nil, &ConfigError{Errors: fieldErrors(ve, nil)}
+	} else if err != nil {
+		return nil, err
+	}
+
+	cfg := &QueueConfig{}
+	if err := json.Unmarshal([]byte(raw), cfg); err != nil {
+		return nil, &ConfigError{Errors: []FieldError{{Message: err.Error()}}}
+	}
+
+	// The schema covers these checks too; this keeps the Go types honest
+	if err := cfg.Validate(); err != nil {
+		return nil, &ConfigError{Errors: []FieldError{{Message: err.Error()}}}
+	}
+
+	if cfg.DeadLetterQueue == queueName {
+		return nil, &ConfigError{Errors: []FieldError{{Field: "dead_letter_queue", Message: "a queue cannot be its own dead letter queue"}}}
+	}
+
+	return cfg, nil
+}
+
+// fieldErrors flattens a schema validation error into one FieldError per failing keyword
+func fieldErrors(ve *jsonschema.ValidationError, out []FieldError) []FieldError {
+	if len(ve.Causes) == 0 {
+		field := strings.ReplaceAll(strings.TrimPrefix(ve.InstanceLocation, "/"), "/", ".")
+		return append(out, FieldError{Field: field, Message: ve.Message})
+	}
+	for _, cause := range ve.Causes {
+		out = fieldErrors(cause, out)
+	}
+	return out
+}
+
+// LeaseDuration returns the configured lease, or fallback when none is set
+func (c *QueueConfig) LeaseDuration(fallback time.Duration) time.Duration {
+	if c.LeaseSeconds > 0 {
+		return time.Duration(c.LeaseSeconds) * time.Second
+	}
+	return fallback
+}
+
+// DedupWindow returns how long a deduplication key of the queue is remembered
+func (c *QueueConfig) DedupWindow() time.Duration {
+	if c.DedupWindowSeconds > 0 {
+		return time.Duration(c.DedupWindowSeconds) * time.Second
+	}
+	return DefaultDedupWindow
+}
+
+// Validate reports the first problem with the configuration, if any
+func (c *QueueConfig) Validate() error {
+	if c.LeaseSeconds < 0 {
+		return errors.New("lease_seconds must not be negative")
+	}
+
+	if c.DefaultDelaySeconds < 0 {
+		return errors.New("default_delay_seconds must not be negative")
+	}
+
+	if c.DedupWindowSeconds < 0 {
+		return errors.New("dedup_window_seconds must not be negative")
+	}
+
+	if c.RetentionSeconds < 0 || c.MaxMessageSizeBytes < 0 || c.MaxDepth < 0 {
+		return errors.New("retention_seconds, max_message_size_bytes and max_depth must not be negative")
+	}
+
+	if c.RateLimit != nil {
+		if c.RateLimit.ProducePerSecond < 0 || c.RateLimit.ConsumePerSecond < 0 || c.RateLimit.Burst < 0 {
+			return errors.New("rate_limit: rates and burst must not be negative")
+		}
+	}
+
+	if c.RetryPolicy != nil {
+		if err := c.RetryPolicy.Validate(); err != nil {
+			return fmt.Errorf("retry_policy: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Retention returns how long finished messages are kept, or zero to keep them forever
+func (c *QueueConfig) Retention() time.Duration {
+	return time.Duration(c.RetentionSeconds) * time.Second
+}
+
+// Retry returns the configured retry policy, or the default policy when none is set
+func (c *QueueConfig) Retry() *retry.Policy {
+	if c.RetryPolicy != nil {
+		return c.RetryPolicy
+	}
+	return &retry.DefaultPolicy
+}
+
+// DeadLetterQueueName returns the name of the DLQ linked to the queue with the given name
+func (c *QueueConfig) DeadLetterQueueName(queueName string) string {
+	if c.DeadLetterQueue != "" {
+		return c.DeadLetterQueue
+	}
+	return queueName + "-dlq"
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestValidateQueueConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		queue      string
+		raw        string
+		want       *QueueConfig
+		wantFields []string // sorted fields of the expected FieldErrors, nil when valid
+	}{
+		{name: "empty", queue: "orders", raw: "", want: &QueueConfig{}},
+		{name: "empty object", queue: "orders", raw: "{}", want: &QueueConfig{}},
+		{
+			name:  "valid",
+			queue: "orders",
+			raw:   `{"lease_seconds": 60, "dead_letter_queue": "orders-failed", "max_depth": 10}`,
+			want:  &QueueConfig{LeaseSeconds: 60, DeadLetterQueue: "orders-failed", MaxDepth: 10},
+		},
+		{name: "invalid json", queue: "orders", raw: `{"lease_seconds":`, wantFields: []string{""}},
+		{name: "not an object", queue: "orders", raw: `[]`, wantFields: []string{""}},
+		{name: "unknown field", queue: "orders", raw: `{"colour": "red"}`, wantFields: []string{""}},
+		{name: "negative lease", queue: "orders", raw: `{"lease_seconds": -1}`, wantFields: []string{"lease_seconds"}},
+		{
+			name:       "several problems",
+			queue:      "orders",
+			raw:        `{"lease_seconds": -1, "max_depth": "ten"}`,
+			wantFields: []string{"lease_seconds", "max_depth"},
+		},
+		{
+			name:       "nested field",
+			queue:      "orders",
+			raw:        `{"retry_policy": {"strategy": "fixed", "delay_seconds": 1, "jitter": 2}}`,
+			wantFields: []string{"retry_policy.jitter"},
+		},
+		{name: "own dead letter queue", queue: "orders", raw: `{"dead_letter_queue": "orders"}`, wantFields: []string{"dead_letter_queue"}},
+		{
+			name:  "dead letter queue of another queue",
+			queue: "orders-dlq",
+			raw:   `{"dead_letter_queue": "orders"}`,
+			want:  &QueueConfig{DeadLetterQueue: "orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ValidateQueueConfig(tt.queue, tt.raw)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("ValidateQueueConfig() error = %v", err)
+				}
+				if !reflect.DeepEqual(cfg, tt.want) {
+					t.Fatalf("ValidateQueueConfig() = %+v, want %+v", cfg, tt.want)
+				}
+				return
+			}
+
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("ValidateQueueConfig() error = %v, want a ConfigError", err)
+			}
+			fields := make([]string, len(configErr.Errors))
+			for i, fe := range configErr.Errors {
+				fields[i] = fe.Field
+			}
+			sort.Strings(fields) // the schema does not report properties in a fixed order
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Fatalf("ValidateQueueConfig() reported fields %q, want %q (%v)", fields, tt.wantFields, err)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ve   *jsonschema.ValidationError
+		want []FieldError
+	}{
+		{
+			name: "leaf at the root",
+			ve:   &jsonschema.ValidationError{InstanceLocation: "", Message: "expected object"},
+			want: []FieldError{{Field: "", Message: "expected object"}},
+		},
+		{
+			name: "leaf in a nested object",
+			ve:   &jsonschema.ValidationError{InstanceLocation: "/retry_policy/jitter", Message: "must be <= 1"},
+			want: []FieldError{{Field: "retry_policy.jitter", Message: "must be <= 1"}},
+		},
+		{
+			name: "causes are flattened in order",
+			ve: &jsonschema.ValidationError{
+				InstanceLocation: "",
+				Message:          "doesn't validate",
+				Causes: []*jsonschema.ValidationError{
+					{InstanceLocation: "/lease_seconds", Message: "must be >= 0"},
+					{
+						InstanceLocation: "/rate_limit",
+						Message:          "doesn't validate",
+						Causes: []*jsonschema.ValidationError{
+							{InstanceLocation: "/rate_limit/burst", Message: "expected integer"},
+						},
+					},
+				},
+			},
+			want: []FieldError{
+				{Field: "lease_seconds", Message: "must be >= 0"},
+				{Field: "rate_limit.burst", Message: "expected integer"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldErrors(tt.ve, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("fieldErrors() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
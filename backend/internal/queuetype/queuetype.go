@@ -0,0 +1,155 @@
+// Package queuetype implements the queue types a queue can be created with. A
+// type decides which configuration a queue needs, how new messages are adjusted
+// before they are stored, and in which order they are claimed.
+package queuetype
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// Names of the built-in queue types
+const (
+	FIFO     = "fifo"
+	LIFO     = "lifo"
+	Priority = "priority"
+	Delay    = "delay"
+)
+
+// ErrUnknownType is returned by Lookup for a type that has not been registered
+var ErrUnknownType = errors.New("unknown queue type")
+
+// Type is the strategy behind a value of Queue.Type
+type Type interface {
+	// Name is the value of Queue.Type selecting this type
+	Name() string
+
+	// ValidateConfig reports configuration the type cannot work with
+	ValidateConfig(cfg *models.QueueConfig) error
+
+	// PrepareMessage adjusts a message produced to a queue of this type before it is stored
+	PrepareMessage(cfg *models.QueueConfig, message *models.Message, now time.Time)
+
+	// Order is the order in which eligible messages are claimed
+	Order() storage.Order
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Type)
+)
+
+func init() {
+	Register(fifo{})
+	Register(lifo{})
+	Register(priority{})
+	Register(delay{})
+}
+
+// Register makes a queue type available under its name. It panics when the name is
+// already taken.
+func Register(t Type) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := registry[t.Name()]; ok {
+		panic(fmt.Sprintf("queuetype: type %q registered twice", t.Name()))
+	}
+	registry[t.Name()] = t
+}
+
+// Lookup returns the queue type with the given name
+func Lookup(name string) (Type, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	t, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, want one of %s", ErrUnknownType, name, strings.Join(namesLocked(), ", "))
+	}
+	return t, nil
+}
+
+// Names returns the names of all registered queue types, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return namesLocked()
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fifo delivers messages in the order they were produced. Priority is ignored.
+type fifo struct{}
+
+func (fifo) Name() string { return FIFO }
+
+func (fifo) ValidateConfig(cfg *models.QueueConfig) error { return nil }
+
+func (fifo) PrepareMessage(cfg *models.QueueConfig, m *models.Message, now time.Time) {
+	m.Priority = 0
+}
+
+func (fifo) Order() storage.Order { return storage.OrderFIFO }
+
+// lifo delivers the newest message first. Priority is ignored.
+type lifo struct{}
+
+func (lifo) Name() string { return LIFO }
+
+func (lifo) ValidateConfig(cfg *models.QueueConfig) error { return nil }
+
+func (lifo) PrepareMessage(cfg *models.QueueConfig, m *models.Message, now time.Time) {
+	m.Priority = 0
+}
+
+func (lifo) Order() storage.Order { return storage.OrderLIFO }
+
+// priority delivers the highest priority first, oldest first within a priority
+type priority struct{}
+
+func (priority) Name() string { return Priority }
+
+func (priority) ValidateConfig(cfg *models.QueueConfig) error { return nil }
+
+func (priority) PrepareMessage(cfg *models.QueueConfig, m *models.Message, now time.Time) {}
+
+func (priority) Order() storage.Order { return storage.OrderPriority }
+
+// delay holds every message back for the queue's default delay unless the producer
+// schedules it explicitly, then delivers in the order produced. Priority is ignored.
+type delay struct{}
+
+func (delay) Name() string { return Delay }
+
+func (delay) ValidateConfig(cfg *models.QueueConfig) error {
+	if cfg.DefaultDelaySeconds <= 0 {
+		return &models.ConfigError{Errors: []models.FieldError{{
+			Field:   "default_delay_seconds",
+			Message: "a delay queue requires a positive default delay",
+		}}}
+	}
+	return nil
+}
+
+// PrepareMessage only drops the priority: the default delay itself is applied to
+// messages of every queue type by the queue service.
+func (delay) PrepareMessage(cfg *models.QueueConfig, m *models.Message, now time.Time) {
+	m.Priority = 0
+}
+
+func (delay) Order() storage.Order { return storage.OrderFIFO }
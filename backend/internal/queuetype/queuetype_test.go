@@ -0,0 +1,128 @@
+package queuetype
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantOrder storage.Order
+		wantErr   bool
+	}{
+		{name: FIFO, wantOrder: storage.OrderFIFO},
+		{name: LIFO, wantOrder: storage.OrderLIFO},
+		{name: Priority, wantOrder: storage.OrderPriority},
+		{name: Delay, wantOrder: storage.OrderFIFO},
+		{name: "", wantErr: true},
+		{name: "FIFO", wantErr: true},
+		{name: "stack", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := Lookup(tt.name)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownType) {
+					t.Fatalf("Lookup(%q) error = %v, want ErrUnknownType", tt.name, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup(%q) error = %v", tt.name, err)
+			}
+			if typ.Name() != tt.name {
+				t.Fatalf("Lookup(%q).Name() = %q", tt.name, typ.Name())
+			}
+			if typ.Order() != tt.wantOrder {
+				t.Fatalf("Lookup(%q).Order() = %v, want %v", tt.name, typ.Order(), tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	want := []string{Delay, FIFO, LIFO, Priority}
+	if got := Names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register of a taken name did not panic")
+		}
+	}()
+	Register(fifo{})
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		delay   int
+		wantErr bool
+	}{
+		{name: "fifo without delay", typ: FIFO},
+		{name: "lifo without delay", typ: LIFO},
+		{name: "priority without delay", typ: Priority},
+		{name: "delay with delay", typ: Delay, delay: 10},
+		{name: "delay without delay", typ: Delay, wantErr: true},
+		{name: "delay with negative delay", typ: Delay, delay: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := Lookup(tt.typ)
+			if err != nil {
+				t.Fatalf("Lookup(%q) error = %v", tt.typ, err)
+			}
+
+			err = typ.ValidateConfig(&models.QueueConfig{DefaultDelaySeconds: tt.delay})
+			if tt.wantErr {
+				var configErr *models.ConfigError
+				if !errors.As(err, &configErr) {
+					t.Fatalf("ValidateConfig() = %v, want a ConfigError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateConfig() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestPrepareMessage(t *testing.T) {
+	tests := []struct {
+		typ          string
+		wantPriority int
+	}{
+		{typ: FIFO, wantPriority: 0},
+		{typ: LIFO, wantPriority: 0},
+		{typ: Priority, wantPriority: 7},
+		{typ: Delay, wantPriority: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			typ, err := Lookup(tt.typ)
+			if err != nil {
+				t.Fatalf("Lookup(%q) error = %v", tt.typ, err)
+			}
+
+			message := &models.Message{Priority: 7}
+			typ.PrepareMessage(&models.QueueConfig{}, message, time.Now())
+			if message.Priority != tt.wantPriority {
+				t.Fatalf("PrepareMessage() left priority %d, want %d", message.Priority, tt.wantPriority)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff strategies
+const (
+	StrategyFixed       = "fixed"
+	StrategyLinear      = "linear"
+	StrategyExponential = "exponential"
+	StrategySchedule    = "schedule"
+)
+
+// maxBackoff bounds the delays of policies without max_delay_seconds, so that an
+// exponential delay stops growing
+const maxBackoff = 7 * 24 * time.Hour
+
+// DefaultMaxRetries applies to messages when neither they nor their queue's policy
+// set max_retries
+const DefaultMaxRetries = 3
+
+// DefaultPolicy applies to queues that do not configure a retry policy
+var DefaultPolicy = Policy{
+	Strategy:        StrategyExponential,
+	DelaySeconds:    5,
+	MaxDelaySeconds: 300,
+	Multiplier:      2,
+	Jitter:          0.1,
+}
+
+// Policy describes how long a failed message waits before it is redelivered
+type Policy struct {
+	Strategy        string  `json:"strategy"`
+	DelaySeconds    int     `json:"delay_seconds,omitempty"`     // fixed delay, linear step or exponential base
+	MaxDelaySeconds int     `json:"max_delay_seconds,omitempty"` // upper bound on any computed delay
+	Multiplier      float64 `json:"multiplier,omitempty"`        // exponential growth factor, defaults to 2
+	Jitter          float64 `json:"jitter,omitempty"`            // fraction of the delay to randomise, 0 to 1
+	Schedule        []int   `json:"schedule_seconds,omitempty"`  // explicit delay per attempt; the last entry repeats
+	MaxRetries      *int    `json:"max_retries,omitempty"`       // default for messages that do not set their own; 0 dead-letters on the first failure
+}
+
+// Validate reports the first problem with the policy, if any
+func (p *Policy) Validate() error {
+	switch p.Strategy {
+	case StrategyFixed, StrategyLinear, StrategyExponential:
+		if p.DelaySeconds <= 0 {
+			return fmt.Errorf("delay_seconds must be positive for the %s strategy", p.Strategy)
+		}
+	case StrategySchedule:
+		if len(p.Schedule) == 0 {
+			return errors.New("schedule_seconds must not be empty for the schedule strategy")
+		}
+		for _, d := range p.Schedule {
+			if d < 0 {
+				return errors.New("schedule_seconds must not contain negative delays")
+			}
+		}
+	case "":
+		return errors.New("strategy is required")
+	default:
+		return fmt.Errorf("unknown strategy %q", p.Strategy)
+	}
+
+	if p.MaxDelaySeconds < 0 {
+		return errors.New("max_delay_seconds must not be negative")
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return errors.New("multiplier must be at least 1")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("jitter must be between 0 and 1")
+	}
+	if p.MaxRetries != nil && *p.MaxRetries < 0 {
+		return errors.New("max_retries must not be negative")
+	}
+
+	return nil
+}
+
+// Backoff returns the delay before the given retry attempt, starting at 1. Delays
+// are capped at max_delay_seconds, or at a week when it is unset.
+func (p *Policy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	var seconds float64
+	switch p.Strategy {
+	case StrategyFixed:
+		seconds = float64(p.DelaySeconds)
+	case StrategyLinear:
+		seconds = float64(p.DelaySeconds) * float64(attempt)
+	case StrategyExponential:
+		multiplier := p.Multiplier
+		if multiplier == 0 {
+			multiplier = 2
+		}
+		// Large attempts make this +Inf, which the cap below brings back in range
+		seconds = float64(p.DelaySeconds) * math.Pow(multiplier, float64(attempt-1))
+	case StrategySchedule:
+		if len(p.Schedule) == 0 {
+			return 0
+		}
+		seconds = float64(p.Schedule[min(attempt, len(p.Schedule))-1])
+	}
+
+	limit := maxBackoff.Seconds()
+	if p.MaxDelaySeconds > 0 {
+		limit = float64(p.MaxDelaySeconds)
+	}
+	seconds = math.Min(seconds, limit)
+
+	if p.Jitter > 0 {
+		seconds -= seconds * p.Jitter * rand.Float64()
+	}
+
+	// A max_delay_seconds of centuries does not fit in a time.Duration
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,112 @@
+package retry
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr string
+	}{
+		{"default", DefaultPolicy, ""},
+		{"fixed", Policy{Strategy: StrategyFixed, DelaySeconds: 10}, ""},
+		{"linear with max", Policy{Strategy: StrategyLinear, DelaySeconds: 10, MaxDelaySeconds: 60}, ""},
+		{"schedule", Policy{Strategy: StrategySchedule, Schedule: []int{0, 5, 30}}, ""},
+		{"no retries", Policy{Strategy: StrategyFixed, DelaySeconds: 1, MaxRetries: intPtr(0)}, ""},
+		{"missing strategy", Policy{DelaySeconds: 10}, "strategy is required"},
+		{"unknown strategy", Policy{Strategy: "random", DelaySeconds: 10}, `unknown strategy "random"`},
+		{"zero delay", Policy{Strategy: StrategyFixed}, "delay_seconds must be positive"},
+		{"negative delay", Policy{Strategy: StrategyExponential, DelaySeconds: -1}, "delay_seconds must be positive"},
+		{"empty schedule", Policy{Strategy: StrategySchedule}, "schedule_seconds must not be empty"},
+		{"negative schedule entry", Policy{Strategy: StrategySchedule, Schedule: []int{5, -1}}, "negative delays"},
+		{"negative max delay", Policy{Strategy: StrategyFixed, DelaySeconds: 1, MaxDelaySeconds: -1}, "max_delay_seconds"},
+		{"multiplier below one", Policy{Strategy: StrategyExponential, DelaySeconds: 1, Multiplier: 0.5}, "multiplier"},
+		{"jitter above one", Policy{Strategy: StrategyFixed, DelaySeconds: 1, Jitter: 1.5}, "jitter"},
+		{"negative jitter", Policy{Strategy: StrategyFixed, DelaySeconds: 1, Jitter: -0.1}, "jitter"},
+		{"negative max retries", Policy{Strategy: StrategyFixed, DelaySeconds: 1, MaxRetries: intPtr(-1)}, "max_retries"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		want    time.Duration
+	}{
+		{"fixed", Policy{Strategy: StrategyFixed, DelaySeconds: 10}, 3, 10 * time.Second},
+		{"attempt below one", Policy{Strategy: StrategyLinear, DelaySeconds: 10}, 0, 10 * time.Second},
+		{"linear", Policy{Strategy: StrategyLinear, DelaySeconds: 10}, 3, 30 * time.Second},
+		{"linear capped", Policy{Strategy: StrategyLinear, DelaySeconds: 10, MaxDelaySeconds: 25}, 3, 25 * time.Second},
+		{"exponential first attempt", Policy{Strategy: StrategyExponential, DelaySeconds: 5}, 1, 5 * time.Second},
+		{"exponential default multiplier", Policy{Strategy: StrategyExponential, DelaySeconds: 5}, 4, 40 * time.Second},
+		{"exponential multiplier", Policy{Strategy: StrategyExponential, DelaySeconds: 1, Multiplier: 3}, 3, 9 * time.Second},
+		{"exponential capped", Policy{Strategy: StrategyExponential, DelaySeconds: 5, MaxDelaySeconds: 60}, 10, time.Minute},
+		{"exponential uncapped stops at a week", Policy{Strategy: StrategyExponential, DelaySeconds: 5}, 40, maxBackoff},
+		{"exponential overflowing float", Policy{Strategy: StrategyExponential, DelaySeconds: 5}, 5000, maxBackoff},
+		{"exponential overflowing float with max", Policy{Strategy: StrategyExponential, DelaySeconds: 5, MaxDelaySeconds: 3600}, 5000, time.Hour},
+		{"max beyond duration range", Policy{Strategy: StrategyExponential, DelaySeconds: 5, MaxDelaySeconds: math.MaxInt}, 5000, math.MaxInt64},
+		{"schedule", Policy{Strategy: StrategySchedule, Schedule: []int{0, 5, 30}}, 2, 5 * time.Second},
+		{"schedule repeats last entry", Policy{Strategy: StrategySchedule, Schedule: []int{0, 5, 30}}, 7, 30 * time.Second},
+		{"empty schedule", Policy{Strategy: StrategySchedule}, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+				t.Fatalf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffNeverNegative(t *testing.T) {
+	policies := []Policy{
+		{Strategy: StrategyExponential, DelaySeconds: 1},
+		{Strategy: StrategyExponential, DelaySeconds: 1, Multiplier: 10, Jitter: 1},
+		{Strategy: StrategyLinear, DelaySeconds: math.MaxInt32},
+	}
+
+	for _, policy := range policies {
+		for attempt := 1; attempt <= 200; attempt++ {
+			if got := policy.Backoff(attempt); got < 0 {
+				t.Fatalf("%+v: Backoff(%d) = %v, want a non-negative delay", policy, attempt, got)
+			}
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := Policy{Strategy: StrategyFixed, DelaySeconds: 100, Jitter: 0.2}
+
+	for i := 0; i < 100; i++ {
+		got := policy.Backoff(1)
+		if got < 80*time.Second || got > 100*time.Second {
+			t.Fatalf("Backoff(1) = %v, want between 80s and 100s", got)
+		}
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
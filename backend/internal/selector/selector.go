@@ -0,0 +1,152 @@
+// Package selector parses and evaluates message header selectors such as
+// `type=invoice AND region!=us`.
+package selector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Term is a single comparison of a header against a value
+type Term struct {
+	Key    string
+	Value  string
+	Negate bool // true for !=
+}
+
+// Selector matches messages whose headers satisfy every term
+type Selector struct {
+	Terms []Term
+}
+
+// Parse parses a selector of the form `key=value AND key!=value`. Values may be
+// double-quoted to include spaces. An empty string yields nil, which matches everything.
+func Parse(s string) (*Selector, error) {
+	p := &parser{input: s}
+	p.skipSpace()
+	if p.done() {
+		return nil, nil
+	}
+
+	sel := &Selector{}
+	for {
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		sel.Terms = append(sel.Terms, term)
+
+		spaced := p.skipSpace()
+		if p.done() {
+			return sel, nil
+		}
+		if !spaced || !p.keyword("AND") || !p.skipSpace() {
+			return nil, fmt.Errorf("invalid selector %q: expected AND at offset %d", s, p.pos)
+		}
+	}
+}
+
+// parser scans a selector one term at a time, so quoted values may hold
+// anything, including operators and the AND keyword
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) rest() string {
+	return p.input[p.pos:]
+}
+
+// skipSpace advances past whitespace and reports whether there was any
+func (p *parser) skipSpace() bool {
+	start := p.pos
+	for !p.done() && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// keyword consumes word, in any case, if it comes next and is followed by a space
+func (p *parser) keyword(word string) bool {
+	rest := p.rest()
+	if len(rest) <= len(word) || !strings.EqualFold(rest[:len(word)], word) || !unicode.IsSpace(rune(rest[len(word)])) {
+		return false
+	}
+	p.pos += len(word)
+	return true
+}
+
+// term reads key, operator and value. The operator is matched once, right
+// after the key, so the value may contain = or != itself.
+func (p *parser) term() (Term, error) {
+	start := p.pos
+	for !p.done() && isKeyByte(p.input[p.pos]) {
+		p.pos++
+	}
+	key := p.input[start:p.pos]
+	if key == "" {
+		return Term{}, fmt.Errorf("invalid selector key %q", p.untilSpace())
+	}
+	p.skipSpace()
+
+	var negate bool
+	switch rest := p.rest(); {
+	case strings.HasPrefix(rest, "!="):
+		negate = true
+		p.pos += 2
+	case strings.HasPrefix(rest, "="):
+		p.pos++
+	default:
+		return Term{}, fmt.Errorf("invalid selector term %q: expected key=value or key!=value", key+p.untilSpace())
+	}
+	p.skipSpace()
+
+	if !strings.HasPrefix(p.rest(), `"`) {
+		return Term{Key: key, Value: p.untilSpace(), Negate: negate}, nil
+	}
+
+	quoted, err := strconv.QuotedPrefix(p.rest())
+	if err != nil {
+		return Term{}, fmt.Errorf("invalid quoted value in selector term %q", p.input[start:])
+	}
+	p.pos += len(quoted)
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return Term{}, fmt.Errorf("invalid quoted value in selector term %q", p.input[start:p.pos])
+	}
+	return Term{Key: key, Value: value, Negate: negate}, nil
+}
+
+// untilSpace consumes and returns everything up to the next whitespace
+func (p *parser) untilSpace() string {
+	start := p.pos
+	for !p.done() && !unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func isKeyByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
+
+// Matches reports whether the headers satisfy the selector. A nil selector matches everything.
+func (s *Selector) Matches(headers map[string]string) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, term := range s.Terms {
+		value, ok := headers[term.Key]
+		if term.Negate == (ok && value == term.Value) {
+			return false
+		}
+	}
+	return true
+}
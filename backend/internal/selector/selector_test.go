@@ -0,0 +1,102 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Term
+		wantErr bool
+	}{
+		{name: "empty", input: "  "},
+		{name: "single", input: "type=invoice", want: []Term{{Key: "type", Value: "invoice"}}},
+		{name: "negated", input: "region!=us", want: []Term{{Key: "region", Value: "us", Negate: true}}},
+		{
+			name:  "and",
+			input: "type=invoice AND region!=us",
+			want:  []Term{{Key: "type", Value: "invoice"}, {Key: "region", Value: "us", Negate: true}},
+		},
+		{
+			name:  "lowercase and with extra spaces",
+			input: "  type = invoice   and   region != us ",
+			want:  []Term{{Key: "type", Value: "invoice"}, {Key: "region", Value: "us", Negate: true}},
+		},
+		{name: "operator in value", input: "key=a!=b", want: []Term{{Key: "key", Value: "a!=b"}}},
+		{name: "equals in negated value", input: "key!=a=b", want: []Term{{Key: "key", Value: "a=b", Negate: true}}},
+		{name: "quoted spaces", input: `name="hello world"`, want: []Term{{Key: "name", Value: "hello world"}}},
+		{
+			name:  "quoted and",
+			input: `title="cats AND dogs" AND lang=en`,
+			want:  []Term{{Key: "title", Value: "cats AND dogs"}, {Key: "lang", Value: "en"}},
+		},
+		{name: "quoted escape", input: `q="say \"hi\""`, want: []Term{{Key: "q", Value: `say "hi"`}}},
+		{name: "empty value", input: "key=", want: []Term{{Key: "key", Value: ""}}},
+		{name: "dotted key", input: "x-trace.id=1", want: []Term{{Key: "x-trace.id", Value: "1"}}},
+		{name: "missing operator", input: "type", wantErr: true},
+		{name: "missing key", input: "=invoice", wantErr: true},
+		{name: "invalid key", input: "ty pe=invoice", wantErr: true},
+		{name: "missing and", input: "a=1 b=2", wantErr: true},
+		{name: "trailing and", input: "a=1 AND", wantErr: true},
+		{name: "unterminated quote", input: `a="oops`, wantErr: true},
+		{name: "text after quote", input: `a="x"y`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want error", tt.input, sel)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+
+			var got []Term
+			if sel != nil {
+				got = sel.Terms
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	headers := map[string]string{"type": "invoice", "region": "eu"}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     bool
+	}{
+		{name: "nil selector", selector: "", want: true},
+		{name: "equal", selector: "type=invoice", want: true},
+		{name: "not equal", selector: "type=receipt", want: false},
+		{name: "negated mismatch", selector: "region!=us", want: true},
+		{name: "negated match", selector: "region!=eu", want: false},
+		{name: "negated missing header", selector: "tenant!=acme", want: true},
+		{name: "missing header", selector: "tenant=acme", want: false},
+		{name: "all terms", selector: "type=invoice AND region=eu", want: true},
+		{name: "one term fails", selector: "type=invoice AND region=us", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.selector, err)
+			}
+			if got := sel.Matches(headers); got != tt.want {
+				t.Fatalf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
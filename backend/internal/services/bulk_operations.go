@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/selector"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// operationRetention is how long a finished bulk operation can still be looked up
+const operationRetention = time.Hour
+
+// An empty chunk while matching messages remain means they are locked by other
+// transactions. runBulk waits for them up to bulkLockedRetries times, the wait
+// doubling from bulkLockedMinBackoff up to bulkLockedMaxBackoff, then gives up.
+const (
+	bulkLockedRetries    = 5
+	bulkLockedMinBackoff = 50 * time.Millisecond
+	bulkLockedMaxBackoff = time.Second
+)
+
+// bulkOperations tracks the bulk operations started on this server instance
+type bulkOperations struct {
+	mu  sync.Mutex
+	ops map[string]*bulkOperation
+}
+
+type bulkOperation struct {
+	state  models.BulkOperation
+	cancel context.CancelFunc
+}
+
+func newBulkOperations() *bulkOperations {
+	return &bulkOperations{ops: make(map[string]*bulkOperation)}
+}
+
+// start registers a new running operation and returns it with the context it runs under
+func (o *bulkOperations) start(queueID int64, action string) (models.BulkOperation, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for id, op := range o.ops {
+		if op.state.FinishedAt != nil && now.Sub(*op.state.FinishedAt) > operationRetention {
+			delete(o.ops, id)
+		}
+	}
+
+	op := &bulkOperation{
+		state: models.BulkOperation{
+			ID:        newOperationID(),
+			QueueID:   queueID,
+			Action:    action,
+			Status:    models.BulkOperationRunning,
+			StartedAt: now,
+		},
+		cancel: cancel,
+	}
+	o.ops[op.state.ID] = op
+	return op.state, ctx
+}
+
+// progress records a committed chunk of n messages
+func (o *bulkOperations) progress(id string, n int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if op, ok := o.ops[id]; ok {
+		op.state.Processed += n
+		op.state.Chunks++
+	}
+}
+
+// finish moves an operation to its final status, with the matching messages it left
+func (o *bulkOperations) finish(id string, status string, remaining int64, err error) models.BulkOperation {
+	now := time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	op := o.ops[id]
+	op.state.Status = status
+	op.state.FinishedAt = &now
+	op.state.Remaining = remaining
+	if err != nil {
+		op.state.Error = err.Error()
+	}
+	op.cancel()
+	return op.state
+}
+
+func (o *bulkOperations) get(id string) (*models.BulkOperation, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	op, ok := o.ops[id]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	state := op.state
+	return &state, nil
+}
+
+func (o *bulkOperations) stop(id string) (*models.BulkOperation, error) {
+	o.mu.Lock()
+	op, ok := o.ops[id]
+	o.mu.Unlock()
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+
+	op.cancel()
+	return o.get(id)
+}
+
+// PurgeQueue starts deleting the messages of a queue matching req in the background
+func (s *QueueService) PurgeQueue(ctx context.Context, queueID int64, req *models.PurgeQueueRequest) (*models.BulkOperation, error) {
+	return s.DeleteMessages(ctx, queueID, &models.MessageFilterRequest{
+		Statuses:      req.Statuses,
+		MinAgeSeconds: req.MinAgeSeconds,
+		MaxAgeSeconds: req.MaxAgeSeconds,
+	})
+}
+
+// DeleteMessages starts deleting the messages of a queue matching req in the background
+func (s *QueueService) DeleteMessages(ctx context.Context, queueID int64, req *models.MessageFilterRequest) (*models.BulkOperation, error) {
+	return s.startBulk(ctx, queueID, req, models.BulkActionDelete, storage.BulkOptions{Action: storage.BulkDelete})
+}
+
+// RequeueMessages starts returning the finished messages of a queue matching req to
+// pending in the background. Pending and in-flight messages are left alone. Dead
+// letter queues are refused, since their messages go back through RedriveMessages
+// on the queues they came from.
+func (s *QueueService) RequeueMessages(ctx context.Context, queueID int64, req *models.MessageFilterRequest) (*models.BulkOperation, error) {
+	sources, err := s.catalog.ListQueues(ctx, storage.QueueListOptions{DeadLetterQueueID: queueID})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, source := range sources {
+		if source.ID != queueID {
+			names = append(names, strconv.Quote(source.Name))
+		}
+	}
+	if len(names) > 0 {
+		return nil, fmt.Errorf("%w: queue %d is the dead letter queue of %s, redrive its messages from there instead", ErrInvalidFilter, queueID, strings.Join(names, ", "))
+	}
+
+	return s.startBulk(ctx, queueID, req, models.BulkActionRequeue, storage.BulkOptions{Action: storage.BulkRequeue})
+}
+
+// ReprioritizeMessages starts changing the priority of the messages of a queue matching req in the background
+func (s *QueueService) ReprioritizeMessages(ctx context.Context, queueID int64, req *models.ReprioritizeMessagesRequest) (*models.BulkOperation, error) {
+	if req.Priority == nil {
+		return nil, fmt.Errorf("%w: priority is required", ErrInvalidFilter)
+	}
+	return s.startBulk(ctx, queueID, &req.MessageFilterRequest, models.BulkActionReprioritize, storage.BulkOptions{
+		Action:   storage.BulkReprioritize,
+		Priority: *req.Priority,
+	})
+}
+
+// GetBulkOperation returns the progress of a bulk operation started on this server instance
+func (s *QueueService) GetBulkOperation(ctx context.Context, id string) (*models.BulkOperation, error) {
+	return s.operations.get(id)
+}
+
+// CancelBulkOperation stops a running bulk operation after its current chunk.
+// Chunks already committed stay applied.
+func (s *QueueService) CancelBulkOperation(ctx context.Context, id string) (*models.BulkOperation, error) {
+	return s.operations.stop(id)
+}
+
+func (s *QueueService) startBulk(ctx context.Context, queueID int64, req *models.MessageFilterRequest, action string, opts storage.BulkOptions) (*models.BulkOperation, error) {
+	if _, err := s.GetQueue(ctx, queueID); err != nil {
+		return nil, err
+	}
+
+	filter, err := messageFilter(queueID, req)
+	if err != nil {
+		return nil, err
+	}
+	opts.Filter = filter
+	opts.Limit = s.cfg.BulkChunkSize
+
+	op, opCtx := s.operations.start(queueID, action)
+	go s.runBulk(opCtx, op.ID, opts)
+
+	return &op, nil
+}
+
+// runBulk applies a bulk operation one chunk at a time until no message matches,
+// so no transaction holds row locks on more than one chunk. Chunks skip messages
+// locked by other transactions, so after an empty chunk it counts the matching
+// messages and waits for them while any are left. An operation still blocked after
+// bulkLockedRetries waits finishes as partial, with the messages it left.
+func (s *QueueService) runBulk(ctx context.Context, id string, opts storage.BulkOptions) {
+	backoff := bulkLockedMinBackoff
+	retries := 0
+
+	for {
+		n, err := s.backend.Bulk(ctx, opts)
+		if err == nil && n > 0 {
+			s.operations.progress(id, n)
+			if opts.Action == storage.BulkRequeue {
+				s.notifier.Notify(ctx, opts.Filter.QueueID)
+			}
+			backoff, retries = bulkLockedMinBackoff, 0
+		}
+
+		var remaining int64
+		if err == nil && n == 0 {
+			remaining, err = s.backend.BulkRemaining(ctx, opts)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			s.logBulk(s.operations.finish(id, models.BulkOperationCancelled, 0, nil))
+			return
+		case err != nil:
+			s.logBulk(s.operations.finish(id, models.BulkOperationFailed, 0, err))
+			return
+		case n > 0:
+			continue
+		case remaining == 0:
+			s.logBulk(s.operations.finish(id, models.BulkOperationCompleted, 0, nil))
+			return
+		case retries == bulkLockedRetries:
+			s.logBulk(s.operations.finish(id, models.BulkOperationPartial, remaining, nil))
+			return
+		}
+
+		retries++
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, bulkLockedMaxBackoff)
+	}
+}
+
+func (s *QueueService) logBulk(op models.BulkOperation) {
+	log.Printf("Bulk %s %s on queue %d %s after %d messages in %d chunks", op.Action, op.ID, op.QueueID, op.Status, op.Processed, op.Chunks)
+}
+
+// messageFilter converts a filter request into the storage filter of a queue
+func messageFilter(queueID int64, req *models.MessageFilterRequest) (storage.MessageFilter, error) {
+	filter := storage.MessageFilter{
+		QueueID:    queueID,
+		Statuses:   req.Statuses,
+		MessageIDs: req.MessageIDs,
+	}
+
+	for _, status := range req.Statuses {
+		switch status {
+		case models.MessageStatusScheduled, models.MessageStatusPending, models.MessageStatusProcessing, models.MessageStatusCompleted,
+			models.MessageStatusFailed, models.MessageStatusDeadLettered:
+		default:
+			return filter, fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, status)
+		}
+	}
+
+	if req.MinAgeSeconds < 0 || req.MaxAgeSeconds < 0 {
+		return filter, fmt.Errorf("%w: ages must not be negative", ErrInvalidFilter)
+	}
+
+	now := time.Now()
+	if req.MinAgeSeconds > 0 {
+		before := now.Add(-time.Duration(req.MinAgeSeconds) * time.Second)
+		filter.CreatedBefore = &before
+	}
+	if req.MaxAgeSeconds > 0 {
+		after := now.Add(-time.Duration(req.MaxAgeSeconds) * time.Second)
+		filter.CreatedAfter = &after
+	}
+
+	sel, err := selector.Parse(req.Selector)
+	if err != nil {
+		return filter, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+	filter.Selector = sel
+
+	return filter, nil
+}
+
+func newOperationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate operation ID: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// lockedBackend reports its locked messages as matching but never selects them,
+// as postgres does for rows held by other transactions, until unlocked after the
+// given number of empty chunks
+type lockedBackend struct {
+	storage.Backend
+	locked      int64
+	unlockAfter int32
+	empty       atomic.Int32
+}
+
+func (b *lockedBackend) Bulk(ctx context.Context, opts storage.BulkOptions) (int64, error) {
+	if b.unlockAfter < 0 || b.empty.Load() < b.unlockAfter {
+		b.empty.Add(1)
+		return 0, nil
+	}
+	return b.Backend.Bulk(ctx, opts)
+}
+
+func (b *lockedBackend) BulkRemaining(ctx context.Context, opts storage.BulkOptions) (int64, error) {
+	n, err := b.Backend.BulkRemaining(ctx, opts)
+	if b.unlockAfter < 0 || b.empty.Load() < b.unlockAfter {
+		n += b.locked
+	}
+	return n, err
+}
+
+func TestRunBulkWaitsForLockedMessages(t *testing.T) {
+	tests := []struct {
+		name          string
+		unlockAfter   int32
+		wantStatus    string
+		wantProcessed int64
+		wantRemaining int64
+	}{
+		{"unlocked while waiting", 2, models.BulkOperationCompleted, 3, 0},
+		{"never unlocked", -1, models.BulkOperationPartial, 0, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, _, _ := newMemoryServices()
+			s.backend = &lockedBackend{Backend: s.backend, locked: 2, unlockAfter: tt.unlockAfter}
+
+			queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "orders", Type: "fifo"})
+			if err != nil {
+				t.Fatalf("CreateQueue: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := s.CreateMessage(ctx, &models.CreateMessageRequest{QueueID: queue.ID, Payload: "job"}); err != nil {
+					t.Fatalf("CreateMessage: %v", err)
+				}
+			}
+
+			op, err := s.DeleteMessages(ctx, queue.ID, &models.MessageFilterRequest{})
+			if err != nil {
+				t.Fatalf("DeleteMessages: %v", err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for op.Status == models.BulkOperationRunning {
+				if time.Now().After(deadline) {
+					t.Fatal("bulk operation still running")
+				}
+				time.Sleep(10 * time.Millisecond)
+				if op, err = s.GetBulkOperation(ctx, op.ID); err != nil {
+					t.Fatalf("GetBulkOperation: %v", err)
+				}
+			}
+
+			if op.Status != tt.wantStatus || op.Processed != tt.wantProcessed || op.Remaining != tt.wantRemaining {
+				t.Fatalf("bulk operation %s after %d messages with %d left, want %s after %d with %d left",
+					op.Status, op.Processed, op.Remaining, tt.wantStatus, tt.wantProcessed, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestRequeueMessagesRefusesDeadLetterQueues(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "orders", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+
+	if _, err := s.RequeueMessages(ctx, *queue.DeadLetterQueueID, &models.MessageFilterRequest{}); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("RequeueMessages on the dead letter queue: got %v, want ErrInvalidFilter", err)
+	}
+	if _, err := s.RequeueMessages(ctx, queue.ID, &models.MessageFilterRequest{}); err != nil {
+		t.Fatalf("RequeueMessages on the source queue: %v", err)
+	}
+}
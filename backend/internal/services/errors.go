@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+var (
+	// ErrQueueNotFound is returned when a queue does not exist
+	ErrQueueNotFound = storage.ErrQueueNotFound
+
+	// ErrQueueExists is returned when a queue name is already taken
+	ErrQueueExists = errors.New("queue already exists")
+
+	// ErrQueueNotEmpty is returned when a queue holding messages is deleted in refuse mode
+	ErrQueueNotEmpty = errors.New("queue is not empty")
+
+	// ErrQueueInUse is returned when a queue is deleted while another queue uses it as its dead letter queue
+	ErrQueueInUse = errors.New("queue is in use")
+
+	// ErrInvalidDeleteMode is returned when a queue is deleted with an unknown mode
+	ErrInvalidDeleteMode = errors.New("invalid delete mode")
+
+	// ErrVersionConflict is returned when a queue was changed since the version the caller last read
+	ErrVersionConflict = errors.New("queue version conflict")
+
+	// ErrInvalidQueueType is returned when a queue is created with an unknown type
+	ErrInvalidQueueType = errors.New("invalid queue type")
+
+	// ErrInvalidQueueConfig is returned when a queue's configuration fails validation
+	ErrInvalidQueueConfig = errors.New("invalid queue config")
+
+	// ErrInvalidQueueSpec is returned when the queue declarations of an apply fail validation
+	ErrInvalidQueueSpec = errors.New("invalid queue declarations")
+
+	// ErrInvalidMessage is returned when a produce request fails validation
+	ErrInvalidMessage = errors.New("invalid message")
+
+	// ErrQueueDraining is returned when a message is produced to a draining queue
+	ErrQueueDraining = errors.New("queue is draining")
+
+	// ErrMessageTooLarge is returned when a payload exceeds the queue's max_message_size_bytes
+	ErrMessageTooLarge = errors.New("message too large")
+
+	// ErrQueueFull is returned when a produce would take a queue beyond its max_depth
+	ErrQueueFull = errors.New("queue is full")
+
+	// ErrRateLimited is returned when a produce or claim exceeds the queue's rate limit
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	// ErrNoDeadLetterQueue is returned when a queue has no linked dead letter queue
+	ErrNoDeadLetterQueue = errors.New("queue has no dead letter queue")
+
+	// ErrInvalidFilter is returned when a bulk operation's message filter fails validation
+	ErrInvalidFilter = errors.New("invalid message filter")
+
+	// ErrOperationNotFound is returned when a bulk operation is unknown to this server instance
+	ErrOperationNotFound = errors.New("bulk operation not found")
+
+	// ErrWorkerNotFound is returned when a worker does not exist
+	ErrWorkerNotFound = storage.ErrWorkerNotFound
+
+	// ErrWorkerStopped is returned when a stopped worker sends a heartbeat or claims messages
+	ErrWorkerStopped = storage.ErrWorkerStopped
+
+	// ErrWorkerQueueMismatch is returned when a worker claims messages of a queue it is not registered for
+	ErrWorkerQueueMismatch = errors.New("worker is registered for another queue")
+
+	// ErrInvalidWorker is returned when a worker request fails validation
+	ErrInvalidWorker = errors.New("invalid worker")
+
+	// ErrScheduleNotFound is returned when a schedule does not exist
+	ErrScheduleNotFound = storage.ErrScheduleNotFound
+
+	// ErrScheduleExists is returned when a schedule name is already taken
+	ErrScheduleExists = errors.New("schedule already exists")
+
+	// ErrInvalidSchedule is returned when a schedule request fails validation
+	ErrInvalidSchedule = errors.New("invalid schedule")
+
+	// ErrNoMessageAvailable is returned when a queue has no message eligible for delivery
+	ErrNoMessageAvailable = storage.ErrNoMessageAvailable
+
+	// ErrMessageNotFound is returned when a message does not exist
+	ErrMessageNotFound = storage.ErrMessageNotFound
+
+	// ErrLeaseNotHeld is returned when the caller's lease token does not match the
+	// message's current, unexpired lease
+	ErrLeaseNotHeld = storage.ErrLeaseNotHeld
+)
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// LeaseReaper periodically returns messages with an expired lease to their queue,
+// so a message held by a crashed worker is redelivered. It also forgets expired
+// deduplication keys, deletes finished messages past their queue's retention and
+// purges queues whose deletion grace period has passed.
+type LeaseReaper struct {
+	queueService *QueueService
+	interval     time.Duration
+}
+
+func NewLeaseReaper(queueService *QueueService, interval time.Duration) *LeaseReaper {
+	return &LeaseReaper{queueService: queueService, interval: interval}
+}
+
+// Run blocks until ctx is cancelled
+func (r *LeaseReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Each step runs even when an earlier one fails, so one failing step
+			// does not stop the others
+			if n, err := r.queueService.RequeueExpiredLeases(ctx); err != nil {
+				log.Printf("Lease reaper: %v", err)
+			} else if n > 0 {
+				log.Printf("Lease reaper: requeued %d messages with expired leases", n)
+			}
+
+			if _, err := r.queueService.ExpireDedupKeys(ctx); err != nil {
+				log.Printf("Lease reaper: %v", err)
+			}
+
+			if n, err := r.queueService.EnforceRetention(ctx); err != nil {
+				log.Printf("Lease reaper: %v", err)
+			} else if n > 0 {
+				log.Printf("Lease reaper: deleted %d messages past their retention", n)
+			}
+
+			if n, err := r.queueService.PurgeDeletedQueues(ctx); err != nil {
+				log.Printf("Lease reaper: %v", err)
+			} else if n > 0 {
+				log.Printf("Lease reaper: purged %d deleted queues", n)
+			}
+		}
+	}
+}
@@ -1,17 +1,31 @@
 package services
 
 import (
+	"context"
+	"strconv"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/shravan20/qafka/internal/models"
 )
 
 type MonitoringService struct {
 	QueueDepth     prometheus.GaugeVec
 	MessagesTotal  prometheus.CounterVec
 	ProcessingTime prometheus.HistogramVec
+	SchedulingLag  prometheus.HistogramVec
 }
 
-func NewMonitoringService() *MonitoringService {
+// queueStateTimeout bounds how long a scrape waits for the queue states
+const queueStateTimeout = 5 * time.Second
+
+// NewMonitoringService registers the metrics. Queue states are read from queueService
+// whenever the metrics are scraped.
+func NewMonitoringService(queueService *QueueService) *MonitoringService {
+	prometheus.MustRegister(newQueueStateCollector(queueService))
+
 	return &MonitoringService{
 		QueueDepth: *promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -34,6 +48,14 @@ func NewMonitoringService() *MonitoringService {
 			},
 			[]string{"queue_name"},
 		),
+		SchedulingLag: *promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "qafka_scheduling_lag_seconds",
+				Help:    "Time between a message becoming due and the scheduler promoting it to pending",
+				Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+			},
+			[]string{"queue_name"},
+		),
 	}
 }
 
@@ -41,6 +63,10 @@ func (m *MonitoringService) IncrementMessageCounter(queueName, status string) {
 	m.MessagesTotal.WithLabelValues(queueName, status).Inc()
 }
 
+func (m *MonitoringService) AddMessageCounter(queueName, status string, count float64) {
+	m.MessagesTotal.WithLabelValues(queueName, status).Add(count)
+}
+
 func (m *MonitoringService) SetQueueDepth(queueName, status string, depth float64) {
 	m.QueueDepth.WithLabelValues(queueName, status).Set(depth)
 }
@@ -48,3 +74,52 @@ func (m *MonitoringService) SetQueueDepth(queueName, status string, depth float6
 func (m *MonitoringService) ObserveProcessingTime(queueName string, duration float64) {
 	m.ProcessingTime.WithLabelValues(queueName).Observe(duration)
 }
+
+func (m *MonitoringService) ObserveSchedulingLag(queueName string, lag float64) {
+	m.SchedulingLag.WithLabelValues(queueName).Observe(lag)
+}
+
+// queueStateCollector reports the state of every queue as stored, so each replica
+// reports the same states whichever replica changed them
+type queueStateCollector struct {
+	queueService *QueueService
+	desc         *prometheus.Desc
+}
+
+func newQueueStateCollector(queueService *QueueService) *queueStateCollector {
+	return &queueStateCollector{
+		queueService: queueService,
+		desc: prometheus.NewDesc(
+			"qafka_queue_state",
+			"1 for the current state of each queue (active, paused or draining), 0 otherwise",
+			[]string{"queue_name", "state"},
+			nil,
+		),
+	}
+}
+
+func (c *queueStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueStateTimeout)
+	defer cancel()
+
+	queues, err := c.queueService.GetQueues(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for _, queue := range queues {
+		queueName := "queue_" + strconv.FormatInt(queue.ID, 10)
+		for _, state := range []string{models.QueueStateActive, models.QueueStatePaused, models.QueueStateDraining} {
+			value := 0.0
+			if state == queue.State {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, queueName, state)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/shravan20/qafka/internal/models"
+)
+
+func TestQueueStateCollector(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "orders", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+	if _, err := s.PauseQueue(ctx, queue.ID); err != nil {
+		t.Fatalf("PauseQueue: %v", err)
+	}
+
+	collector := newQueueStateCollector(s)
+	dlq := "queue_" + strconv.FormatInt(*queue.DeadLetterQueueID, 10)
+	name := "queue_" + strconv.FormatInt(queue.ID, 10)
+
+	want := `
+# HELP qafka_queue_state 1 for the current state of each queue (active, paused or draining), 0 otherwise
+# TYPE qafka_queue_state gauge
+qafka_queue_state{queue_name="` + name + `",state="active"} 0
+qafka_queue_state{queue_name="` + name + `",state="draining"} 0
+qafka_queue_state{queue_name="` + name + `",state="paused"} 1
+qafka_queue_state{queue_name="` + dlq + `",state="active"} 1
+qafka_queue_state{queue_name="` + dlq + `",state="draining"} 0
+qafka_queue_state{queue_name="` + dlq + `",state="paused"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Fatalf("after pause: %v", err)
+	}
+
+	// A deleted queue is no longer reported
+	if _, err := s.DeleteQueue(ctx, queue.ID, models.QueueDeleteCascade); err != nil {
+		t.Fatalf("DeleteQueue: %v", err)
+	}
+	want = `
+# HELP qafka_queue_state 1 for the current state of each queue (active, paused or draining), 0 otherwise
+# TYPE qafka_queue_state gauge
+qafka_queue_state{queue_name="` + dlq + `",state="active"} 1
+qafka_queue_state{queue_name="` + dlq + `",state="draining"} 0
+qafka_queue_state{queue_name="` + dlq + `",state="paused"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Fatalf("after delete: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+const (
+	// notifyChannel is the Postgres channel carrying queue wakeups between API replicas
+	notifyChannel = "qafka_messages"
+
+	// listenMinBackoff and listenMaxBackoff bound the wait before listening again
+	// after the listener failed
+	listenMinBackoff = time.Second
+	listenMaxBackoff = 30 * time.Second
+)
+
+// Notifier wakes consumers long-polling a queue when a message may have become eligible
+type Notifier interface {
+	// Notify wakes every subscriber of the queue
+	Notify(ctx context.Context, queueID int64)
+
+	// Subscribe returns a channel that receives a value after each Notify for the
+	// queue, and a function that ends the subscription
+	Subscribe(queueID int64) (<-chan struct{}, func())
+}
+
+// LocalNotifier delivers notifications within a single process
+type LocalNotifier struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan struct{}]struct{}
+}
+
+func NewLocalNotifier() *LocalNotifier {
+	return &LocalNotifier{subscribers: make(map[int64]map[chan struct{}]struct{})}
+}
+
+func (n *LocalNotifier) Notify(ctx context.Context, queueID int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers[queueID] {
+		// A pending wakeup is as good as a new one, so never block
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// notifyAll wakes every subscriber of every queue
+func (n *LocalNotifier) notifyAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, subscribers := range n.subscribers {
+		for ch := range subscribers {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (n *LocalNotifier) Subscribe(queueID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	if n.subscribers[queueID] == nil {
+		n.subscribers[queueID] = make(map[chan struct{}]struct{})
+	}
+	n.subscribers[queueID][ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		delete(n.subscribers[queueID], ch)
+		if len(n.subscribers[queueID]) == 0 {
+			delete(n.subscribers, queueID)
+		}
+	}
+}
+
+// PostgresNotifier fans notifications out to every API replica through
+// Postgres LISTEN/NOTIFY
+type PostgresNotifier struct {
+	db        *bun.DB
+	local     *LocalNotifier
+	listening atomic.Bool // set while Run forwards notifications to local subscribers
+}
+
+func NewPostgresNotifier(db *bun.DB) *PostgresNotifier {
+	return &PostgresNotifier{db: db, local: NewLocalNotifier()}
+}
+
+func (n *PostgresNotifier) Notify(ctx context.Context, queueID int64) {
+	err := pgdriver.Notify(ctx, n.db, notifyChannel, strconv.FormatInt(queueID, 10))
+	if err != nil {
+		log.Printf("Notifier: failed to notify queue %d: %v", queueID, err)
+	}
+
+	// The notification only comes back to this replica's subscribers through Run
+	if err != nil || !n.listening.Load() {
+		n.local.Notify(ctx, queueID)
+	}
+}
+
+func (n *PostgresNotifier) Subscribe(queueID int64) (<-chan struct{}, func()) {
+	return n.local.Subscribe(queueID)
+}
+
+// Run listens for notifications from all replicas, including this one, and
+// forwards them to local subscribers. When listening fails it listens again after
+// a growing backoff; meanwhile Notify wakes this replica's subscribers directly. It
+// blocks until ctx is cancelled.
+func (n *PostgresNotifier) Run(ctx context.Context) {
+	backoff := listenMinBackoff
+	for {
+		started := time.Now()
+		err := n.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A listener that worked for a while starts the backoff over
+		if time.Since(started) > listenMaxBackoff {
+			backoff = listenMinBackoff
+		}
+		log.Printf("Notifier: %v; listening again in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, listenMaxBackoff)
+	}
+}
+
+// listen forwards notifications to local subscribers until the listener stops or
+// ctx is cancelled
+func (n *PostgresNotifier) listen(ctx context.Context) error {
+	ln := pgdriver.NewListener(n.db)
+	defer ln.Close()
+
+	if err := ln.Listen(ctx, notifyChannel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", notifyChannel, err)
+	}
+
+	n.listening.Store(true)
+	defer n.listening.Store(false)
+
+	// Other replicas' notifications sent while not listening were missed, so every
+	// waiter checks its queue again
+	n.local.notifyAll()
+
+	ch := ln.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case notification, ok := <-ch:
+			if !ok {
+				return errors.New("listener closed")
+			}
+			queueID, err := strconv.ParseInt(notification.Payload, 10, 64)
+			if err != nil {
+				continue
+			}
+			n.local.Notify(ctx, queueID)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// unreachableDB returns a database nothing listens on, so every query fails at once
+func unreachableDB(t *testing.T) *bun.DB {
+	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithAddr("127.0.0.1:1"), pgdriver.WithTimeout(time.Second)))
+	db := bun.NewDB(sqldb, pgdialect.New())
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func expectWakeup(t *testing.T, ch <-chan struct{}, want bool) {
+	t.Helper()
+
+	select {
+	case <-ch:
+		if !want {
+			t.Fatal("subscriber was woken")
+		}
+	case <-time.After(50 * time.Millisecond):
+		if want {
+			t.Fatal("subscriber was not woken")
+		}
+	}
+}
+
+func TestLocalNotifier(t *testing.T) {
+	ctx := context.Background()
+	n := NewLocalNotifier()
+
+	ch, stop := n.Subscribe(1)
+	other, stopOther := n.Subscribe(2)
+	defer stopOther()
+
+	n.Notify(ctx, 1)
+	n.Notify(ctx, 1) // coalesced with the pending wakeup
+	expectWakeup(t, ch, true)
+	expectWakeup(t, ch, false)
+	expectWakeup(t, other, false)
+
+	n.notifyAll()
+	expectWakeup(t, ch, true)
+	expectWakeup(t, other, true)
+
+	stop()
+	n.Notify(ctx, 1)
+	expectWakeup(t, ch, false)
+}
+
+func TestPostgresNotifierWakesLocallyWhileNotListening(t *testing.T) {
+	n := NewPostgresNotifier(unreachableDB(t))
+
+	ch, stop := n.Subscribe(1)
+	defer stop()
+
+	n.Notify(context.Background(), 1)
+	expectWakeup(t, ch, true)
+}
+
+func TestPostgresNotifierRunRetriesUntilCancelled(t *testing.T) {
+	n := NewPostgresNotifier(unreachableDB(t))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+
+	// Listening fails at once, and Run waits to try again instead of returning
+	select {
+	case <-done:
+		t.Fatal("Run returned after the listener failed")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if n.listening.Load() {
+		t.Fatal("notifier reports listening without a database")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after ctx was cancelled")
+	}
+}
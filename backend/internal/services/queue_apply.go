@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/queuetype"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// defaultManager owns the queues of an apply request that names no manager
+const defaultManager = "default"
+
+// applyStep is a planned change with what ApplyQueues needs to carry it out
+type applyStep struct {
+	change *models.ApplyChange
+	create *models.CreateQueueRequest // nil for a dead letter queue created along with the step before
+	update *models.UpdateQueueRequest
+	queue  *models.Queue // queue updated or deleted
+}
+
+// ApplyQueues brings the queues declared in req in line with the queues table. It
+// creates the declared queues that do not exist, updates those whose description or
+// config differ and, with req.Prune, deletes the queues of the manager that req no
+// longer declares. Queues owned by no or another manager are never changed, except
+// that req.Adopt takes over the unmanaged queues req declares. Dead letter queues
+// created for declared queues are planned too, and owned by the manager. Applying
+// the same request again changes nothing. With dryRun set the changes are only planned.
+//
+// Changes are applied one at a time, so a failed apply leaves the changes before
+// the failing one in place; applying the request again picks up from there.
+func (s *QueueService) ApplyQueues(ctx context.Context, req *models.ApplyQueuesRequest, dryRun bool) (*models.ApplyPlan, error) {
+	plan, steps, err := s.planApply(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.DryRun = dryRun
+	if dryRun {
+		return plan, nil
+	}
+
+	for _, step := range steps {
+		switch step.change.Action {
+		case models.ApplyCreate:
+			var queue *models.Queue
+			var err error
+			if step.create != nil {
+				queue, err = s.createQueue(ctx, step.create, plan.Manager)
+			} else {
+				queue, err = s.catalog.GetQueueByName(ctx, step.change.Name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to create queue %q: %w", step.change.Name, err)
+			}
+			step.change.QueueID = &queue.ID
+
+		case models.ApplyUpdate:
+			if _, err := s.updateQueue(ctx, step.queue.ID, step.update, step.queue.Version, false, plan.Manager); err != nil {
+				return nil, fmt.Errorf("failed to update queue %q: %w", step.change.Name, err)
+			}
+
+		case models.ApplyDelete:
+			if _, err := s.DeleteQueue(ctx, step.queue.ID, req.DeleteMode); err != nil {
+				return nil, fmt.Errorf("failed to delete queue %q: %w", step.change.Name, err)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// planApply diffs the queues declared in req against the stored queues. Every
+// problem with the declarations is reported at once, as a ConfigError wrapped in
+// ErrInvalidQueueSpec.
+func (s *QueueService) planApply(ctx context.Context, req *models.ApplyQueuesRequest) (*models.ApplyPlan, []*applyStep, error) {
+	manager := strings.TrimSpace(req.Manager)
+	if manager == "" {
+		manager = defaultManager
+	}
+
+	var problems []models.FieldError
+	switch req.DeleteMode {
+	case "", models.QueueDeleteRefuse, models.QueueDeleteCascade, models.QueueDeleteArchive:
+	default:
+		problems = append(problems, models.FieldError{Field: "delete_mode", Message: "must be refuse, cascade or archive"})
+	}
+
+	// Deleted queues keep their name until they are purged
+	queues, err := s.catalog.ListQueues(ctx, storage.QueueListOptions{Deleted: storage.IncludeDeleted})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].ID < queues[j].ID })
+	existing := make(map[string]*models.Queue, len(queues))
+	for _, queue := range queues {
+		existing[queue.Name] = queue
+	}
+
+	var creates, updates, unchanged []*applyStep
+	declared := make(map[string]bool, len(req.Queues))
+	dlqOf := make(map[string]string, len(req.Queues)) // declared queue name to its DLQ name
+
+	for i := range req.Queues {
+		spec := &req.Queues[i]
+		field := fmt.Sprintf("queues[%d]", i)
+
+		name := strings.TrimSpace(spec.Name)
+		if name == "" {
+			problems = append(problems, models.FieldError{Field: field + ".name", Message: "is required"})
+			continue
+		}
+		if declared[name] {
+			problems = append(problems, models.FieldError{Field: field + ".name", Message: fmt.Sprintf("queue %q is declared more than once", name)})
+			continue
+		}
+		declared[name] = true
+
+		typ, err := queuetype.Lookup(spec.Type)
+		if err != nil {
+			problems = append(problems, models.FieldError{Field: field + ".type", Message: err.Error()})
+			continue
+		}
+
+		config, err := specConfig(spec)
+		if err != nil {
+			problems = append(problems, models.FieldError{Field: field + ".config", Message: err.Error()})
+			continue
+		}
+		cfg, err := validateQueueConfig(name, config, typ)
+		if err != nil {
+			problems = append(problems, specConfigErrors(field+".config", err)...)
+			continue
+		}
+		dlqOf[name] = cfg.DeadLetterQueueName(name)
+
+		queue := existing[name]
+		if queue == nil {
+			change := &models.ApplyChange{Action: models.ApplyCreate, Name: name, Fields: []models.FieldChange{{Field: "type", To: spec.Type}}}
+			if spec.Description != "" {
+				change.Fields = append(change.Fields, models.FieldChange{Field: "description", To: spec.Description})
+			}
+			change.Fields = append(change.Fields, models.FieldChange{Field: "config", To: config})
+			creates = append(creates, &applyStep{change: change, create: &models.CreateQueueRequest{
+				Name:        name,
+				Description: spec.Description,
+				Type:        spec.Type,
+				Config:      config,
+			}})
+			continue
+		}
+
+		switch {
+		case queue.DeletedAt != nil:
+			problems = append(problems, models.FieldError{Field: field + ".name", Message: fmt.Sprintf("queue %q is deleted; restore it or wait until it is purged", name)})
+			continue
+		case queue.ManagedBy == "" && !req.Adopt:
+			problems = append(problems, models.FieldError{Field: field + ".name", Message: fmt.Sprintf("queue %q exists and is not managed; adopt it to take it over", name)})
+			continue
+		case queue.ManagedBy != "" && queue.ManagedBy != manager:
+			problems = append(problems, models.FieldError{Field: field + ".name", Message: fmt.Sprintf("queue %q is managed by %q", name, queue.ManagedBy)})
+			continue
+		case queue.Type != spec.Type:
+			problems = append(problems, models.FieldError{Field: field + ".type", Message: fmt.Sprintf("cannot change from %s to %s; delete the queue first", queue.Type, spec.Type)})
+			continue
+		}
+
+		step := &applyStep{
+			change: &models.ApplyChange{Action: models.ApplyUpdate, Name: name, QueueID: &queue.ID},
+			update: &models.UpdateQueueRequest{},
+			queue:  queue,
+		}
+		if queue.Description != spec.Description {
+			step.update.Description = &spec.Description
+			step.change.Fields = append(step.change.Fields, models.FieldChange{Field: "description", From: queue.Description, To: spec.Description})
+		}
+		current, err := canonicalConfig(queue.Config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read config of queue %q: %w", name, err)
+		}
+		if current != config {
+			step.update.Config = &config
+			step.change.Fields = append(step.change.Fields, models.FieldChange{Field: "config", From: current, To: config})
+		}
+		if queue.ManagedBy != manager {
+			step.change.Fields = append(step.change.Fields, models.FieldChange{Field: "managed_by", To: manager})
+		}
+
+		if len(step.change.Fields) == 0 {
+			step.change.Action = models.ApplyUnchanged
+			unchanged = append(unchanged, step)
+		} else {
+			updates = append(updates, step)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidQueueSpec, &models.ConfigError{Errors: problems})
+	}
+
+	// Dead letter queues of declared queues stay even when they are not declared
+	usedAsDLQ := make(map[string]bool, len(dlqOf))
+	for _, name := range dlqOf {
+		usedAsDLQ[name] = true
+	}
+
+	var deletes []*applyStep
+	if req.Prune {
+		pruned := make(map[int64]*applyStep)
+		for _, queue := range queues {
+			if queue.DeletedAt != nil || queue.ManagedBy != manager || declared[queue.Name] || usedAsDLQ[queue.Name] {
+				continue
+			}
+			pruned[queue.ID] = &applyStep{
+				change: &models.ApplyChange{Action: models.ApplyDelete, Name: queue.Name, QueueID: &queue.ID},
+				queue:  queue,
+			}
+		}
+
+		// A queue cannot be deleted while it is the dead letter queue of another, so
+		// pruned queues go before their pruned dead letter queues
+		for _, queue := range queues {
+			if step := pruned[queue.ID]; step != nil {
+				deletes = appendAfterDependency(deletes, step, func(step *applyStep) *applyStep {
+					if step.queue.DeadLetterQueueID == nil || *step.queue.DeadLetterQueueID == step.queue.ID {
+						return nil
+					}
+					return pruned[*step.queue.DeadLetterQueueID]
+				})
+			}
+		}
+		slices.Reverse(deletes)
+	}
+
+	// A declared dead letter queue is created before the queues using it, or creating
+	// them would create it with defaults first
+	var orderedCreates []*applyStep
+	createsByName := make(map[string]*applyStep, len(creates))
+	for _, step := range creates {
+		createsByName[step.change.Name] = step
+	}
+	for _, step := range creates {
+		orderedCreates = appendAfterDependency(orderedCreates, step, func(step *applyStep) *applyStep {
+			return createsByName[dlqOf[step.change.Name]]
+		})
+	}
+
+	// Creating or reconfiguring a queue creates its dead letter queue when no queue
+	// has that name yet, so that create follows the step causing it
+	var steps []*applyStep
+	implicit := make(map[string]bool)
+	for _, group := range [][]*applyStep{orderedCreates, updates} {
+		for _, step := range group {
+			steps = append(steps, step)
+
+			source := &models.Queue{Name: step.change.Name, ManagedBy: manager}
+			switch {
+			case step.create != nil:
+				source.Type = step.create.Type
+			case step.update.Config != nil:
+				source.Type = step.queue.Type
+			default:
+				continue
+			}
+
+			name := dlqOf[source.Name]
+			if existing[name] != nil || declared[name] || implicit[name] {
+				continue
+			}
+			implicit[name] = true
+
+			dlq := newDeadLetterQueue(name, source)
+			steps = append(steps, &applyStep{change: &models.ApplyChange{Action: models.ApplyCreate, Name: name, Fields: []models.FieldChange{
+				{Field: "type", To: dlq.Type},
+				{Field: "description", To: dlq.Description},
+				{Field: "config", To: dlq.Config},
+			}}})
+		}
+	}
+	steps = append(steps, deletes...)
+
+	// Changes never grows past its capacity, so steps can point into it and creates
+	// fill in the queue ID of the change they report
+	plan := &models.ApplyPlan{Manager: manager, Changes: make([]models.ApplyChange, 0, len(steps)+len(unchanged))}
+	for _, step := range steps {
+		plan.Changes = append(plan.Changes, *step.change)
+		step.change = &plan.Changes[len(plan.Changes)-1]
+	}
+	for _, step := range unchanged {
+		plan.Changes = append(plan.Changes, *step.change)
+	}
+
+	return plan, steps, nil
+}
+
+// appendAfterDependency appends step to ordered, preceded by the step it depends
+// on, and so on, skipping steps already in ordered. Cycles are broken arbitrarily.
+func appendAfterDependency(ordered []*applyStep, step *applyStep, dependency func(*applyStep) *applyStep) []*applyStep {
+	var chain []*applyStep
+	for s := step; s != nil && !slices.Contains(ordered, s) && !slices.Contains(chain, s); s = dependency(s) {
+		chain = append(chain, s)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		ordered = append(ordered, chain[i])
+	}
+	return ordered
+}
+
+// specConfig merges the config of spec with its dead_letter_queue and retry_policy
+// shorthands into a canonical config document
+func specConfig(spec *models.QueueSpec) (string, error) {
+	doc := map[string]json.RawMessage{}
+	if isSet(spec.Config) {
+		if err := json.Unmarshal(spec.Config, &doc); err != nil {
+			return "", errors.New("must be a JSON object")
+		}
+	}
+
+	if spec.DeadLetterQueue != "" {
+		if _, ok := doc["dead_letter_queue"]; ok {
+			return "", errors.New("dead_letter_queue is set both on the queue and in its config")
+		}
+		doc["dead_letter_queue"], _ = json.Marshal(spec.DeadLetterQueue)
+	}
+	if isSet(spec.RetryPolicy) {
+		if _, ok := doc["retry_policy"]; ok {
+			return "", errors.New("retry_policy is set both on the queue and in its config")
+		}
+		doc["retry_policy"] = spec.RetryPolicy
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return canonicalConfig(string(data))
+}
+
+// canonicalConfig re-encodes a config document with sorted keys and without
+// insignificant whitespace, so equal configs compare equal as strings
+func canonicalConfig(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "{}", nil
+	}
+
+	var doc any
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// specConfigErrors turns a config validation error into field errors under prefix
+func specConfigErrors(prefix string, err error) []models.FieldError {
+	var configErr *models.ConfigError
+	if !errors.As(err, &configErr) {
+		return []models.FieldError{{Field: prefix, Message: err.Error()}}
+	}
+
+	problems := make([]models.FieldError, len(configErr.Errors))
+	for i, fe := range configErr.Errors {
+		problems[i] = models.FieldError{Field: prefix, Message: fe.Message}
+		if fe.Field != "" {
+			problems[i].Field += "." + fe.Field
+		}
+	}
+	return problems
+}
+
+func isSet(raw json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(raw))
+	return trimmed != "" && trimmed != "null"
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/shravan20/qafka/internal/models"
+)
+
+// planned returns the action and name of every change of plan
+func planned(plan *models.ApplyPlan) []string {
+	changes := make([]string, len(plan.Changes))
+	for i, change := range plan.Changes {
+		changes[i] = change.Action + " " + change.Name
+	}
+	return changes
+}
+
+func TestApplyQueuesCreatesDeadLetterQueues(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	req := &models.ApplyQueuesRequest{
+		Manager: "billing",
+		Queues: []models.QueueSpec{
+			{Name: "invoices", Type: "delay", Config: []byte(`{"default_delay_seconds": 5}`)},
+			{Name: "refunds", Type: "fifo", DeadLetterQueue: "failures"},
+			{Name: "failures", Type: "fifo"},
+			{Name: "receipts", Type: "fifo", DeadLetterQueue: "invoices-dlq"},
+		},
+	}
+
+	plan, err := s.ApplyQueues(ctx, req, true)
+	if err != nil {
+		t.Fatalf("ApplyQueues dry run: %v", err)
+	}
+	// The implicit dead letter queue follows the first queue creating it; the
+	// declared one is created before the queue using it
+	want := []string{"create invoices", "create invoices-dlq", "create failures", "create failures-dlq", "create refunds", "create receipts"}
+	if got := planned(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("dry run planned %q, want %q", got, want)
+	}
+	if fields := plan.Changes[1].Fields; len(fields) == 0 || fields[0] != (models.FieldChange{Field: "type", To: "fifo"}) {
+		t.Fatalf("implicit dead letter queue of a delay queue planned with %+v, want type fifo", fields)
+	}
+	if queues, _ := s.GetQueues(ctx); len(queues) != 0 {
+		t.Fatalf("dry run created %d queues", len(queues))
+	}
+
+	plan, err = s.ApplyQueues(ctx, req, false)
+	if err != nil {
+		t.Fatalf("ApplyQueues: %v", err)
+	}
+	if got := planned(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("apply planned %q, want %q", got, want)
+	}
+	for _, change := range plan.Changes {
+		if change.QueueID == nil {
+			t.Fatalf("created queue %q has no ID in the plan", change.Name)
+		}
+		queue, err := s.GetQueue(ctx, *change.QueueID)
+		if err != nil {
+			t.Fatalf("GetQueue(%q): %v", change.Name, err)
+		}
+		if queue.Name != change.Name || queue.ManagedBy != "billing" {
+			t.Fatalf("plan reports queue %d as %q, stored as %q managed by %q", queue.ID, change.Name, queue.Name, queue.ManagedBy)
+		}
+	}
+	if queues, _ := s.GetQueues(ctx); len(queues) != len(want) {
+		t.Fatalf("apply created %d queues, want %d", len(queues), len(want))
+	}
+
+	// The implicit dead letter queues are neither changed nor pruned on reapply
+	req.Prune = true
+	plan, err = s.ApplyQueues(ctx, req, false)
+	if err != nil {
+		t.Fatalf("ApplyQueues again: %v", err)
+	}
+	want = []string{"unchanged invoices", "unchanged refunds", "unchanged failures", "unchanged receipts"}
+	if got := planned(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reapply planned %q, want %q", got, want)
+	}
+
+	// Dropping a queue prunes its dead letter queue after it
+	req.Queues = req.Queues[1:3]
+	plan, err = s.ApplyQueues(ctx, req, true)
+	if err != nil {
+		t.Fatalf("ApplyQueues with a queue dropped: %v", err)
+	}
+	want = []string{"delete receipts", "delete invoices", "delete invoices-dlq", "unchanged refunds", "unchanged failures"}
+	if got := planned(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("prune planned %q, want %q", got, want)
+	}
+}
+
+func TestApplyQueuesUpdateCreatesDeadLetterQueue(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	req := &models.ApplyQueuesRequest{Manager: "billing", Queues: []models.QueueSpec{{Name: "orders", Type: "fifo"}}}
+	if _, err := s.ApplyQueues(ctx, req, false); err != nil {
+		t.Fatalf("ApplyQueues: %v", err)
+	}
+
+	req.Queues[0].DeadLetterQueue = "orders-failed"
+	plan, err := s.ApplyQueues(ctx, req, false)
+	if err != nil {
+		t.Fatalf("ApplyQueues with a new dead letter queue: %v", err)
+	}
+	want := []string{"update orders", "create orders-failed"}
+	if got := planned(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("apply planned %q, want %q", got, want)
+	}
+
+	dlq, err := s.GetQueue(ctx, *plan.Changes[1].QueueID)
+	if err != nil {
+		t.Fatalf("GetQueue: %v", err)
+	}
+	if dlq.Name != "orders-failed" || dlq.ManagedBy != "billing" {
+		t.Fatalf("dead letter queue stored as %q managed by %q", dlq.Name, dlq.ManagedBy)
+	}
+}
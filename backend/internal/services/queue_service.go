@@ -2,216 +2,1259 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/shravan20/qafka/internal/config"
 	"github.com/shravan20/qafka/internal/models"
-	"github.com/uptrace/bun"
+	"github.com/shravan20/qafka/internal/queuetype"
+	"github.com/shravan20/qafka/internal/retry"
+	"github.com/shravan20/qafka/internal/selector"
+	"github.com/shravan20/qafka/internal/storage"
 )
 
+// purgeChunksPerCall bounds the work PurgeDeletedQueues does per deleted queue and call
+const purgeChunksPerCall = 10
+
 type QueueService struct {
-	db *bun.DB
+	catalog    storage.Catalog
+	backend    storage.Backend
+	notifier   Notifier
+	cfg        *config.Config
+	limiter    *rateLimiter
+	operations *bulkOperations
+	scheduled  chan struct{} // signalled when a message is scheduled, to wake the scheduler
 }
 
-func NewQueueService(db *bun.DB) *QueueService {
-	return &QueueService{db: db}
+func NewQueueService(catalog storage.Catalog, backend storage.Backend, notifier Notifier, cfg *config.Config) *QueueService {
+	return &QueueService{
+		catalog:    catalog,
+		backend:    backend,
+		notifier:   notifier,
+		cfg:        cfg,
+		limiter:    newRateLimiter(),
+		operations: newBulkOperations(),
+		scheduled:  make(chan struct{}, 1),
+	}
 }
 
 // Queue operations
 func (s *QueueService) CreateQueue(ctx context.Context, req *models.CreateQueueRequest) (*models.Queue, error) {
+	return s.createQueue(ctx, req, "")
+}
+
+// createQueue creates a queue owned by manager, or an unmanaged one when manager is empty
+func (s *QueueService) createQueue(ctx context.Context, req *models.CreateQueueRequest, manager string) (*models.Queue, error) {
+	queue, err := s.ValidateQueue(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	queue.ManagedBy = manager
+
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.catalog.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		dlq, err := s.ensureDeadLetterQueue(ctx, tx, cfg.DeadLetterQueueName(queue.Name), queue)
+		if err != nil {
+			return err
+		}
+		queue.DeadLetterQueueID = &dlq.ID
+
+		return tx.InsertQueue(ctx, queue)
+	})
+	if errors.Is(err, storage.ErrNameTaken) {
+		// Created concurrently since ValidateQueue checked the name
+		return nil, fmt.Errorf("%w: %q", ErrQueueExists, req.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create queue: %w", err)
+	}
+
+	return queue, nil
+}
+
+// ValidateQueue checks a queue creation request without creating anything. It
+// returns the queue CreateQueue would create.
+func (s *QueueService) ValidateQueue(ctx context.Context, req *models.CreateQueueRequest) (*models.Queue, error) {
+	typ, err := queuetype.Lookup(req.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQueueType, err)
+	}
+
+	if _, err := validateQueueConfig(req.Name, req.Config, typ); err != nil {
+		return nil, err
+	}
+
+	// A deleted queue keeps its name until it is purged, so it can be restored
+	_, err = s.catalog.GetQueueByName(ctx, req.Name)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %q", ErrQueueExists, req.Name)
+	}
+	if !errors.Is(err, ErrQueueNotFound) {
+		return nil, fmt.Errorf("failed to check queue name: %w", err)
+	}
+
+	raw := req.Config
+	if raw == "" {
+		raw = "{}"
+	}
+
 	queue := &models.Queue{
 		Name:        req.Name,
 		Description: req.Description,
 		Type:        req.Type,
-		Config:      req.Config,
+		Config:      raw,
 		IsActive:    true,
+		State:       models.QueueStateActive,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	_, err := s.db.NewInsert().Model(queue).Exec(ctx)
+	return queue, nil
+}
+
+// ensureDeadLetterQueue returns the queue with the given name, creating it as a
+// dead letter queue for source, owned by the same manager, when it does not exist yet
+func (s *QueueService) ensureDeadLetterQueue(ctx context.Context, tx storage.Catalog, name string, source *models.Queue) (*models.Queue, error) {
+	dlq, err := tx.GetQueueByName(ctx, name)
+	if err == nil && dlq.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: dead letter queue %q is deleted", ErrQueueExists, name)
+	}
+	if err == nil {
+		return dlq, nil
+	}
+	if !errors.Is(err, ErrQueueNotFound) {
+		return nil, err
+	}
+
+	dlq = newDeadLetterQueue(name, source)
+	dlq.CreatedAt = time.Now()
+	dlq.UpdatedAt = dlq.CreatedAt
+
+	if err := tx.InsertQueue(ctx, dlq); err != nil {
+		return nil, err
+	}
+
+	return dlq, nil
+}
+
+// newDeadLetterQueue returns the queue ensureDeadLetterQueue creates for source
+func newDeadLetterQueue(name string, source *models.Queue) *models.Queue {
+	// A delay DLQ would need its own default delay, and dead letters should not wait
+	dlqType := source.Type
+	if dlqType == queuetype.Delay {
+		dlqType = queuetype.FIFO
+	}
+
+	return &models.Queue{
+		Name:        name,
+		Description: "Dead letter queue for " + source.Name,
+		Type:        dlqType,
+		Config:      "{}",
+		IsActive:    true,
+		State:       models.QueueStateActive,
+		ManagedBy:   source.ManagedBy,
+	}
+}
+
+func (s *QueueService) GetQueues(ctx context.Context) ([]*models.Queue, error) {
+	return s.catalog.ListQueues(ctx, storage.QueueListOptions{})
+}
+
+func (s *QueueService) GetQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	return s.catalog.GetQueue(ctx, id)
+}
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// UpdateQueue applies the fields set in req to a queue and increments its version.
+// When ifVersion is positive the update only succeeds while the queue is still at
+// that version. A config naming another dead letter queue links it, creating it
+// if needed. With dryRun set nothing is persisted and the updated queue is only
+// returned.
+func (s *QueueService) UpdateQueue(ctx context.Context, id int64, req *models.UpdateQueueRequest, ifVersion int64, dryRun bool) (*models.Queue, error) {
+	return s.updateQueue(ctx, id, req, ifVersion, dryRun, "")
+}
+
+// updateQueue is UpdateQueue, also making manager the owner of the queue unless it is empty
+func (s *QueueService) updateQueue(ctx context.Context, id int64, req *models.UpdateQueueRequest, ifVersion int64, dryRun bool, manager string) (*models.Queue, error) {
+	var queue *models.Queue
+
+	err := s.catalog.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		var err error
+		queue, err = lockQueue(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if ifVersion > 0 && queue.Version != ifVersion {
+			return fmt.Errorf("%w: queue is at version %d, not %d", ErrVersionConflict, queue.Version, ifVersion)
+		}
+
+		if req.Description != nil {
+			queue.Description = *req.Description
+		}
+		if req.IsActive != nil {
+			queue.State = models.QueueStateActive
+			if !*req.IsActive {
+				queue.State = models.QueueStatePaused
+			}
+			queue.IsActive = *req.IsActive
+		}
+		// Before the config, so a dead letter queue it creates has the same owner
+		if manager != "" {
+			queue.ManagedBy = manager
+		}
+		if req.Config != nil {
+			cfg, err := validateQueueConfig(queue.Name, *req.Config, queueType(queue))
+			if err != nil {
+				return err
+			}
+
+			queue.Config = *req.Config
+			if queue.Config == "" {
+				queue.Config = "{}"
+			}
+
+			dlq, err := s.ensureDeadLetterQueue(ctx, tx, cfg.DeadLetterQueueName(queue.Name), queue)
+			if err != nil {
+				return err
+			}
+			queue.DeadLetterQueueID = &dlq.ID
+		}
+
+		queue.Version++
+		queue.UpdatedAt = time.Now()
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return tx.UpdateQueue(ctx, queue)
+	})
+	if errors.Is(err, errDryRun) {
+		return queue, nil
+	}
+	if errors.Is(err, ErrQueueNotFound) || errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrInvalidQueueConfig) || errors.Is(err, ErrQueueExists) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update queue: %w", err)
+	}
+
+	if queue.State == models.QueueStateActive {
+		s.notifier.Notify(ctx, queue.ID)
+	}
+
+	return queue, nil
+}
+
+// PauseQueue stops a queue from handing out claims. It keeps accepting produces.
+func (s *QueueService) PauseQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	return s.setQueueState(ctx, id, models.QueueStatePaused)
+}
+
+// ResumeQueue returns a paused or draining queue to the active state and wakes
+// consumers waiting on it
+func (s *QueueService) ResumeQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	queue, err := s.setQueueState(ctx, id, models.QueueStateActive)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create queue: %w", err)
+		return nil, err
 	}
 
+	s.notifier.Notify(ctx, queue.ID)
 	return queue, nil
 }
 
-func (s *QueueService) GetQueues(ctx context.Context) ([]*models.Queue, error) {
-	var queues []*models.Queue
-	err := s.db.NewSelect().Model(&queues).Order("created_at DESC").Scan(ctx)
+// DrainQueue makes a queue reject new produces while consumers empty it
+func (s *QueueService) DrainQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	return s.setQueueState(ctx, id, models.QueueStateDraining)
+}
+
+func (s *QueueService) setQueueState(ctx context.Context, id int64, state string) (*models.Queue, error) {
+	var queue *models.Queue
+	err := s.catalog.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		var err error
+		queue, err = lockQueue(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		queue.State = state
+		queue.IsActive = state == models.QueueStateActive
+		queue.Version++
+		queue.UpdatedAt = time.Now()
+		return tx.UpdateQueue(ctx, queue)
+	})
+	if errors.Is(err, ErrQueueNotFound) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get queues: %w", err)
+		return nil, fmt.Errorf("failed to set queue state: %w", err)
 	}
-	return queues, nil
+	return queue, nil
 }
 
-func (s *QueueService) GetQueue(ctx context.Context, id int64) (*models.Queue, error) {
-	queue := &models.Queue{}
-	err := s.db.NewSelect().Model(queue).Where("id = ?", id).Scan(ctx)
+// lockQueue returns a queue that is not deleted, locked until the transaction ends
+func lockQueue(ctx context.Context, tx storage.Catalog, id int64) (*models.Queue, error) {
+	queue, err := tx.GetQueueForUpdate(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get queue: %w", err)
+		return nil, err
+	}
+	if queue.DeletedAt != nil {
+		return nil, ErrQueueNotFound
 	}
 	return queue, nil
 }
 
-func (s *QueueService) UpdateQueue(ctx context.Context, id int64, updates map[string]interface{}) (*models.Queue, error) {
-	updates["updated_at"] = time.Now()
+// DeleteQueue marks a queue as deleted. Deleted queues accept no produces or claims
+// but keep their name and messages for the grace period, during which RestoreQueue
+// undoes the delete. After it, PurgeDeletedQueues disposes of the messages as mode
+// says and removes the queue for good.
+func (s *QueueService) DeleteQueue(ctx context.Context, id int64, mode string) (*models.Queue, error) {
+	switch mode {
+	case "":
+		mode = models.QueueDeleteRefuse
+	case models.QueueDeleteRefuse, models.QueueDeleteCascade, models.QueueDeleteArchive:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDeleteMode, mode)
+	}
+
+	var queue *models.Queue
+	err := s.catalog.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		var err error
+		queue, err = lockQueue(ctx, tx, id)
+		if err != nil {
+			return err
+		}
 
-	_, err := s.db.NewUpdate().Model((*models.Queue)(nil)).
-		Set("updated_at = ?", time.Now()).
-		Where("id = ?", id).
-		Exec(ctx)
+		users, err := tx.ListQueues(ctx, storage.QueueListOptions{DeadLetterQueueID: id})
+		if err != nil {
+			return err
+		}
+		var sources []string
+		for _, user := range users {
+			if user.ID != id {
+				sources = append(sources, user.Name)
+			}
+		}
+		if len(sources) > 0 {
+			return fmt.Errorf("%w: it is the dead letter queue of %s", ErrQueueInUse, strings.Join(sources, ", "))
+		}
 
+		if mode == models.QueueDeleteRefuse {
+			held, err := s.liveMessages(ctx, id)
+			if err != nil {
+				return err
+			}
+			if held > 0 {
+				return fmt.Errorf("%w: it holds %d messages", ErrQueueNotEmpty, held)
+			}
+		}
+
+		now := time.Now()
+		queue.DeletedAt = &now
+		queue.DeleteMode = mode
+		queue.Version++
+		queue.UpdatedAt = now
+		return tx.UpdateQueue(ctx, queue)
+	})
+	if errors.Is(err, ErrQueueNotFound) || errors.Is(err, ErrQueueInUse) || errors.Is(err, ErrQueueNotEmpty) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to update queue: %w", err)
+		return nil, fmt.Errorf("failed to delete queue: %w", err)
+	}
+
+	// A produce that read the queue before the delete committed can still land;
+	// count again now that no new one can and undo the delete if one did
+	if mode == models.QueueDeleteRefuse {
+		held, err := s.liveMessages(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete queue: %w", err)
+		}
+		if held > 0 {
+			if _, err := s.RestoreQueue(ctx, id); err != nil && !errors.Is(err, ErrQueueNotFound) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: it holds %d messages", ErrQueueNotEmpty, held)
+		}
 	}
 
-	return s.GetQueue(ctx, id)
+	return queue, nil
 }
 
-func (s *QueueService) DeleteQueue(ctx context.Context, id int64) error {
-	_, err := s.db.NewDelete().Model((*models.Queue)(nil)).Where("id = ?", id).Exec(ctx)
+// liveMessages counts the messages of a queue that refuse mode keeps a delete from
+// dropping: those waiting for delivery or a retry and those being processed
+func (s *QueueService) liveMessages(ctx context.Context, id int64) (int64, error) {
+	depth, err := s.backend.Depth(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete queue: %w", err)
+		return 0, err
 	}
-	return nil
+	return depth[models.MessageStatusPending] + depth[models.MessageStatusScheduled] +
+		depth[models.MessageStatusProcessing] + depth[models.MessageStatusFailed], nil
+}
+
+// GetDeletedQueues returns the deleted queues that can still be restored
+func (s *QueueService) GetDeletedQueues(ctx context.Context) ([]*models.Queue, error) {
+	return s.catalog.ListQueues(ctx, storage.QueueListOptions{Deleted: storage.OnlyDeleted})
+}
+
+// RestoreQueue undoes the deletion of a queue that has not been purged yet
+func (s *QueueService) RestoreQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	var queue *models.Queue
+	err := s.catalog.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		var err error
+		queue, err = tx.GetQueueForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if queue.DeletedAt == nil {
+			return ErrQueueNotFound
+		}
+
+		queue.DeletedAt = nil
+		queue.DeleteMode = ""
+		queue.Version++
+		queue.UpdatedAt = time.Now()
+		return tx.UpdateQueue(ctx, queue)
+	})
+	if errors.Is(err, ErrQueueNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore queue: %w", err)
+	}
+
+	if queue.State == models.QueueStateActive {
+		s.notifier.Notify(ctx, queue.ID)
+	}
+
+	return queue, nil
+}
+
+// PurgeDeletedQueues removes the queues deleted longer than the grace period ago,
+// with their messages. Large queues are purged over several calls, a bounded number
+// of chunks at a time. It returns the number of queues removed.
+func (s *QueueService) PurgeDeletedQueues(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-s.cfg.QueueDeleteGrace)
+	queues, err := s.catalog.ListQueues(ctx, storage.QueueListOptions{Deleted: storage.OnlyDeleted, DeletedBefore: &before})
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, queue := range queues {
+		done, err := s.purgeDeletedQueue(ctx, queue)
+		if err != nil {
+			return purged, err
+		}
+		if done {
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeDeletedQueue disposes of up to purgeChunksPerCall chunks of a deleted queue's
+// messages and removes the queue once none is left. It reports whether it did.
+func (s *QueueService) purgeDeletedQueue(ctx context.Context, queue *models.Queue) (bool, error) {
+	// A produce still in flight when a refuse mode delete was checked may have
+	// landed since; keep its messages until the queue is restored
+	if queue.DeleteMode == models.QueueDeleteRefuse {
+		held, err := s.liveMessages(ctx, queue.ID)
+		if err != nil {
+			return false, err
+		}
+		if held > 0 {
+			return false, nil
+		}
+	}
+
+	opts := storage.BulkOptions{
+		Filter: storage.MessageFilter{QueueID: queue.ID},
+		Action: storage.BulkDelete,
+		Limit:  s.cfg.BulkChunkSize,
+	}
+	if queue.DeleteMode == models.QueueDeleteArchive {
+		opts.Action = storage.BulkArchive
+	}
+
+	for i := 0; i < purgeChunksPerCall; i++ {
+		n, err := s.backend.Bulk(ctx, opts)
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	// Rows locked by a concurrent chunk are skipped; wait for the next call
+	depth, err := s.backend.Depth(ctx, queue.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(depth) > 0 {
+		return false, nil
+	}
+
+	if err := s.catalog.DeleteQueue(ctx, queue.ID); err != nil {
+		return false, fmt.Errorf("failed to purge queue: %w", err)
+	}
+
+	return true, nil
 }
 
 // Message operations
 func (s *QueueService) CreateMessage(ctx context.Context, req *models.CreateMessageRequest) (*models.Message, error) {
+	return s.createMessage(ctx, req, 0)
+}
+
+// createMessage is CreateMessage, remembering the dedup_id of the message for at
+// least minDedupWindow even when its queue configures a shorter window
+func (s *QueueService) createMessage(ctx context.Context, req *models.CreateMessageRequest, minDedupWindow time.Duration) (*models.Message, error) {
+	queue, err := s.GetQueue(ctx, req.QueueID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.newMessage(queue, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.admit(ctx, queue, 1); err != nil {
+		return nil, err
+	}
+
+	dedupWindow, err := s.dedupWindow(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.backend.Enqueue(ctx, message, max(dedupWindow, minDedupWindow)); err != nil {
+		return nil, err
+	}
+
+	if message.Status == models.MessageStatusScheduled {
+		s.wakeScheduler()
+	} else {
+		s.notifier.Notify(ctx, message.QueueID)
+	}
+
+	return message, nil
+}
+
+// CreateMessages produces a batch of messages to a queue with a single bulk insert.
+// Items that fail validation are reported in their result and do not prevent the
+// others from being stored.
+func (s *QueueService) CreateMessages(ctx context.Context, queueID int64, reqs []models.CreateMessageRequest) ([]models.BatchMessageResult, error) {
+	queue, err := s.GetQueue(ctx, queueID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BatchMessageResult, len(reqs))
+	messages := make([]*models.Message, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+
+	for i := range reqs {
+		req := reqs[i]
+		req.QueueID = queueID
+		results[i].Index = i
+
+		message, err := s.newMessage(queue, &req)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		messages = append(messages, message)
+		indexes = append(indexes, i)
+	}
+
+	if len(messages) == 0 {
+		return results, nil
+	}
+
+	if err := s.admit(ctx, queue, len(messages)); err != nil {
+		return nil, err
+	}
+
+	dedupWindow, err := s.dedupWindow(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.backend.EnqueueBatch(ctx, messages, dedupWindow); err != nil {
+		return nil, err
+	}
+
+	notify := false
+	for j, message := range messages {
+		results[indexes[j]].Message = message
+		if message.Status == models.MessageStatusScheduled {
+			s.wakeScheduler()
+		} else {
+			notify = true
+		}
+	}
+
+	if notify {
+		s.notifier.Notify(ctx, queueID)
+	}
+
+	return results, nil
+}
+
+// newMessage builds a message for queue from a produce request. It is scheduled when
+// it is delayed, explicitly or by the queue's default delay, and pending otherwise.
+func (s *QueueService) newMessage(queue *models.Queue, req *models.CreateMessageRequest) (*models.Message, error) {
+	if req.Payload == "" {
+		return nil, fmt.Errorf("%w: payload is required", ErrInvalidMessage)
+	}
+	if req.MaxRetries != nil && *req.MaxRetries < 0 {
+		return nil, fmt.Errorf("%w: max_retries must not be negative", ErrInvalidMessage)
+	}
+	if req.DelaySeconds != nil {
+		if *req.DelaySeconds < 0 {
+			return nil, fmt.Errorf("%w: delay_seconds must not be negative", ErrInvalidMessage)
+		}
+		if req.ScheduledAt != nil {
+			return nil, fmt.Errorf("%w: set either scheduled_at or delay_seconds", ErrInvalidMessage)
+		}
+	}
+
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxMessageSizeBytes > 0 && len(req.Payload) > cfg.MaxMessageSizeBytes {
+		return nil, fmt.Errorf("%w: payload is %d bytes, the queue accepts at most %d", ErrMessageTooLarge, len(req.Payload), cfg.MaxMessageSizeBytes)
+	}
+
+	now := time.Now()
 	message := &models.Message{
-		QueueID:     req.QueueID,
+		QueueID:     queue.ID,
 		Payload:     req.Payload,
 		Priority:    req.Priority,
-		Status:      "pending",
+		Status:      models.MessageStatusPending,
 		ScheduledAt: req.ScheduledAt,
-		MaxRetries:  req.MaxRetries,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Headers:     req.Headers,
+		DedupID:     req.DedupID,
+		GroupKey:    req.GroupKey,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	if message.MaxRetries == 0 {
-		message.MaxRetries = 3
+	delay := cfg.DefaultDelaySeconds
+	if req.DelaySeconds != nil {
+		delay = *req.DelaySeconds
+	}
+	if message.ScheduledAt == nil && delay > 0 {
+		due := now.Add(time.Duration(delay) * time.Second)
+		message.ScheduledAt = &due
 	}
 
-	_, err := s.db.NewInsert().Model(message).Exec(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create message: %w", err)
+	switch policy := cfg.Retry(); {
+	case req.MaxRetries != nil:
+		message.MaxRetries = *req.MaxRetries
+	case policy.MaxRetries != nil:
+		message.MaxRetries = *policy.MaxRetries
+	default:
+		message.MaxRetries = retry.DefaultMaxRetries
+	}
+
+	queueType(queue).PrepareMessage(cfg, message, now)
+
+	if message.ScheduledAt != nil && message.ScheduledAt.After(now) {
+		message.Status = models.MessageStatusScheduled
 	}
 
 	return message, nil
 }
 
-func (s *QueueService) GetMessages(ctx context.Context, queueID int64, limit int) ([]*models.Message, error) {
-	var messages []*models.Message
-	query := s.db.NewSelect().Model(&messages).Relation("Queue")
+// admit checks that queue accepts n more messages: it must not be draining, and they
+// must fit under its max_depth and produce rate limit. Concurrent producers may
+// overshoot max_depth slightly.
+func (s *QueueService) admit(ctx context.Context, queue *models.Queue, n int) error {
+	if queue.State == models.QueueStateDraining {
+		return fmt.Errorf("%w: %s accepts no new messages", ErrQueueDraining, queue.Name)
+	}
 
-	if queueID > 0 {
-		query = query.Where("queue_id = ?", queueID)
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		return err
 	}
 
-	if limit > 0 {
-		query = query.Limit(limit)
+	if cfg.MaxDepth > 0 {
+		depth, err := s.backend.Depth(ctx, queue.ID)
+		if err != nil {
+			return err
+		}
+		held := depth[models.MessageStatusScheduled] + depth[models.MessageStatusPending] + depth[models.MessageStatusProcessing]
+		if held+int64(n) > int64(cfg.MaxDepth) {
+			return fmt.Errorf("%w: %d of %d messages held", ErrQueueFull, held, cfg.MaxDepth)
+		}
+	}
+
+	if limit := cfg.RateLimit; limit != nil {
+		key := rateKey{queue.ID, rateProduce}
+		if s.limiter.take(key, limit.ProducePerSecond, limit.Burst, n, false) < n {
+			return fmt.Errorf("%w: produce limit of %g messages per second", ErrRateLimited, limit.ProducePerSecond)
+		}
 	}
 
-	err := query.Order("created_at DESC").Scan(ctx)
+	return nil
+}
+
+func (s *QueueService) GetMessages(ctx context.Context, queueID int64, limit int, sel *selector.Selector) ([]*models.Message, error) {
+	return s.backend.List(ctx, storage.ListOptions{QueueID: queueID, Selector: sel, Limit: limit})
+}
+
+// ClaimMessage atomically picks the highest-priority eligible message of a queue,
+// marks it as processing and assigns it to the given worker under a fresh lease.
+// Two consumers never receive the same message. A non-nil selector restricts the
+// claim to messages whose headers match it.
+func (s *QueueService) ClaimMessage(ctx context.Context, queueID int64, workerID int64, sel *selector.Selector) (*models.Message, error) {
+	messages, err := s.ClaimMessages(ctx, queueID, workerID, 1, sel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get messages: %w", err)
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNoMessageAvailable
 	}
-	return messages, nil
+
+	return messages[0], nil
 }
 
-func (s *QueueService) GetNextMessage(ctx context.Context, queueID int64) (*models.Message, error) {
-	message := &models.Message{}
-	err := s.db.NewSelect().Model(message).
-		Where("queue_id = ? AND status = 'pending'", queueID).
-		Where("(scheduled_at IS NULL OR scheduled_at <= ?)", time.Now()).
-		Order("priority DESC", "created_at ASC").
-		Limit(1).
-		Scan(ctx)
+// ClaimMessages claims up to max messages of a queue for a worker in a single
+// transaction. The messages share one lease token. It returns an empty slice when
+// no message is eligible or the queue is paused. The worker must be registered for
+// the queue and not stopped.
+func (s *QueueService) ClaimMessages(ctx context.Context, queueID int64, workerID int64, max int, sel *selector.Selector) (messages []*models.Message, err error) {
+	queue, err := s.GetQueue(ctx, queueID)
+	if err != nil {
+		return nil, err
+	}
 
+	worker, err := s.GetWorker(ctx, workerID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get next message: %w", err)
+		return nil, err
+	}
+	if worker.Status == models.WorkerStatusStopped {
+		return nil, fmt.Errorf("%w: worker %d must register again", ErrWorkerStopped, workerID)
+	}
+	if worker.QueueID != queueID {
+		return nil, fmt.Errorf("%w: worker %d consumes queue %d", ErrWorkerQueueMismatch, workerID, worker.QueueID)
 	}
 
-	return message, nil
+	if queue.State == models.QueueStatePaused {
+		return []*models.Message{}, nil
+	}
+
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claims that find no message hand their share of the consume limit back
+	if limit := cfg.RateLimit; limit != nil {
+		key := rateKey{queue.ID, rateConsume}
+		granted := s.limiter.take(key, limit.ConsumePerSecond, limit.Burst, max, true)
+		if granted == 0 {
+			return nil, fmt.Errorf("%w: consume limit of %g messages per second", ErrRateLimited, limit.ConsumePerSecond)
+		}
+		defer func() { s.limiter.refund(key, granted-len(messages)) }()
+		max = granted
+	}
+
+	opts, err := s.claimOptions(queue, workerID, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.backend.ClaimBatch(ctx, queueID, max, opts)
+}
+
+// claimOptions prepares a new lease on queue for a worker
+func (s *QueueService) claimOptions(queue *models.Queue, workerID int64, sel *selector.Selector) (storage.ClaimOptions, error) {
+	lease, err := s.leaseDuration(queue)
+	if err != nil {
+		return storage.ClaimOptions{}, err
+	}
+
+	return storage.ClaimOptions{
+		WorkerID:   workerID,
+		LeaseToken: newLeaseToken(),
+		Lease:      lease,
+		Selector:   sel,
+		Order:      queueType(queue).Order(),
+	}, nil
+}
+
+// WaitForMessages claims up to max messages of a queue for a worker. When none is
+// eligible it waits, for at most wait (capped by the configured maximum), until a
+// message is produced, released or promoted by the scheduler, and returns an empty
+// slice if none arrives in time.
+func (s *QueueService) WaitForMessages(ctx context.Context, queueID int64, workerID int64, max int, sel *selector.Selector, wait time.Duration) ([]*models.Message, error) {
+	if wait > s.cfg.MaxPollWait {
+		wait = s.cfg.MaxPollWait
+	}
+
+	// Subscribe before the first claim so a message produced in between still wakes us
+	wake, cancel := s.notifier.Subscribe(queueID)
+	defer cancel()
+
+	deadline := time.Now().Add(wait)
+
+	for {
+		messages, err := s.ClaimMessages(ctx, queueID, workerID, max, sel)
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := time.Until(deadline)
+		if len(messages) > 0 || remaining <= 0 {
+			return messages, nil
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return messages, nil
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// ExtendLease pushes back the lease deadline of a claimed message. The caller must
+// present the lease token handed out by the claim, and the lease must not have expired.
+func (s *QueueService) ExtendLease(ctx context.Context, messageID int64, leaseToken string, extension time.Duration) (*models.Message, error) {
+	if extension <= 0 {
+		message, err := s.backend.Get(ctx, messageID)
+		if err != nil {
+			return nil, err
+		}
+
+		queue, err := s.GetQueue(ctx, message.QueueID)
+		if err != nil {
+			return nil, err
+		}
+
+		extension, err = s.leaseDuration(queue)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.backend.ExtendLease(ctx, messageID, leaseToken, time.Now().Add(extension))
+}
+
+// RequeueExpiredLeases treats every message whose lease has lapsed as a failed
+// delivery: it is retried per its queue's retry policy, or moved to the dead
+// letter queue once its retries are exhausted. It returns the number of messages affected.
+func (s *QueueService) RequeueExpiredLeases(ctx context.Context) (int64, error) {
+	return s.requeueFailed(ctx, "lease expired", s.backend.ExpireLeases)
 }
 
-func (s *QueueService) UpdateMessageStatus(ctx context.Context, messageID int64, status string, errorMessage string) error {
-	updates := map[string]interface{}{
-		"status":     status,
-		"updated_at": time.Now(),
+// SuperviseWorkers marks the workers whose last heartbeat is older than the
+// configured timeout as stopped, and treats every message they held as a failed
+// delivery. It returns the number of workers stopped. Each worker is stopped by a
+// single caller, so replicas can supervise concurrently.
+func (s *QueueService) SuperviseWorkers(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	lapsed, err := s.catalog.StopLapsedWorkers(ctx, now.Add(-s.cfg.WorkerTimeout), now)
+	if err != nil {
+		return 0, err
 	}
 
-	switch status {
-	case "processing":
-		updates["processed_at"] = time.Now()
-	case "failed":
-		updates["failed_at"] = time.Now()
-		if errorMessage != "" {
-			updates["error_message"] = errorMessage
+	for _, worker := range lapsed {
+		n, err := s.requeueFailed(ctx, "worker stopped sending heartbeats", func(ctx context.Context, decide func(*models.Message) storage.Failure) (int64, error) {
+			return s.backend.FailWorker(ctx, worker.ID, decide)
+		})
+		if err != nil {
+			return len(lapsed), err
+		}
+
+		if n > 0 {
+			s.countWorker(ctx, worker.ID, 0, n)
 		}
+		s.recordWorkerEvent(ctx, worker.ID, models.WorkerEventLapsed,
+			fmt.Sprintf("no heartbeat since %s", worker.LastPing.Format(time.RFC3339)), n)
 	}
 
-	_, err := s.db.NewUpdate().Model((*models.Message)(nil)).
-		Where("id = ?", messageID).
-		Set("status = ?", status).
-		Set("updated_at = ?", time.Now()).
-		Exec(ctx)
+	return len(lapsed), nil
+}
+
+// requeueFailed records a failure with the given reason for the messages selected
+// by fail, deciding each one's fate from its queue's retry policy, and wakes the
+// consumers of the queues concerned
+func (s *QueueService) requeueFailed(ctx context.Context, reason string, fail func(ctx context.Context, decide func(*models.Message) storage.Failure) (int64, error)) (int64, error) {
+	queues := make(map[int64]*models.Queue)
 
+	n, err := fail(ctx, func(message *models.Message) storage.Failure {
+		queue, ok := queues[message.QueueID]
+		if !ok {
+			var err error
+			queue, err = s.GetQueue(ctx, message.QueueID)
+			if err != nil {
+				log.Printf("Requeue after %s: %v; using the default retry policy for queue %d", reason, err, message.QueueID)
+				queue = &models.Queue{ID: message.QueueID}
+			}
+			queues[message.QueueID] = queue
+		}
+
+		return s.failure(queue, message, reason, nil)
+	})
+
+	for queueID := range queues {
+		s.notifier.Notify(ctx, queueID)
+	}
+	if n > 0 {
+		s.wakeScheduler()
+	}
+
+	return n, err
+}
+
+// EnforceRetention deletes the finished messages of every queue with a retention
+// period once they are older than it. It returns the number of messages deleted.
+func (s *QueueService) EnforceRetention(ctx context.Context) (int64, error) {
+	queues, err := s.GetQueues(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to update message status: %w", err)
+		return 0, err
 	}
 
-	return nil
+	var deleted int64
+	for _, queue := range queues {
+		cfg, err := models.ParseQueueConfig(queue.Config)
+		if err != nil || cfg.Retention() <= 0 {
+			continue
+		}
+
+		n, err := s.backend.DeleteFinished(ctx, queue.ID, time.Now().Add(-cfg.Retention()))
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+
+	return deleted, nil
+}
+
+// ExpireDedupKeys forgets deduplication keys whose window has passed
+func (s *QueueService) ExpireDedupKeys(ctx context.Context) (int64, error) {
+	return s.backend.ExpireDedupKeys(ctx)
+}
+
+// RedriveMessages moves dead-lettered messages that originated from the given queue
+// back to it, resetting their retry count. It returns the number of messages moved.
+func (s *QueueService) RedriveMessages(ctx context.Context, queueID int64, req *models.RedriveRequest) (int64, error) {
+	queue, err := s.GetQueue(ctx, queueID)
+	if err != nil {
+		return 0, err
+	}
+	if queue.DeadLetterQueueID == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+
+	now := time.Now()
+	opts := storage.RedriveOptions{
+		DeadLetterQueueID: *queue.DeadLetterQueueID,
+		SourceQueueID:     queueID,
+		MessageIDs:        req.MessageIDs,
+		ErrorContains:     req.ErrorContains,
+	}
+	if req.MinAgeSeconds > 0 {
+		before := now.Add(-time.Duration(req.MinAgeSeconds) * time.Second)
+		opts.DeadLetteredBefore = &before
+	}
+	if req.MaxAgeSeconds > 0 {
+		after := now.Add(-time.Duration(req.MaxAgeSeconds) * time.Second)
+		opts.DeadLetteredAfter = &after
+	}
+
+	n, err := s.backend.Redrive(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > 0 {
+		s.notifier.Notify(ctx, queueID)
+	}
+
+	return n, nil
+}
+
+// AckMessage marks a claimed message as completed. The caller must hold the current lease.
+func (s *QueueService) AckMessage(ctx context.Context, messageID int64, leaseToken string) (*models.Message, error) {
+	message, err := s.backend.Ack(ctx, messageID, leaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.WorkerID != nil {
+		s.countWorker(ctx, *message.WorkerID, 1, 0)
+	}
+
+	// The next message of the group can now be delivered
+	if message.GroupKey != "" {
+		s.notifier.Notify(ctx, message.QueueID)
+	}
+
+	return message, nil
+}
+
+// NackMessage records a processing failure for a claimed message. While the message
+// has retries left it is rescheduled using the queue's retry policy, or after
+// requeueDelay when one is given; once its retries are exhausted it moves to the
+// queue's dead letter queue, or is marked as failed when the queue has none.
+func (s *QueueService) NackMessage(ctx context.Context, messageID int64, leaseToken string, reason string, requeueDelay *time.Duration) (*models.Message, error) {
+	message, err := s.backend.Get(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := s.GetQueue(ctx, message.QueueID)
+	if err != nil {
+		return nil, err
+	}
+
+	workerID := message.WorkerID
+
+	message, err = s.backend.Nack(ctx, messageID, leaseToken, s.failure(queue, message, reason, requeueDelay))
+	if err != nil {
+		return nil, err
+	}
+
+	if workerID != nil {
+		s.countWorker(ctx, *workerID, 0, 1)
+	}
+
+	switch {
+	case message.Status == models.MessageStatusScheduled:
+		s.wakeScheduler()
+	case message.Status == models.MessageStatusPending || message.GroupKey != "":
+		// Retried at once, or finished so the next message of the group can be delivered
+		s.notifier.Notify(ctx, queue.ID)
+	}
+
+	return message, nil
+}
+
+// ReleaseMessage hands a claimed message back to its queue without counting a retry
+func (s *QueueService) ReleaseMessage(ctx context.Context, messageID int64, leaseToken string) (*models.Message, error) {
+	message, err := s.backend.Release(ctx, messageID, leaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifier.Notify(ctx, message.QueueID)
+
+	return message, nil
+}
+
+// failure decides what happens to a message after a failed delivery attempt
+func (s *QueueService) failure(queue *models.Queue, message *models.Message, reason string, requeueDelay *time.Duration) storage.Failure {
+	failure := storage.Failure{Error: reason}
+
+	attempt := message.RetryCount + 1
+	if attempt > message.MaxRetries {
+		failure.DeadLetterQueueID = queue.DeadLetterQueueID
+		return failure
+	}
+
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		cfg = &models.QueueConfig{}
+	}
+
+	delay := cfg.Retry().Backoff(attempt)
+	if requeueDelay != nil {
+		delay = *requeueDelay
+	}
+
+	retryAt := time.Now().Add(delay)
+	failure.RetryAt = &retryAt
+	return failure
 }
 
 // Worker operations
+
+// RegisterWorker registers a worker consuming a queue. It starts idle, and must send
+// heartbeats to stay registered.
 func (s *QueueService) RegisterWorker(ctx context.Context, name string, queueID int64) (*models.Worker, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidWorker)
+	}
+	if _, err := s.GetQueue(ctx, queueID); err != nil {
+		return nil, err
+	}
+
 	worker := &models.Worker{
 		Name:      name,
 		QueueID:   queueID,
-		Status:    "idle",
+		Status:    models.WorkerStatusIdle,
 		LastPing:  time.Now(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	_, err := s.db.NewInsert().Model(worker).Exec(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to register worker: %w", err)
+	if err := s.catalog.InsertWorker(ctx, worker); err != nil {
+		return nil, err
 	}
 
+	s.recordWorkerEvent(ctx, worker.ID, models.WorkerEventRegistered, "", 0)
+
 	return worker, nil
 }
 
 func (s *QueueService) GetWorkers(ctx context.Context, queueID int64) ([]*models.Worker, error) {
-	var workers []*models.Worker
-	query := s.db.NewSelect().Model(&workers).Relation("Queue")
+	return s.catalog.ListWorkers(ctx, queueID)
+}
+
+func (s *QueueService) GetWorker(ctx context.Context, workerID int64) (*models.Worker, error) {
+	return s.catalog.GetWorker(ctx, workerID)
+}
+
+// UpdateWorkerPing records a heartbeat of a worker with its status and the messages
+// it is processing. A stopped worker is not revived: it must register again.
+func (s *QueueService) UpdateWorkerPing(ctx context.Context, workerID int64, req *models.WorkerHeartbeatRequest) (*models.Worker, error) {
+	status := req.Status
+	if status == "" {
+		status = models.WorkerStatusIdle
+		if len(req.MessageIDs) > 0 {
+			status = models.WorkerStatusBusy
+		}
+	}
+	if status != models.WorkerStatusIdle && status != models.WorkerStatusBusy {
+		return nil, fmt.Errorf("%w: status must be idle or busy", ErrInvalidWorker)
+	}
+
+	return s.catalog.PingWorker(ctx, workerID, status, req.MessageIDs, time.Now())
+}
+
+// DeregisterWorker marks a worker as stopped when it shuts down gracefully. Messages
+// it still holds go back to their queue without counting a retry.
+func (s *QueueService) DeregisterWorker(ctx context.Context, workerID int64) (*models.Worker, error) {
+	worker, err := s.catalog.StopWorker(ctx, workerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	released, err := s.backend.ReleaseWorker(ctx, workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make(map[int64]bool)
+	for _, message := range released {
+		if !queues[message.QueueID] {
+			queues[message.QueueID] = true
+			s.notifier.Notify(ctx, message.QueueID)
+		}
+	}
+
+	s.recordWorkerEvent(ctx, workerID, models.WorkerEventDeregistered, "", int64(len(released)))
+
+	return worker, nil
+}
 
-	if queueID > 0 {
-		query = query.Where("queue_id = ?", queueID)
+// GetWorkerEvents returns the most recent lifecycle events of a worker, newest first
+func (s *QueueService) GetWorkerEvents(ctx context.Context, workerID int64, limit int) ([]*models.WorkerEvent, error) {
+	if _, err := s.GetWorker(ctx, workerID); err != nil {
+		return nil, err
 	}
 
-	err := query.Order("created_at DESC").Scan(ctx)
+	return s.catalog.ListWorkerEvents(ctx, workerID, limit)
+}
+
+// recordWorkerEvent adds an event to the history of a worker. Failing to record it
+// does not fail the change it describes.
+func (s *QueueService) recordWorkerEvent(ctx context.Context, workerID int64, typ string, detail string, messages int64) {
+	event := &models.WorkerEvent{
+		WorkerID:  workerID,
+		Type:      typ,
+		Detail:    detail,
+		Messages:  messages,
+		CreatedAt: time.Now(),
+	}
+	if err := s.catalog.InsertWorkerEvent(ctx, event); err != nil {
+		log.Printf("Failed to record %s event of worker %d: %v", typ, workerID, err)
+	}
+}
+
+// countWorker adds to the processed and failed message counters of a worker. The
+// message outcome it counts stands even when this fails.
+func (s *QueueService) countWorker(ctx context.Context, workerID int64, processed, failed int64) {
+	if err := s.catalog.CountWorker(ctx, workerID, processed, failed); err != nil {
+		log.Printf("Failed to update the counters of worker %d: %v", workerID, err)
+	}
+}
+
+// validateQueueConfig checks the raw configuration of the named queue against the
+// schema and the needs of the queue's type. Problems are reported as a *models.ConfigError wrapped
+// in ErrInvalidQueueConfig.
+func validateQueueConfig(name, raw string, typ queuetype.Type) (*models.QueueConfig, error) {
+	cfg, err := models.ValidateQueueConfig(name, raw)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get workers: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQueueConfig, err)
 	}
-	return workers, nil
+
+	if err := typ.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQueueConfig, err)
+	}
+
+	return cfg, nil
+}
+
+func (s *QueueService) dedupWindow(queue *models.Queue) (time.Duration, error) {
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		return 0, err
+	}
+	return cfg.DedupWindow(), nil
 }
 
-func (s *QueueService) UpdateWorkerPing(ctx context.Context, workerID int64, status string) error {
-	_, err := s.db.NewUpdate().Model((*models.Worker)(nil)).
-		Set("last_ping = ?", time.Now()).
-		Set("status = ?", status).
-		Set("updated_at = ?", time.Now()).
-		Where("id = ?", workerID).
-		Exec(ctx)
+func (s *QueueService) leaseDuration(queue *models.Queue) (time.Duration, error) {
+	cfg, err := models.ParseQueueConfig(queue.Config)
+	if err != nil {
+		return 0, err
+	}
+	return cfg.LeaseDuration(s.cfg.DefaultLease), nil
+}
 
+// queueType returns the type of queue. Queues created before types were enforced may
+// carry a free-form type; they keep the priority ordering they always had.
+func queueType(queue *models.Queue) queuetype.Type {
+	typ, err := queuetype.Lookup(queue.Type)
 	if err != nil {
-		return fmt.Errorf("failed to update worker ping: %w", err)
+		typ, _ = queuetype.Lookup(queuetype.Priority)
 	}
+	return typ
+}
 
-	return nil
+func newLeaseToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate lease token: %v", err))
+	}
+	return hex.EncodeToString(b)
 }
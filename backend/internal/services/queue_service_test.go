@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shravan20/qafka/internal/config"
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+	"github.com/shravan20/qafka/internal/storage/memory"
+)
+
+// newMemoryServices wires the services on in-memory storage, as main does in memory mode
+func newMemoryServices() (*QueueService, *ScheduleService, *memory.Catalog) {
+	cfg := &config.Config{
+		DefaultLease:  30 * time.Second,
+		WorkerTimeout: 30 * time.Second,
+		MaxPollWait:   time.Second,
+		BulkChunkSize: 100,
+	}
+	catalog := memory.NewCatalog()
+	queueService := NewQueueService(catalog, memory.New(), NewLocalNotifier(), cfg)
+	return queueService, NewScheduleService(catalog, queueService), catalog
+}
+
+func TestMemoryQueueLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "orders", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+	if queue.DeadLetterQueueID == nil {
+		t.Fatal("CreateQueue did not link a dead letter queue")
+	}
+	if _, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "loop", Type: "fifo", Config: `{"dead_letter_queue": "loop"}`}); !errors.Is(err, ErrInvalidQueueConfig) {
+		t.Fatalf("CreateQueue as its own dead letter queue: got %v, want ErrInvalidQueueConfig", err)
+	}
+	if _, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "orders", Type: "fifo"}); !errors.Is(err, ErrQueueExists) {
+		t.Fatalf("CreateQueue with a taken name: got %v, want ErrQueueExists", err)
+	}
+
+	queues, err := s.GetQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetQueues: %v", err)
+	}
+	if len(queues) != 2 {
+		t.Fatalf("GetQueues returned %d queues, want the queue and its dead letter queue", len(queues))
+	}
+
+	if _, err := s.CreateMessage(ctx, &models.CreateMessageRequest{QueueID: queue.ID, Payload: "hello"}); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+
+	worker, err := s.RegisterWorker(ctx, "worker-1", queue.ID)
+	if err != nil {
+		t.Fatalf("RegisterWorker: %v", err)
+	}
+	message, err := s.ClaimMessage(ctx, queue.ID, worker.ID, nil)
+	if err != nil {
+		t.Fatalf("ClaimMessage: %v", err)
+	}
+	if _, err := s.AckMessage(ctx, message.ID, message.LeaseToken); err != nil {
+		t.Fatalf("AckMessage: %v", err)
+	}
+
+	worker, err = s.DeregisterWorker(ctx, worker.ID)
+	if err != nil {
+		t.Fatalf("DeregisterWorker: %v", err)
+	}
+	if worker.Status != models.WorkerStatusStopped || worker.ProcessedCount != 1 {
+		t.Fatalf("DeregisterWorker returned status %s with %d processed, want stopped with 1", worker.Status, worker.ProcessedCount)
+	}
+	events, err := s.GetWorkerEvents(ctx, worker.ID, 0)
+	if err != nil {
+		t.Fatalf("GetWorkerEvents: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("GetWorkerEvents returned %d events, want registered and deregistered", len(events))
+	}
+
+	if _, err := s.DeleteQueue(ctx, *queue.DeadLetterQueueID, ""); !errors.Is(err, ErrQueueInUse) {
+		t.Fatalf("DeleteQueue of a linked dead letter queue: got %v, want ErrQueueInUse", err)
+	}
+	if _, err := s.DeleteQueue(ctx, queue.ID, models.QueueDeleteCascade); err != nil {
+		t.Fatalf("DeleteQueue: %v", err)
+	}
+	if _, err := s.GetQueue(ctx, queue.ID); !errors.Is(err, ErrQueueNotFound) {
+		t.Fatalf("GetQueue of deleted queue: got %v, want ErrQueueNotFound", err)
+	}
+
+	restored, err := s.RestoreQueue(ctx, queue.ID)
+	if err != nil {
+		t.Fatalf("RestoreQueue: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != queue.Version+2 {
+		t.Fatalf("RestoreQueue returned %+v", restored)
+	}
+
+	if _, err := s.DeleteQueue(ctx, queue.ID, models.QueueDeleteCascade); err != nil {
+		t.Fatalf("DeleteQueue: %v", err)
+	}
+	purged, err := s.PurgeDeletedQueues(ctx)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedQueues: purged %d, %v", purged, err)
+	}
+	if _, err := s.GetWorker(ctx, worker.ID); !errors.Is(err, ErrWorkerNotFound) {
+		t.Fatalf("GetWorker of purged queue's worker: got %v, want ErrWorkerNotFound", err)
+	}
+	if deleted, _ := s.GetDeletedQueues(ctx); len(deleted) != 0 {
+		t.Fatalf("GetDeletedQueues returned %d queues after the purge", len(deleted))
+	}
+}
+
+func TestMemoryZeroMaxRetries(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	noRetries, err := s.CreateQueue(ctx, &models.CreateQueueRequest{
+		Name:   "no-retries",
+		Type:   "fifo",
+		Config: `{"retry_policy": {"strategy": "fixed", "delay_seconds": 1, "max_retries": 0}}`,
+	})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+	defaults, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "defaults", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+
+	zero := 0
+	tests := []struct {
+		name        string
+		queue       *models.Queue
+		maxRetries  *int
+		wantRetries int
+		wantStatus  string
+	}{
+		{"queue policy of 0", noRetries, nil, 0, models.MessageStatusDeadLettered},
+		{"message field of 0", defaults, &zero, 0, models.MessageStatusDeadLettered},
+		{"unset", defaults, nil, 3, models.MessageStatusScheduled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := s.CreateMessage(ctx, &models.CreateMessageRequest{QueueID: tt.queue.ID, Payload: "job", MaxRetries: tt.maxRetries})
+			if err != nil {
+				t.Fatalf("CreateMessage: %v", err)
+			}
+			if message.MaxRetries != tt.wantRetries {
+				t.Fatalf("CreateMessage set max_retries %d, want %d", message.MaxRetries, tt.wantRetries)
+			}
+
+			worker, err := s.RegisterWorker(ctx, "worker", tt.queue.ID)
+			if err != nil {
+				t.Fatalf("RegisterWorker: %v", err)
+			}
+			claimed, err := s.ClaimMessage(ctx, tt.queue.ID, worker.ID, nil)
+			if err != nil {
+				t.Fatalf("ClaimMessage: %v", err)
+			}
+			failed, err := s.NackMessage(ctx, claimed.ID, claimed.LeaseToken, "boom", nil)
+			if err != nil {
+				t.Fatalf("NackMessage: %v", err)
+			}
+			if failed.Status != tt.wantStatus {
+				t.Fatalf("NackMessage left the message %s, want %s", failed.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// racingBackend produces a message to a queue right after its first depth count,
+// as a produce that read the queue just before it was deleted would
+type racingBackend struct {
+	storage.Backend
+	once sync.Once
+}
+
+func (b *racingBackend) Depth(ctx context.Context, queueID int64) (map[string]int64, error) {
+	depth, err := b.Backend.Depth(ctx, queueID)
+	b.once.Do(func() {
+		now := time.Now()
+		late := &models.Message{QueueID: queueID, Payload: "late", Status: models.MessageStatusPending, MaxRetries: 3, CreatedAt: now, UpdatedAt: now}
+		if enqueueErr := b.Backend.Enqueue(ctx, late, 0); err == nil {
+			err = enqueueErr
+		}
+	})
+	return depth, err
+}
+
+func TestMemoryDeleteQueueRefuse(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryServices()
+
+	queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "orders", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+	if _, err := s.CreateMessage(ctx, &models.CreateMessageRequest{QueueID: queue.ID, Payload: "job"}); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	if _, err := s.DeleteQueue(ctx, queue.ID, models.QueueDeleteRefuse); !errors.Is(err, ErrQueueNotEmpty) {
+		t.Fatalf("DeleteQueue with a pending message: got %v, want ErrQueueNotEmpty", err)
+	}
+
+	// Completed messages do not hold the queue back
+	worker, err := s.RegisterWorker(ctx, "worker", queue.ID)
+	if err != nil {
+		t.Fatalf("RegisterWorker: %v", err)
+	}
+	message, err := s.ClaimMessage(ctx, queue.ID, worker.ID, nil)
+	if err != nil {
+		t.Fatalf("ClaimMessage: %v", err)
+	}
+	if _, err := s.AckMessage(ctx, message.ID, message.LeaseToken); err != nil {
+		t.Fatalf("AckMessage: %v", err)
+	}
+	if _, err := s.DeleteQueue(ctx, queue.ID, models.QueueDeleteRefuse); err != nil {
+		t.Fatalf("DeleteQueue with a completed message: %v", err)
+	}
+
+	// A produce landing after the delete keeps the queue from being purged
+	now := time.Now()
+	late := &models.Message{QueueID: queue.ID, Payload: "late", Status: models.MessageStatusPending, MaxRetries: 3, CreatedAt: now, UpdatedAt: now}
+	if err := s.backend.Enqueue(ctx, late, 0); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if purged, err := s.PurgeDeletedQueues(ctx); err != nil || purged != 0 {
+		t.Fatalf("PurgeDeletedQueues with a pending message: purged %d, %v, want 0", purged, err)
+	}
+	if _, err := s.RestoreQueue(ctx, queue.ID); err != nil {
+		t.Fatalf("RestoreQueue: %v", err)
+	}
+
+	// A produce landing while the delete commits undoes it
+	racing, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "racing", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+	s.backend = &racingBackend{Backend: s.backend}
+	if _, err := s.DeleteQueue(ctx, racing.ID, models.QueueDeleteRefuse); !errors.Is(err, ErrQueueNotEmpty) {
+		t.Fatalf("DeleteQueue racing a produce: got %v, want ErrQueueNotEmpty", err)
+	}
+	if _, err := s.GetQueue(ctx, racing.ID); err != nil {
+		t.Fatalf("GetQueue after the delete was undone: %v", err)
+	}
+}
+
+func TestMemorySchedules(t *testing.T) {
+	ctx := context.Background()
+	s, schedules, catalog := newMemoryServices()
+
+	queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "reports", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+
+	schedule, err := schedules.CreateSchedule(ctx, &models.CreateScheduleRequest{
+		Name:            "nightly",
+		CronExpr:        "* * * * *",
+		QueueID:         queue.ID,
+		PayloadTemplate: `{"at": {{.Unix}}}`,
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule: %v", err)
+	}
+
+	// Make the next tick due
+	due := time.Now().Add(-30 * time.Second).Truncate(time.Minute)
+	schedule.NextRunAt = &due
+	if err := catalog.UpdateSchedule(ctx, schedule); err != nil {
+		t.Fatalf("UpdateSchedule: %v", err)
+	}
+
+	runs, err := schedules.RunDueSchedules(ctx)
+	if err != nil || runs != 1 {
+		t.Fatalf("RunDueSchedules: ran %d, %v", runs, err)
+	}
+
+	history, err := schedules.GetScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("GetScheduleRuns: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.ScheduleRunEnqueued || history[0].MessageID == nil {
+		t.Fatalf("GetScheduleRuns returned %+v", history)
+	}
+
+	messages, err := s.GetMessages(ctx, queue.ID, 0, nil)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != *history[0].MessageID {
+		t.Fatalf("GetMessages returned %d messages, want the scheduled one", len(messages))
+	}
+
+	schedule, err = schedules.GetSchedule(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("GetSchedule: %v", err)
+	}
+	if schedule.NextRunAt == nil || !schedule.NextRunAt.After(time.Now()) || schedule.LastRunAt == nil {
+		t.Fatalf("schedule was not advanced: next %v, last %v", schedule.NextRunAt, schedule.LastRunAt)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Directions a queue's rate limit applies to
+const (
+	rateProduce = "produce"
+	rateConsume = "consume"
+)
+
+type rateKey struct {
+	queueID   int64
+	direction string
+}
+
+// tokenBucket refills at rate tokens per second up to burst tokens
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps one token bucket per queue and direction. Limits are enforced
+// per server instance.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[rateKey]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[rateKey]*tokenBucket)}
+}
+
+// take removes up to n tokens from the bucket of key and returns how many it got.
+// Unless partial is set it takes all n tokens or none. A non-positive rate is
+// unlimited. burst defaults to one second's worth of tokens.
+func (l *rateLimiter) take(key rateKey, rate float64, burst int, n int, partial bool) int {
+	if rate <= 0 || n <= 0 {
+		return n
+	}
+
+	capacity := float64(burst)
+	if capacity <= 0 {
+		capacity = math.Max(rate, 1)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate || b.burst != capacity {
+		// New or reconfigured limit: start full
+		b = &tokenBucket{rate: rate, burst: capacity, tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	available := int(b.tokens)
+	if available >= n {
+		available = n
+	} else if !partial {
+		return 0
+	}
+
+	b.tokens -= float64(available)
+	return available
+}
+
+// refund returns n unused tokens to the bucket of key
+func (l *rateLimiter) refund(key rateKey, n int) {
+	if n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(b.burst, b.tokens+float64(n))
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"time"
+)
+
+// scheduleLeaderLockID is the key of the advisory lock held by the replica running schedules
+const scheduleLeaderLockID int64 = 0x7166616b62
+
+// ScheduleRunner runs the due ticks of cron schedules. Every replica runs one, but
+// only the elected leader, the replica holding a session advisory lock, runs
+// schedules; the others retry the election every interval and take over when the
+// leader's database session ends. Without a database the process is the only
+// replica and always leads.
+type ScheduleRunner struct {
+	scheduleService *ScheduleService
+	db              *sql.DB // database holding the leader lock, nil when running alone
+	interval        time.Duration
+	conn            *sql.Conn // session holding the leader lock, nil while not leading
+}
+
+func NewScheduleRunner(scheduleService *ScheduleService, db *sql.DB, interval time.Duration) *ScheduleRunner {
+	return &ScheduleRunner{scheduleService: scheduleService, db: db, interval: interval}
+}
+
+// Run blocks until ctx is cancelled, then steps down if leading
+func (r *ScheduleRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	defer r.resign()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.lead(ctx) {
+				continue
+			}
+
+			n, err := r.scheduleService.RunDueSchedules(ctx)
+			if err != nil {
+				log.Printf("Schedule runner: %v", err)
+			} else if n > 0 {
+				log.Printf("Schedule runner: ran %d schedule ticks", n)
+			}
+		}
+	}
+}
+
+// lead reports whether this replica is the leader, trying to become it if no
+// replica is
+func (r *ScheduleRunner) lead(ctx context.Context) bool {
+	if r.db == nil {
+		return true
+	}
+
+	if r.conn != nil {
+		// The lock lives as long as the session, so a working connection still holds it
+		if _, err := r.conn.ExecContext(ctx, `SELECT 1`); err == nil {
+			return true
+		}
+		log.Printf("Schedule runner: lost leadership")
+		discard(r.conn)
+		r.conn = nil
+	}
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Schedule runner: failed to get connection: %v", err)
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, scheduleLeaderLockID).Scan(&acquired); err != nil {
+		log.Printf("Schedule runner: failed to take leader lock: %v", err)
+		discard(conn) // the lock may have been taken before the error
+		return false
+	}
+	if !acquired {
+		conn.Close()
+		return false
+	}
+
+	log.Printf("Schedule runner: elected leader")
+	r.conn = conn
+	return true
+}
+
+// resign releases the leader lock so another replica can take over at once
+func (r *ScheduleRunner) resign() {
+	if r.conn == nil {
+		return
+	}
+	if _, err := r.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, scheduleLeaderLockID); err != nil {
+		discard(r.conn)
+	} else {
+		r.conn.Close()
+	}
+	r.conn = nil
+}
+
+// discard closes the session of conn instead of returning it to the pool. A
+// session that failed a query may still be alive and hold the leader lock, which
+// would then belong to whatever borrowed the connection next.
+func discard(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+const (
+	// maxCatchUpRuns bounds the missed ticks a schedule with the "all" policy runs at once
+	maxCatchUpRuns = 100
+
+	// missedRunGrace is how late a tick may run before it counts as missed
+	missedRunGrace = time.Minute
+
+	// staleRunTimeout is how long a run may stay running before its runner is taken
+	// to have died and the tick is retried
+	staleRunTimeout = time.Minute
+
+	// scheduleDedupWindow is how long the dedup key of a tick's message is kept,
+	// whatever the target queue configures. A tick retried after its runner died
+	// within a day of enqueueing the message is not enqueued twice.
+	scheduleDedupWindow = 24 * time.Hour
+)
+
+// ScheduleService manages cron schedules and runs their due ticks
+type ScheduleService struct {
+	catalog      storage.Catalog
+	queueService *QueueService
+}
+
+func NewScheduleService(catalog storage.Catalog, queueService *QueueService) *ScheduleService {
+	return &ScheduleService{catalog: catalog, queueService: queueService}
+}
+
+// scheduleRunData is the data a payload template is rendered with
+type scheduleRunData struct {
+	Schedule     string    // name of the schedule
+	ScheduledFor time.Time // tick being run, in the schedule's timezone
+	Unix         int64     // tick being run, as a Unix timestamp
+}
+
+func (s *ScheduleService) GetSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	return s.catalog.ListSchedules(ctx)
+}
+
+func (s *ScheduleService) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	return s.catalog.GetSchedule(ctx, id)
+}
+
+// CreateSchedule validates and stores a new schedule. An enabled schedule first runs
+// at the next tick after now.
+func (s *ScheduleService) CreateSchedule(ctx context.Context, req *models.CreateScheduleRequest) (*models.Schedule, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidSchedule)
+	}
+
+	now := time.Now()
+	schedule := &models.Schedule{
+		Name:            req.Name,
+		Description:     req.Description,
+		CronExpr:        req.CronExpr,
+		Timezone:        req.Timezone,
+		QueueID:         req.QueueID,
+		PayloadTemplate: req.PayloadTemplate,
+		Headers:         req.Headers,
+		Priority:        req.Priority,
+		CatchUp:         req.CatchUp,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if schedule.CatchUp == "" {
+		schedule.CatchUp = models.ScheduleCatchUpLatest
+	}
+
+	if err := s.validateSchedule(ctx, s.catalog, schedule); err != nil {
+		return nil, err
+	}
+	if err := setNextRun(schedule, now); err != nil {
+		return nil, err
+	}
+
+	err := s.catalog.InsertSchedule(ctx, schedule)
+	if errors.Is(err, storage.ErrNameTaken) {
+		return nil, fmt.Errorf("%w: %q", ErrScheduleExists, req.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// UpdateSchedule applies the fields set in req to a schedule. Changing its cron
+// expression or timezone, or enabling it, moves its next run to the next tick after now.
+func (s *ScheduleService) UpdateSchedule(ctx context.Context, id int64, req *models.UpdateScheduleRequest) (*models.Schedule, error) {
+	var schedule *models.Schedule
+
+	err := s.catalog.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		var err error
+		schedule, err = tx.GetScheduleForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		reschedule := false
+		if req.Description != nil {
+			schedule.Description = *req.Description
+		}
+		if req.CronExpr != nil {
+			reschedule = reschedule || *req.CronExpr != schedule.CronExpr
+			schedule.CronExpr = *req.CronExpr
+		}
+		if req.Timezone != nil {
+			reschedule = reschedule || *req.Timezone != schedule.Timezone
+			schedule.Timezone = *req.Timezone
+		}
+		if req.QueueID != nil {
+			schedule.QueueID = *req.QueueID
+		}
+		if req.PayloadTemplate != nil {
+			schedule.PayloadTemplate = *req.PayloadTemplate
+		}
+		if req.Headers != nil {
+			schedule.Headers = req.Headers
+		}
+		if req.Priority != nil {
+			schedule.Priority = *req.Priority
+		}
+		if req.CatchUp != nil {
+			schedule.CatchUp = *req.CatchUp
+		}
+		if req.Enabled != nil {
+			reschedule = reschedule || *req.Enabled != schedule.Enabled
+			schedule.Enabled = *req.Enabled
+		}
+
+		if err := s.validateSchedule(ctx, tx, schedule); err != nil {
+			return err
+		}
+		if reschedule {
+			if err := setNextRun(schedule, time.Now()); err != nil {
+				return err
+			}
+		}
+		schedule.UpdatedAt = time.Now()
+
+		return tx.UpdateSchedule(ctx, schedule)
+	})
+	if errors.Is(err, ErrScheduleNotFound) || errors.Is(err, ErrInvalidSchedule) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// DeleteSchedule deletes a schedule with its run history. Messages it enqueued are kept.
+func (s *ScheduleService) DeleteSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	return s.catalog.DeleteSchedule(ctx, id)
+}
+
+// GetScheduleRuns returns the most recent runs of a schedule, newest first
+func (s *ScheduleService) GetScheduleRuns(ctx context.Context, id int64, limit int) ([]*models.ScheduleRun, error) {
+	if _, err := s.GetSchedule(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.catalog.ListScheduleRuns(ctx, id, limit)
+}
+
+// RunDueSchedules runs the ticks of every enabled schedule that are due, applying
+// each schedule's catch-up policy to the ticks it missed. It returns the number of
+// runs started. Only the elected leader calls it, and a tick already recorded in
+// the run history is never run again, so no tick enqueues twice.
+func (s *ScheduleService) RunDueSchedules(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	due, err := s.catalog.DueSchedules(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	runs := 0
+	for _, schedule := range due {
+		n, err := s.runSchedule(ctx, schedule, now)
+		runs += n
+		if err != nil {
+			log.Printf("Schedule runner: schedule %s: %v", schedule.Name, err)
+		}
+	}
+
+	// Due ticks were retried above; the rest are not due any more
+	expired, err := s.catalog.FailStaleScheduleRuns(ctx, now.Add(-staleRunTimeout), now, "the schedule runner stopped before the run finished")
+	if err != nil {
+		return runs, err
+	}
+	if expired > 0 {
+		log.Printf("Schedule runner: expired %d interrupted schedule runs", expired)
+	}
+
+	return runs, nil
+}
+
+// runSchedule runs the due ticks of a schedule kept by its catch-up policy, then
+// moves its next run to the first tick after now
+func (s *ScheduleService) runSchedule(ctx context.Context, schedule *models.Schedule, now time.Time) (int, error) {
+	sched, loc, err := parseSchedule(schedule)
+	if err != nil {
+		return 0, err
+	}
+
+	var ticks []time.Time
+	next := *schedule.NextRunAt
+	for !next.IsZero() && !next.After(now) {
+		ticks = append(ticks, next)
+		if len(ticks) > maxCatchUpRuns {
+			ticks = ticks[1:]
+		}
+		next = sched.Next(next.In(loc))
+	}
+
+	switch schedule.CatchUp {
+	case models.ScheduleCatchUpSkip:
+		kept := ticks[:0]
+		for _, tick := range ticks {
+			if now.Sub(tick) <= missedRunGrace {
+				kept = append(kept, tick)
+			}
+		}
+		ticks = kept
+	case models.ScheduleCatchUpLatest:
+		if len(ticks) > 1 {
+			ticks = ticks[len(ticks)-1:]
+		}
+	}
+
+	runs := 0
+	for _, tick := range ticks {
+		ran, err := s.runTick(ctx, schedule, tick.In(loc))
+		if err != nil {
+			return runs, err
+		}
+		if ran {
+			runs++
+		}
+	}
+
+	from := *schedule.NextRunAt
+	schedule.NextRunAt = nil
+	if !next.IsZero() {
+		schedule.NextRunAt = &next
+	}
+	if len(ticks) > 0 {
+		schedule.LastRunAt = &ticks[len(ticks)-1]
+	}
+	schedule.UpdatedAt = time.Now()
+	if err := s.catalog.AdvanceSchedule(ctx, schedule, from); err != nil {
+		return runs, err
+	}
+
+	return runs, nil
+}
+
+// runTick enqueues the message of one tick and records the run. It returns false
+// when the tick was already run, and retries it when its run was interrupted.
+func (s *ScheduleService) runTick(ctx context.Context, schedule *models.Schedule, tick time.Time) (bool, error) {
+	run := &models.ScheduleRun{
+		ScheduleID:   schedule.ID,
+		ScheduledFor: tick,
+		Status:       models.ScheduleRunRunning,
+		StartedAt:    time.Now(),
+	}
+
+	inserted, err := s.catalog.InsertScheduleRun(ctx, run, run.StartedAt.Add(-staleRunTimeout))
+	if err != nil || !inserted {
+		return false, err
+	}
+
+	payload, err := renderPayload(schedule, tick)
+	if err == nil {
+		var message *models.Message
+		message, err = s.queueService.createMessage(ctx, &models.CreateMessageRequest{
+			QueueID:  schedule.QueueID,
+			Payload:  payload,
+			Priority: schedule.Priority,
+			Headers:  schedule.Headers,
+			DedupID:  tickDedupID(schedule, tick),
+		}, scheduleDedupWindow)
+		if err == nil {
+			run.MessageID = &message.ID
+		}
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = models.ScheduleRunEnqueued
+	if err != nil {
+		run.Status = models.ScheduleRunFailed
+		run.Error = err.Error()
+	}
+
+	return true, s.catalog.UpdateScheduleRun(ctx, run)
+}
+
+// tickDedupID returns the dedup_id of the message of a schedule's tick
+func tickDedupID(schedule *models.Schedule, tick time.Time) string {
+	return fmt.Sprintf("schedule-%d-%d", schedule.ID, tick.Unix())
+}
+
+// validateSchedule checks the cron expression, timezone, catch-up policy, payload
+// template and target queue of a schedule, looking the queue up in catalog
+func (s *ScheduleService) validateSchedule(ctx context.Context, catalog storage.Catalog, schedule *models.Schedule) error {
+	if _, _, err := parseSchedule(schedule); err != nil {
+		return err
+	}
+
+	switch schedule.CatchUp {
+	case models.ScheduleCatchUpSkip, models.ScheduleCatchUpLatest, models.ScheduleCatchUpAll:
+	default:
+		return fmt.Errorf("%w: unknown catch_up policy %q", ErrInvalidSchedule, schedule.CatchUp)
+	}
+
+	if schedule.PayloadTemplate == "" {
+		return fmt.Errorf("%w: payload_template is required", ErrInvalidSchedule)
+	}
+	if _, err := renderPayload(schedule, time.Now()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+
+	if _, err := catalog.GetQueue(ctx, schedule.QueueID); errors.Is(err, ErrQueueNotFound) {
+		return fmt.Errorf("%w: queue %d does not exist", ErrInvalidSchedule, schedule.QueueID)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseSchedule parses the cron expression of a schedule and loads its timezone
+func parseSchedule(schedule *models.Schedule) (cron.Schedule, *time.Location, error) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, schedule.Timezone)
+	}
+
+	// The timezone field is the only way to set the zone, so it cannot disagree with the expression
+	if strings.HasPrefix(schedule.CronExpr, "TZ=") || strings.HasPrefix(schedule.CronExpr, "CRON_TZ=") {
+		return nil, nil, fmt.Errorf("%w: set the timezone field instead of a TZ prefix", ErrInvalidSchedule)
+	}
+
+	sched, err := cron.ParseStandard(schedule.CronExpr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid cron_expr: %v", ErrInvalidSchedule, err)
+	}
+
+	return sched, loc, nil
+}
+
+// setNextRun sets the next run of a schedule to its first tick after now, or clears
+// it when the schedule is disabled or never ticks again
+func setNextRun(schedule *models.Schedule, now time.Time) error {
+	schedule.NextRunAt = nil
+	if !schedule.Enabled {
+		return nil
+	}
+
+	sched, loc, err := parseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	if next := sched.Next(now.In(loc)); !next.IsZero() {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+// renderPayload renders the payload template of a schedule for a tick
+func renderPayload(schedule *models.Schedule, tick time.Time) (string, error) {
+	tmpl, err := template.New(schedule.Name).Option("missingkey=error").Parse(schedule.PayloadTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid payload_template: %w", err)
+	}
+
+	var payload strings.Builder
+	err = tmpl.Execute(&payload, scheduleRunData{
+		Schedule:     schedule.Name,
+		ScheduledFor: tick,
+		Unix:         tick.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render payload_template: %w", err)
+	}
+
+	return payload.String(), nil
+}
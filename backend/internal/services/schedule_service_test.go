@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+)
+
+// newDueSchedule creates a schedule firing every minute with the given catch-up
+// policy, next due at nextRun
+func newDueSchedule(t *testing.T, catchUp string, nextRun time.Time) (*ScheduleService, *models.Schedule) {
+	t.Helper()
+	ctx := context.Background()
+	s, schedules, catalog := newMemoryServices()
+
+	queue, err := s.CreateQueue(ctx, &models.CreateQueueRequest{Name: "ticks", Type: "fifo"})
+	if err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+	schedule, err := schedules.CreateSchedule(ctx, &models.CreateScheduleRequest{
+		Name:            "every-minute",
+		CronExpr:        "* * * * *",
+		QueueID:         queue.ID,
+		PayloadTemplate: `{"at": {{.Unix}}}`,
+		CatchUp:         catchUp,
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule: %v", err)
+	}
+
+	schedule.NextRunAt = &nextRun
+	if err := catalog.UpdateSchedule(ctx, schedule); err != nil {
+		t.Fatalf("UpdateSchedule: %v", err)
+	}
+	return schedules, schedule
+}
+
+func TestRunScheduleCatchUp(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		catchUp   string
+		nextRun   time.Time
+		wantTicks []time.Time // latest first
+	}{
+		{
+			name:      "skip runs only the tick within the grace period",
+			catchUp:   models.ScheduleCatchUpSkip,
+			nextRun:   now.Add(-10 * time.Minute).Truncate(time.Minute),
+			wantTicks: []time.Time{now.Truncate(time.Minute)},
+		},
+		{
+			name:      "latest runs the last missed tick",
+			catchUp:   models.ScheduleCatchUpLatest,
+			nextRun:   now.Add(-10 * time.Minute).Truncate(time.Minute),
+			wantTicks: []time.Time{now.Truncate(time.Minute)},
+		},
+		{
+			name:    "all runs every missed tick",
+			catchUp: models.ScheduleCatchUpAll,
+			nextRun: now.Add(-3 * time.Minute).Truncate(time.Minute),
+			wantTicks: []time.Time{
+				now.Truncate(time.Minute),
+				now.Truncate(time.Minute).Add(-time.Minute),
+				now.Truncate(time.Minute).Add(-2 * time.Minute),
+				now.Truncate(time.Minute).Add(-3 * time.Minute),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			schedules, schedule := newDueSchedule(t, tt.catchUp, tt.nextRun)
+
+			runs, err := schedules.runSchedule(ctx, schedule, now)
+			if err != nil {
+				t.Fatalf("runSchedule: %v", err)
+			}
+			if runs != len(tt.wantTicks) {
+				t.Fatalf("runSchedule ran %d ticks, want %d", runs, len(tt.wantTicks))
+			}
+
+			history, err := schedules.GetScheduleRuns(ctx, schedule.ID, 0)
+			if err != nil {
+				t.Fatalf("GetScheduleRuns: %v", err)
+			}
+			if len(history) != len(tt.wantTicks) {
+				t.Fatalf("GetScheduleRuns returned %d runs, want %d", len(history), len(tt.wantTicks))
+			}
+			for i, run := range history {
+				if !run.ScheduledFor.Equal(tt.wantTicks[i]) || run.Status != models.ScheduleRunEnqueued {
+					t.Fatalf("run %d is for %v with status %s, want %v enqueued", i, run.ScheduledFor, run.Status, tt.wantTicks[i])
+				}
+			}
+
+			advanced, err := schedules.GetSchedule(ctx, schedule.ID)
+			if err != nil {
+				t.Fatalf("GetSchedule: %v", err)
+			}
+			if want := now.Truncate(time.Minute).Add(time.Minute); advanced.NextRunAt == nil || !advanced.NextRunAt.Equal(want) {
+				t.Fatalf("next run is %v, want %v", advanced.NextRunAt, want)
+			}
+		})
+	}
+}
+
+func TestRunScheduleCapsCatchUp(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	last := now.Truncate(time.Minute)
+	schedules, schedule := newDueSchedule(t, models.ScheduleCatchUpAll, last.Add(-3*maxCatchUpRuns*time.Minute))
+
+	runs, err := schedules.runSchedule(ctx, schedule, now)
+	if err != nil {
+		t.Fatalf("runSchedule: %v", err)
+	}
+	if runs != maxCatchUpRuns {
+		t.Fatalf("runSchedule ran %d ticks, want the cap of %d", runs, maxCatchUpRuns)
+	}
+
+	history, err := schedules.GetScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("GetScheduleRuns: %v", err)
+	}
+	first := last.Add(-(maxCatchUpRuns - 1) * time.Minute)
+	if len(history) != maxCatchUpRuns || !history[0].ScheduledFor.Equal(last) || !history[len(history)-1].ScheduledFor.Equal(first) {
+		t.Fatalf("GetScheduleRuns returned %d runs from %v to %v, want the latest %d from %v to %v",
+			len(history), history[len(history)-1].ScheduledFor, history[0].ScheduledFor, maxCatchUpRuns, first, last)
+	}
+
+	advanced, err := schedules.GetSchedule(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("GetSchedule: %v", err)
+	}
+	if want := last.Add(time.Minute); advanced.NextRunAt == nil || !advanced.NextRunAt.Equal(want) {
+		t.Fatalf("next run is %v, want %v", advanced.NextRunAt, want)
+	}
+	if advanced.LastRunAt == nil || !advanced.LastRunAt.Equal(last) {
+		t.Fatalf("last run is %v, want %v", advanced.LastRunAt, last)
+	}
+}
+
+func TestRunScheduleRetriesInterruptedTick(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	tick := now.Truncate(time.Minute)
+	schedules, schedule := newDueSchedule(t, models.ScheduleCatchUpLatest, tick)
+
+	// A runner died after recording the tick and before enqueueing its message
+	crashed := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick, Status: models.ScheduleRunRunning, StartedAt: now.Add(-2 * staleRunTimeout)}
+	if _, err := schedules.catalog.InsertScheduleRun(ctx, crashed, now); err != nil {
+		t.Fatalf("InsertScheduleRun: %v", err)
+	}
+
+	runs, err := schedules.runSchedule(ctx, schedule, now)
+	if err != nil || runs != 1 {
+		t.Fatalf("runSchedule: ran %d, %v; want the interrupted tick retried", runs, err)
+	}
+
+	history, err := schedules.GetScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("GetScheduleRuns: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != crashed.ID || history[0].Status != models.ScheduleRunEnqueued || history[0].MessageID == nil {
+		t.Fatalf("GetScheduleRuns returned %+v, want the interrupted run enqueued", history)
+	}
+}
+
+func TestRunScheduleRetriedTickOutlivesShortDedupWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	tick := now.Truncate(time.Minute)
+	schedules, schedule := newDueSchedule(t, models.ScheduleCatchUpLatest, tick)
+
+	config := `{"dedup_window_seconds": 1}`
+	if _, err := schedules.queueService.UpdateQueue(ctx, schedule.QueueID, &models.UpdateQueueRequest{Config: &config}, 0, false); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+
+	// A runner died after enqueueing the tick's message and before recording the run
+	crashed := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick, Status: models.ScheduleRunRunning, StartedAt: now.Add(-2 * staleRunTimeout)}
+	if _, err := schedules.catalog.InsertScheduleRun(ctx, crashed, now); err != nil {
+		t.Fatalf("InsertScheduleRun: %v", err)
+	}
+	enqueued, err := schedules.queueService.createMessage(ctx, &models.CreateMessageRequest{
+		QueueID: schedule.QueueID,
+		Payload: "tick",
+		DedupID: tickDedupID(schedule, tick),
+	}, scheduleDedupWindow)
+	if err != nil {
+		t.Fatalf("createMessage: %v", err)
+	}
+
+	// Outlive the queue's dedup window
+	time.Sleep(1100 * time.Millisecond)
+
+	runs, err := schedules.runSchedule(ctx, schedule, now)
+	if err != nil || runs != 1 {
+		t.Fatalf("runSchedule: ran %d, %v; want the interrupted tick retried", runs, err)
+	}
+
+	history, err := schedules.GetScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("GetScheduleRuns: %v", err)
+	}
+	if len(history) != 1 || history[0].MessageID == nil || *history[0].MessageID != enqueued.ID {
+		t.Fatalf("GetScheduleRuns returned %+v, want the run to point at message %d", history, enqueued.ID)
+	}
+	messages, err := schedules.queueService.GetMessages(ctx, schedule.QueueID, 10, nil)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("GetMessages returned %d messages, want the tick enqueued once", len(messages))
+	}
+}
+
+func TestRunDueSchedulesExpiresInterruptedRuns(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	schedules, schedule := newDueSchedule(t, models.ScheduleCatchUpLatest, now.Add(time.Hour))
+
+	// The tick is no longer due, so the run is not retried
+	crashed := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: now.Add(-time.Hour), Status: models.ScheduleRunRunning, StartedAt: now.Add(-time.Hour)}
+	if _, err := schedules.catalog.InsertScheduleRun(ctx, crashed, now); err != nil {
+		t.Fatalf("InsertScheduleRun: %v", err)
+	}
+
+	if _, err := schedules.RunDueSchedules(ctx); err != nil {
+		t.Fatalf("RunDueSchedules: %v", err)
+	}
+
+	history, err := schedules.GetScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("GetScheduleRuns: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.ScheduleRunFailed || history[0].FinishedAt == nil {
+		t.Fatalf("GetScheduleRuns returned %+v, want the interrupted run failed", history)
+	}
+}
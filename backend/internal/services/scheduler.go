@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+)
+
+// Scheduler promotes scheduled messages to pending once they are due and wakes the
+// consumers waiting on their queues. Every replica runs one: the storage backend
+// hands each due message to a single promoter, so replicas share the work instead
+// of promoting a message twice.
+type Scheduler struct {
+	queueService      *QueueService
+	monitoringService *MonitoringService
+	interval          time.Duration
+}
+
+func NewScheduler(queueService *QueueService, monitoringService *MonitoringService, interval time.Duration) *Scheduler {
+	return &Scheduler{queueService: queueService, monitoringService: monitoringService, interval: interval}
+}
+
+// Run blocks until ctx is cancelled. It sleeps until the earliest scheduled message
+// is due, but never longer than the interval, so messages scheduled through another
+// replica are promoted without much delay.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		promoted, err := s.queueService.PromoteDueMessages(ctx)
+		if err != nil {
+			log.Printf("Scheduler: %v", err)
+		}
+
+		now := time.Now()
+		for _, message := range promoted {
+			s.monitoringService.ObserveSchedulingLag("queue_"+strconv.FormatInt(message.QueueID, 10), now.Sub(*message.ScheduledAt).Seconds())
+		}
+
+		sleep := s.interval
+		if next, err := s.queueService.backend.NextDue(ctx, 0); err != nil {
+			log.Printf("Scheduler: %v", err)
+		} else if next != nil && time.Until(*next) < sleep {
+			sleep = time.Until(*next)
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.queueService.scheduled:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// PromoteDueMessages moves every scheduled message that is due to pending and wakes
+// the consumers of the queues concerned. It returns the promoted messages.
+func (s *QueueService) PromoteDueMessages(ctx context.Context) ([]*models.Message, error) {
+	var promoted []*models.Message
+	for {
+		chunk, err := s.backend.PromoteDue(ctx, s.cfg.BulkChunkSize)
+		if err != nil {
+			return promoted, fmt.Errorf("failed to promote scheduled messages: %w", err)
+		}
+		if len(chunk) == 0 {
+			return promoted, nil
+		}
+
+		queues := make(map[int64]bool)
+		for _, message := range chunk {
+			queues[message.QueueID] = true
+		}
+		for queueID := range queues {
+			s.notifier.Notify(ctx, queueID)
+		}
+
+		promoted = append(promoted, chunk...)
+	}
+}
+
+// wakeScheduler tells the scheduler a message was scheduled, so it can shorten its
+// sleep if the message is due before its next run
+func (s *QueueService) wakeScheduler() {
+	select {
+	case s.scheduled <- struct{}{}:
+	default:
+	}
+}
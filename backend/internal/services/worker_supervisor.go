@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// WorkerSupervisor periodically stops the workers that stopped sending heartbeats,
+// so the messages they held are redelivered without waiting for their leases to
+// expire.
+type WorkerSupervisor struct {
+	queueService *QueueService
+	interval     time.Duration
+}
+
+func NewWorkerSupervisor(queueService *QueueService, interval time.Duration) *WorkerSupervisor {
+	return &WorkerSupervisor{queueService: queueService, interval: interval}
+}
+
+// Run blocks until ctx is cancelled
+func (s *WorkerSupervisor) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.queueService.SuperviseWorkers(ctx)
+			if err != nil {
+				log.Printf("Worker supervisor: %v", err)
+			}
+			if n > 0 {
+				log.Printf("Worker supervisor: stopped %d workers with lapsed heartbeats", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package storage defines the interfaces between the services and the system that
+// stores messages, queues, workers and schedules.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/selector"
+)
+
+var (
+	// ErrNoMessageAvailable is returned by Claim when no message is eligible for delivery
+	ErrNoMessageAvailable = errors.New("no message available")
+
+	// ErrMessageNotFound is returned when a message does not exist
+	ErrMessageNotFound = errors.New("message not found")
+
+	// ErrLeaseNotHeld is returned when a lease token does not match the message's
+	// current, unexpired lease
+	ErrLeaseNotHeld = errors.New("lease not held")
+)
+
+// Backend stores messages and implements their delivery lifecycle. Implementations
+// must be safe for concurrent use, and Claim must never hand the same message to
+// two callers while a lease on it is live.
+type Backend interface {
+	// Enqueue stores a new message, assigning its ID. When the message has a DedupID
+	// and a message of the same queue was stored under that key less than dedupWindow
+	// ago, nothing is stored and message is overwritten with the original instead. A
+	// dedupWindow of zero disables deduplication.
+	Enqueue(ctx context.Context, message *models.Message, dedupWindow time.Duration) error
+
+	// EnqueueBatch stores several messages at once, assigning their IDs and
+	// deduplicating them like Enqueue, including against each other. Either all
+	// messages are stored or none is.
+	EnqueueBatch(ctx context.Context, messages []*models.Message, dedupWindow time.Duration) error
+
+	// Get returns a single message
+	Get(ctx context.Context, id int64) (*models.Message, error)
+
+	// List returns messages, newest first
+	List(ctx context.Context, opts ListOptions) ([]*models.Message, error)
+
+	// Claim moves the next eligible message of a queue to processing under a new lease
+	Claim(ctx context.Context, queueID int64, opts ClaimOptions) (*models.Message, error)
+
+	// ClaimBatch claims up to limit messages at once, in delivery order. The messages
+	// share the lease in opts. It returns an empty slice when none is eligible.
+	ClaimBatch(ctx context.Context, queueID int64, limit int, opts ClaimOptions) ([]*models.Message, error)
+
+	// NextDue returns when the earliest scheduled message of a queue, or of any queue
+	// when queueID is zero, becomes due, or nil when none is scheduled in the future
+	NextDue(ctx context.Context, queueID int64) (*time.Time, error)
+
+	// PromoteDue moves up to limit scheduled messages whose scheduled_at has passed to
+	// pending, earliest first, and returns them. Concurrent callers never promote the
+	// same message twice.
+	PromoteDue(ctx context.Context, limit int) ([]*models.Message, error)
+
+	// ExtendLease moves the deadline of a live lease to expiresAt
+	ExtendLease(ctx context.Context, id int64, leaseToken string, expiresAt time.Time) (*models.Message, error)
+
+	// Ack marks a claimed message as completed
+	Ack(ctx context.Context, id int64, leaseToken string) (*models.Message, error)
+
+	// Nack records a failed delivery of a claimed message
+	Nack(ctx context.Context, id int64, leaseToken string, failure Failure) (*models.Message, error)
+
+	// Release returns a claimed message to pending without counting a retry
+	Release(ctx context.Context, id int64, leaseToken string) (*models.Message, error)
+
+	// ExpireLeases records a failure, as decided by decide, for every message whose
+	// lease has lapsed. It returns the number of messages affected.
+	ExpireLeases(ctx context.Context, decide func(*models.Message) Failure) (int64, error)
+
+	// ReleaseWorker returns every message held by a worker to pending without counting
+	// a retry, and returns the released messages
+	ReleaseWorker(ctx context.Context, workerID int64) ([]*models.Message, error)
+
+	// FailWorker records a failure, as decided by decide, for every message held by a
+	// worker. It returns the number of messages affected.
+	FailWorker(ctx context.Context, workerID int64, decide func(*models.Message) Failure) (int64, error)
+
+	// Redrive moves dead-lettered messages back to their source queue
+	Redrive(ctx context.Context, opts RedriveOptions) (int64, error)
+
+	// Depth returns the number of messages of a queue per status
+	Depth(ctx context.Context, queueID int64) (map[string]int64, error)
+
+	// Purge deletes every message of a queue
+	Purge(ctx context.Context, queueID int64) (int64, error)
+
+	// Bulk applies opts.Action to at most opts.Limit messages matching opts.Filter in
+	// one short transaction and returns how many it changed. Callers repeat it until
+	// it returns zero. Messages the action would leave matching are not selected, so
+	// repeating terminates: requeue skips pending, scheduled and in-flight messages and
+	// reprioritize skips messages already at the new priority. Messages locked by
+	// concurrent transactions are skipped rather than waited for, so zero does not
+	// mean that none is left; BulkRemaining tells.
+	Bulk(ctx context.Context, opts BulkOptions) (int64, error)
+
+	// BulkRemaining returns how many messages Bulk would still select for opts,
+	// ignoring opts.Limit and counting the messages locked by concurrent transactions
+	BulkRemaining(ctx context.Context, opts BulkOptions) (int64, error)
+
+	// DeleteFinished deletes the completed, failed and dead-lettered messages of a
+	// queue that were last updated before the given time. It returns the number of
+	// messages deleted.
+	DeleteFinished(ctx context.Context, queueID int64, before time.Time) (int64, error)
+
+	// ExpireDedupKeys forgets deduplication keys whose window has passed. It returns
+	// the number of keys removed.
+	ExpireDedupKeys(ctx context.Context) (int64, error)
+}
+
+// Order is the order in which Claim hands out eligible messages. Messages of a group
+// are always delivered oldest first, whatever the order.
+type Order int
+
+const (
+	// OrderPriority claims the highest priority first, oldest first within a priority
+	OrderPriority Order = iota
+
+	// OrderFIFO claims the oldest message first
+	OrderFIFO
+
+	// OrderLIFO claims the newest message first
+	OrderLIFO
+)
+
+// ListOptions filters the messages returned by List. Zero values do not filter.
+type ListOptions struct {
+	QueueID  int64
+	Status   string
+	Selector *selector.Selector
+	Limit    int
+}
+
+// ClaimOptions describes the lease granted by Claim
+type ClaimOptions struct {
+	WorkerID   int64
+	LeaseToken string
+	Lease      time.Duration
+	Selector   *selector.Selector // restricts the claim to messages whose headers match
+	Order      Order
+}
+
+// Failure describes the outcome of a failed delivery. With RetryAt set the message
+// is scheduled for that time, or returns to pending at once when it is not after
+// the time the failure is recorded; otherwise it moves to DeadLetterQueueID when
+// set, or is marked as failed.
+type Failure struct {
+	Error             string
+	RetryAt           *time.Time
+	DeadLetterQueueID *int64
+}
+
+// RedriveOptions selects the dead-lettered messages moved by Redrive. Zero values do not filter.
+type RedriveOptions struct {
+	DeadLetterQueueID  int64
+	SourceQueueID      int64
+	MessageIDs         []int64
+	ErrorContains      string
+	DeadLetteredBefore *time.Time
+	DeadLetteredAfter  *time.Time
+}
+
+// BulkAction is the change Bulk applies to each message it selects
+type BulkAction int
+
+const (
+	// BulkDelete deletes the messages
+	BulkDelete BulkAction = iota
+
+	// BulkRequeue returns finished messages to pending with a fresh retry budget
+	BulkRequeue
+
+	// BulkReprioritize sets the priority of the messages
+	BulkReprioritize
+
+	// BulkArchive copies the messages to the archive, then deletes them
+	BulkArchive
+)
+
+// MessageFilter selects the messages of a queue affected by a bulk operation. Zero
+// values do not filter.
+type MessageFilter struct {
+	QueueID       int64
+	Statuses      []string
+	MessageIDs    []int64
+	Selector      *selector.Selector
+	CreatedBefore *time.Time
+	CreatedAfter  *time.Time
+}
+
+// BulkOptions describes one chunk of a bulk operation
+type BulkOptions struct {
+	Filter   MessageFilter
+	Action   BulkAction
+	Priority int // new priority for BulkReprioritize
+	Limit    int // most messages changed, unbounded when zero
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+)
+
+var (
+	// ErrQueueNotFound is returned when a queue does not exist
+	ErrQueueNotFound = errors.New("queue not found")
+
+	// ErrWorkerNotFound is returned when a worker does not exist
+	ErrWorkerNotFound = errors.New("worker not found")
+
+	// ErrWorkerStopped is returned by PingWorker when the worker is stopped
+	ErrWorkerStopped = errors.New("worker is stopped")
+
+	// ErrScheduleNotFound is returned when a schedule does not exist
+	ErrScheduleNotFound = errors.New("schedule not found")
+
+	// ErrNameTaken is returned when a queue or schedule is stored under a name
+	// another one already has
+	ErrNameTaken = errors.New("name already taken")
+)
+
+// Catalog stores everything but messages: queues, workers and schedules with their
+// histories. Implementations must be safe for concurrent use.
+type Catalog interface {
+	// RunInTx calls fn with a Catalog whose changes are kept when fn returns nil and
+	// discarded when it returns an error. Rows read with the ForUpdate methods stay
+	// locked against other transactions until fn returns. Calling RunInTx on the
+	// Catalog passed to fn runs in the same transaction.
+	RunInTx(ctx context.Context, fn func(ctx context.Context, tx Catalog) error) error
+
+	// GetQueue returns a queue that is not deleted
+	GetQueue(ctx context.Context, id int64) (*models.Queue, error)
+
+	// GetQueueForUpdate returns a queue, deleted or not, and locks it until the
+	// transaction ends
+	GetQueueForUpdate(ctx context.Context, id int64) (*models.Queue, error)
+
+	// GetQueueByName returns the queue with the given name, deleted or not. Deleted
+	// queues keep their name until they are removed.
+	GetQueueByName(ctx context.Context, name string) (*models.Queue, error)
+
+	// ListQueues returns queues newest first, or most recently deleted first when
+	// listing deleted queues only
+	ListQueues(ctx context.Context, opts QueueListOptions) ([]*models.Queue, error)
+
+	// InsertQueue stores a new queue, assigning its ID
+	InsertQueue(ctx context.Context, queue *models.Queue) error
+
+	// UpdateQueue stores every mutable field of a queue, deleted or not
+	UpdateQueue(ctx context.Context, queue *models.Queue) error
+
+	// DeleteQueue removes a queue for good, with its workers and schedules. Queues
+	// using it as their dead letter queue lose the link. Its messages must be gone.
+	DeleteQueue(ctx context.Context, id int64) error
+
+	// InsertWorker stores a new worker, assigning its ID
+	InsertWorker(ctx context.Context, worker *models.Worker) error
+
+	// GetWorker returns a single worker
+	GetWorker(ctx context.Context, id int64) (*models.Worker, error)
+
+	// ListWorkers returns the workers of a queue, or of every queue when queueID is
+	// zero, newest first and with their queue
+	ListWorkers(ctx context.Context, queueID int64) ([]*models.Worker, error)
+
+	// PingWorker records a heartbeat of a worker with its status and the messages it
+	// holds. A stopped worker is not revived; ErrWorkerStopped is returned instead.
+	PingWorker(ctx context.Context, id int64, status string, messageIDs []int64, at time.Time) (*models.Worker, error)
+
+	// StopWorker marks a worker as stopped and forgets the messages it held
+	StopWorker(ctx context.Context, id int64, at time.Time) (*models.Worker, error)
+
+	// StopLapsedWorkers stops every worker whose last heartbeat is before
+	// lastPingBefore and returns them. Concurrent callers never stop the same worker.
+	StopLapsedWorkers(ctx context.Context, lastPingBefore time.Time, at time.Time) ([]*models.Worker, error)
+
+	// CountWorker adds to the processed and failed message counters of a worker
+	CountWorker(ctx context.Context, id int64, processed, failed int64) error
+
+	// InsertWorkerEvent adds an event to the history of a worker
+	InsertWorkerEvent(ctx context.Context, event *models.WorkerEvent) error
+
+	// ListWorkerEvents returns the most recent events of a worker, newest first. A
+	// limit of zero returns them all.
+	ListWorkerEvents(ctx context.Context, workerID int64, limit int) ([]*models.WorkerEvent, error)
+
+	// ListSchedules returns every schedule, by name
+	ListSchedules(ctx context.Context) ([]*models.Schedule, error)
+
+	// GetSchedule returns a single schedule
+	GetSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+
+	// GetScheduleForUpdate returns a schedule and locks it until the transaction ends
+	GetScheduleForUpdate(ctx context.Context, id int64) (*models.Schedule, error)
+
+	// InsertSchedule stores a new schedule, assigning its ID
+	InsertSchedule(ctx context.Context, schedule *models.Schedule) error
+
+	// UpdateSchedule stores every mutable field of a schedule
+	UpdateSchedule(ctx context.Context, schedule *models.Schedule) error
+
+	// DeleteSchedule removes a schedule with its run history and returns it
+	DeleteSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+
+	// DueSchedules returns the enabled schedules whose next run is not after now,
+	// earliest first
+	DueSchedules(ctx context.Context, now time.Time) ([]*models.Schedule, error)
+
+	// AdvanceSchedule stores the next and last run of a schedule, unless its next
+	// run is no longer from because it was changed since it was read
+	AdvanceSchedule(ctx context.Context, schedule *models.Schedule, from time.Time) error
+
+	// InsertScheduleRun records the start of a tick, assigning the run's ID. It
+	// reports false and stores nothing when the tick is already recorded, unless
+	// that run is still running and started before staleBefore: its runner died,
+	// so the run is taken over under its ID with the new start time.
+	InsertScheduleRun(ctx context.Context, run *models.ScheduleRun, staleBefore time.Time) (bool, error)
+
+	// UpdateScheduleRun stores the outcome of a run: its status, message, error and
+	// finish time
+	UpdateScheduleRun(ctx context.Context, run *models.ScheduleRun) error
+
+	// FailStaleScheduleRuns marks every run still running that started before
+	// staleBefore as failed with the given error, and reports how many it marked
+	FailStaleScheduleRuns(ctx context.Context, staleBefore, at time.Time, reason string) (int, error)
+
+	// ListScheduleRuns returns the most recent runs of a schedule, latest tick first.
+	// A limit of zero returns them all.
+	ListScheduleRuns(ctx context.Context, scheduleID int64, limit int) ([]*models.ScheduleRun, error)
+}
+
+// Deleted selects queues by deletion in ListQueues
+type Deleted int
+
+const (
+	// ExcludeDeleted lists the queues that are not deleted
+	ExcludeDeleted Deleted = iota
+
+	// OnlyDeleted lists the deleted queues
+	OnlyDeleted
+
+	// IncludeDeleted lists every queue
+	IncludeDeleted
+)
+
+// QueueListOptions filters the queues returned by ListQueues. Zero values do not filter.
+type QueueListOptions struct {
+	Deleted           Deleted
+	DeletedBefore     *time.Time // only queues deleted before this time
+	DeadLetterQueueID int64      // only queues dead-lettering to this queue
+}
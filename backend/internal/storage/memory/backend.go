@@ -0,0 +1,649 @@
+// Package memory implements storage.Backend and storage.Catalog in process memory.
+// It is meant for tests and local development; nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+type Backend struct {
+	mu       sync.Mutex
+	nextID   int64
+	messages map[int64]*models.Message
+	dedup    map[dedupKey]dedupEntry
+	archived map[int64]*models.Message
+}
+
+type dedupKey struct {
+	queueID int64
+	dedupID string
+}
+
+type dedupEntry struct {
+	messageID int64
+	expiresAt time.Time
+}
+
+func New() *Backend {
+	return &Backend{
+		messages: make(map[int64]*models.Message),
+		dedup:    make(map[dedupKey]dedupEntry),
+		archived: make(map[int64]*models.Message),
+	}
+}
+
+var _ storage.Backend = (*Backend)(nil)
+
+func (b *Backend) Enqueue(ctx context.Context, message *models.Message, dedupWindow time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.enqueue(message, dedupWindow, time.Now())
+	return nil
+}
+
+func (b *Backend) EnqueueBatch(ctx context.Context, messages []*models.Message, dedupWindow time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for _, message := range messages {
+		b.enqueue(message, dedupWindow, now)
+	}
+	return nil
+}
+
+// enqueue stores message unless its deduplication key is held, in which case message
+// is overwritten with the original. The caller must hold b.mu.
+func (b *Backend) enqueue(message *models.Message, dedupWindow time.Duration, now time.Time) {
+	dedup := message.DedupID != "" && dedupWindow > 0
+	key := dedupKey{message.QueueID, message.DedupID}
+
+	if dedup {
+		if entry, ok := b.dedup[key]; ok && entry.expiresAt.After(now) {
+			if original, ok := b.messages[entry.messageID]; ok {
+				*message = *clone(original)
+				return
+			}
+		}
+	}
+
+	if message.Status == "" {
+		message.Status = models.MessageStatusPending
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = now
+	}
+	if message.UpdatedAt.IsZero() {
+		message.UpdatedAt = now
+	}
+
+	b.nextID++
+	message.ID = b.nextID
+	b.messages[message.ID] = clone(message)
+
+	if dedup {
+		b.dedup[key] = dedupEntry{messageID: message.ID, expiresAt: now.Add(dedupWindow)}
+	}
+}
+
+func (b *Backend) Get(ctx context.Context, id int64) (*models.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	message, ok := b.messages[id]
+	if !ok {
+		return nil, storage.ErrMessageNotFound
+	}
+	return clone(message), nil
+}
+
+func (b *Backend) List(ctx context.Context, opts storage.ListOptions) ([]*models.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []*models.Message
+	for _, message := range b.messages {
+		if opts.QueueID > 0 && message.QueueID != opts.QueueID {
+			continue
+		}
+		if opts.Status != "" && message.Status != opts.Status {
+			continue
+		}
+		if !opts.Selector.Matches(message.Headers) {
+			continue
+		}
+		messages = append(messages, clone(message))
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].CreatedAt.Equal(messages[j].CreatedAt) {
+			return messages[i].CreatedAt.After(messages[j].CreatedAt)
+		}
+		return messages[i].ID > messages[j].ID
+	})
+
+	if opts.Limit > 0 && len(messages) > opts.Limit {
+		messages = messages[:opts.Limit]
+	}
+	return messages, nil
+}
+
+func (b *Backend) Claim(ctx context.Context, queueID int64, opts storage.ClaimOptions) (*models.Message, error) {
+	messages, err := b.ClaimBatch(ctx, queueID, 1, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, storage.ErrNoMessageAvailable
+	}
+	return messages[0], nil
+}
+
+func (b *Backend) ClaimBatch(ctx context.Context, queueID int64, limit int, opts storage.ClaimOptions) ([]*models.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	heads := b.groupHeads(queueID)
+
+	var candidates []*models.Message
+	for _, message := range b.messages {
+		if message.QueueID != queueID || !eligible(message, now) || !opts.Selector.Matches(message.Headers) {
+			continue
+		}
+		if message.GroupKey != "" && heads[message.GroupKey] != message.ID {
+			continue
+		}
+		candidates = append(candidates, message)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return deliveredBefore(candidates[i], candidates[j], opts.Order)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	expiresAt := now.Add(opts.Lease)
+	claimed := make([]*models.Message, 0, len(candidates))
+	for _, message := range candidates {
+		workerID := opts.WorkerID
+		message.Status = models.MessageStatusProcessing
+		message.WorkerID = &workerID
+		message.ClaimedAt = &now
+		message.LeaseToken = opts.LeaseToken
+		message.LeaseExpiresAt = &expiresAt
+		message.UpdatedAt = now
+		claimed = append(claimed, clone(message))
+	}
+
+	return claimed, nil
+}
+
+func (b *Backend) NextDue(ctx context.Context, queueID int64) (*time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	var next *time.Time
+	for _, message := range b.messages {
+		if (queueID > 0 && message.QueueID != queueID) || message.Status != models.MessageStatusScheduled ||
+			message.ScheduledAt == nil || !message.ScheduledAt.After(now) {
+			continue
+		}
+		if next == nil || message.ScheduledAt.Before(*next) {
+			due := *message.ScheduledAt
+			next = &due
+		}
+	}
+	return next, nil
+}
+
+func (b *Backend) PromoteDue(ctx context.Context, limit int) ([]*models.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	var due []*models.Message
+	for _, message := range b.messages {
+		if message.Status == models.MessageStatusScheduled && message.ScheduledAt != nil && !message.ScheduledAt.After(now) {
+			due = append(due, message)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].ScheduledAt.Before(*due[j].ScheduledAt) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	promoted := make([]*models.Message, len(due))
+	for i, message := range due {
+		message.Status = models.MessageStatusPending
+		message.UpdatedAt = now
+		promoted[i] = clone(message)
+	}
+	return promoted, nil
+}
+
+func (b *Backend) ExtendLease(ctx context.Context, id int64, leaseToken string, expiresAt time.Time) (*models.Message, error) {
+	return b.updateLeased(id, leaseToken, func(message *models.Message, now time.Time) {
+		message.LeaseExpiresAt = &expiresAt
+	})
+}
+
+func (b *Backend) Ack(ctx context.Context, id int64, leaseToken string) (*models.Message, error) {
+	return b.updateLeased(id, leaseToken, func(message *models.Message, now time.Time) {
+		endLease(message)
+		message.Status = models.MessageStatusCompleted
+		message.ProcessedAt = &now
+	})
+}
+
+func (b *Backend) Nack(ctx context.Context, id int64, leaseToken string, failure storage.Failure) (*models.Message, error) {
+	return b.updateLeased(id, leaseToken, func(message *models.Message, now time.Time) {
+		endLease(message)
+		applyFailure(message, failure, now)
+	})
+}
+
+func (b *Backend) Release(ctx context.Context, id int64, leaseToken string) (*models.Message, error) {
+	return b.updateLeased(id, leaseToken, func(message *models.Message, now time.Time) {
+		endLease(message)
+		message.Status = models.MessageStatusPending
+		message.WorkerID = nil
+		message.ClaimedAt = nil
+	})
+}
+
+func (b *Backend) ExpireLeases(ctx context.Context, decide func(*models.Message) storage.Failure) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	var affected int64
+	for _, message := range b.messages {
+		if message.Status != models.MessageStatusProcessing || message.LeaseExpiresAt == nil || message.LeaseExpiresAt.After(now) {
+			continue
+		}
+
+		// Recorded after the decision, so a retry decided for right away is due
+		failure := decide(clone(message))
+		failedAt := time.Now()
+		endLease(message)
+		applyFailure(message, failure, failedAt)
+		message.UpdatedAt = failedAt
+		affected++
+	}
+
+	return affected, nil
+}
+
+func (b *Backend) ReleaseWorker(ctx context.Context, workerID int64) ([]*models.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	var released []*models.Message
+	for _, message := range b.messages {
+		if message.Status != models.MessageStatusProcessing || message.WorkerID == nil || *message.WorkerID != workerID {
+			continue
+		}
+
+		endLease(message)
+		message.Status = models.MessageStatusPending
+		message.WorkerID = nil
+		message.ClaimedAt = nil
+		message.UpdatedAt = now
+		released = append(released, clone(message))
+	}
+
+	return released, nil
+}
+
+func (b *Backend) FailWorker(ctx context.Context, workerID int64, decide func(*models.Message) storage.Failure) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var affected int64
+	for _, message := range b.messages {
+		if message.Status != models.MessageStatusProcessing || message.WorkerID == nil || *message.WorkerID != workerID {
+			continue
+		}
+
+		// Recorded after the decision, so a retry decided for right away is due
+		failure := decide(clone(message))
+		failedAt := time.Now()
+		endLease(message)
+		applyFailure(message, failure, failedAt)
+		message.UpdatedAt = failedAt
+		affected++
+	}
+
+	return affected, nil
+}
+
+func (b *Backend) Redrive(ctx context.Context, opts storage.RedriveOptions) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids := make(map[int64]bool, len(opts.MessageIDs))
+	for _, id := range opts.MessageIDs {
+		ids[id] = true
+	}
+
+	now := time.Now()
+
+	var affected int64
+	for _, message := range b.messages {
+		if message.QueueID != opts.DeadLetterQueueID || message.Status != models.MessageStatusDeadLettered || message.OriginalQueueID == nil {
+			continue
+		}
+		if opts.SourceQueueID > 0 && *message.OriginalQueueID != opts.SourceQueueID {
+			continue
+		}
+		if len(ids) > 0 && !ids[message.ID] {
+			continue
+		}
+		if opts.ErrorContains != "" && !strings.Contains(strings.ToLower(message.ErrorMessage), strings.ToLower(opts.ErrorContains)) {
+			continue
+		}
+		if opts.DeadLetteredBefore != nil && (message.DeadLetteredAt == nil || message.DeadLetteredAt.After(*opts.DeadLetteredBefore)) {
+			continue
+		}
+		if opts.DeadLetteredAfter != nil && (message.DeadLetteredAt == nil || message.DeadLetteredAt.Before(*opts.DeadLetteredAfter)) {
+			continue
+		}
+
+		message.QueueID = *message.OriginalQueueID
+		message.OriginalQueueID = nil
+		message.Status = models.MessageStatusPending
+		message.RetryCount = 0
+		message.ScheduledAt = nil
+		message.DeadLetteredAt = nil
+		message.UpdatedAt = now
+		affected++
+	}
+
+	return affected, nil
+}
+
+func (b *Backend) Depth(ctx context.Context, queueID int64) (map[string]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	depth := make(map[string]int64)
+	for _, message := range b.messages {
+		if message.QueueID == queueID {
+			depth[message.Status]++
+		}
+	}
+	return depth, nil
+}
+
+func (b *Backend) Purge(ctx context.Context, queueID int64) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var affected int64
+	for id, message := range b.messages {
+		if message.QueueID == queueID {
+			delete(b.messages, id)
+			affected++
+		}
+	}
+	return affected, nil
+}
+
+func (b *Backend) Bulk(ctx context.Context, opts storage.BulkOptions) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var selected []*models.Message
+	for _, message := range b.messages {
+		if bulkSelects(message, opts) {
+			selected = append(selected, message)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+	if opts.Limit > 0 && len(selected) > opts.Limit {
+		selected = selected[:opts.Limit]
+	}
+
+	now := time.Now()
+	for _, message := range selected {
+		switch opts.Action {
+		case storage.BulkDelete:
+			delete(b.messages, message.ID)
+		case storage.BulkRequeue:
+			message.Status = models.MessageStatusPending
+			message.RetryCount = 0
+			message.ScheduledAt = nil
+			message.DeadLetteredAt = nil
+			message.WorkerID = nil
+			message.UpdatedAt = now
+		case storage.BulkReprioritize:
+			message.Priority = opts.Priority
+			message.UpdatedAt = now
+		case storage.BulkArchive:
+			b.archived[message.ID] = message
+			delete(b.messages, message.ID)
+		}
+	}
+
+	return int64(len(selected)), nil
+}
+
+func (b *Backend) BulkRemaining(ctx context.Context, opts storage.BulkOptions) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var n int64
+	for _, message := range b.messages {
+		if bulkSelects(message, opts) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (b *Backend) DeleteFinished(ctx context.Context, queueID int64, before time.Time) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var affected int64
+	for id, message := range b.messages {
+		if message.QueueID != queueID || !message.UpdatedAt.Before(before) {
+			continue
+		}
+		switch message.Status {
+		case models.MessageStatusCompleted, models.MessageStatusFailed, models.MessageStatusDeadLettered:
+			delete(b.messages, id)
+			affected++
+		}
+	}
+	return affected, nil
+}
+
+func (b *Backend) ExpireDedupKeys(ctx context.Context) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var affected int64
+	for key, entry := range b.dedup {
+		if !entry.expiresAt.After(now) {
+			delete(b.dedup, key)
+			affected++
+		}
+	}
+	return affected, nil
+}
+
+// updateLeased applies update to a message while the caller's lease token is
+// current and unexpired, returning a copy of the updated message
+func (b *Backend) updateLeased(id int64, leaseToken string, update func(*models.Message, time.Time)) (*models.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	message, ok := b.messages[id]
+	if !ok {
+		return nil, storage.ErrMessageNotFound
+	}
+
+	now := time.Now()
+	if message.Status != models.MessageStatusProcessing || message.LeaseToken != leaseToken ||
+		message.LeaseExpiresAt == nil || !message.LeaseExpiresAt.After(now) {
+		return nil, storage.ErrLeaseNotHeld
+	}
+
+	update(message, now)
+	message.UpdatedAt = now
+
+	return clone(message), nil
+}
+
+// groupHeads returns, per message group of a queue, the ID of the only message of
+// the group that may be delivered next: its oldest pending message, or none (zero)
+// while a message of the group is in flight. The caller must hold b.mu.
+func (b *Backend) groupHeads(queueID int64) map[string]int64 {
+	heads := make(map[string]int64)
+	inFlight := make(map[string]bool)
+
+	for _, message := range b.messages {
+		if message.QueueID != queueID || message.GroupKey == "" {
+			continue
+		}
+		switch message.Status {
+		case models.MessageStatusProcessing:
+			inFlight[message.GroupKey] = true
+		case models.MessageStatusPending, models.MessageStatusScheduled:
+			if head, ok := heads[message.GroupKey]; !ok || message.ID < head {
+				heads[message.GroupKey] = message.ID
+			}
+		}
+	}
+
+	for group := range inFlight {
+		heads[group] = 0
+	}
+	return heads
+}
+
+// bulkSelects reports whether Bulk applies opts to message
+func bulkSelects(message *models.Message, opts storage.BulkOptions) bool {
+	if !matchesFilter(message, opts.Filter) {
+		return false
+	}
+	switch opts.Action {
+	case storage.BulkRequeue:
+		return message.Status != models.MessageStatusPending && message.Status != models.MessageStatusScheduled &&
+			message.Status != models.MessageStatusProcessing
+	case storage.BulkReprioritize:
+		return message.Priority != opts.Priority
+	}
+	return true
+}
+
+// matchesFilter reports whether message is selected by f
+func matchesFilter(message *models.Message, f storage.MessageFilter) bool {
+	if message.QueueID != f.QueueID {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, message.Status) {
+		return false
+	}
+	if len(f.MessageIDs) > 0 && !slices.Contains(f.MessageIDs, message.ID) {
+		return false
+	}
+	if f.CreatedBefore != nil && !message.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.CreatedAfter != nil && message.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	return f.Selector.Matches(message.Headers)
+}
+
+// eligible reports whether a message can be claimed at now
+func eligible(message *models.Message, now time.Time) bool {
+	return message.Status == models.MessageStatusPending &&
+		(message.ScheduledAt == nil || !message.ScheduledAt.After(now))
+}
+
+// deliveredBefore reports whether a should be claimed before b under order
+func deliveredBefore(a, b *models.Message, order storage.Order) bool {
+	if order == storage.OrderLIFO {
+		return deliveredBefore(b, a, storage.OrderFIFO)
+	}
+	if order == storage.OrderPriority && a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func endLease(message *models.Message) {
+	message.LeaseToken = ""
+	message.LeaseExpiresAt = nil
+}
+
+func applyFailure(message *models.Message, failure storage.Failure, now time.Time) {
+	message.FailedAt = &now
+	message.ErrorMessage = failure.Error
+	message.ErrorHistory = append(message.ErrorHistory, models.MessageError{
+		Attempt:  message.RetryCount + 1,
+		Error:    failure.Error,
+		FailedAt: now,
+	})
+	message.WorkerID = nil
+
+	switch {
+	case failure.RetryAt != nil:
+		retryAt := *failure.RetryAt
+		message.Status = models.MessageStatusPending
+		if retryAt.After(now) {
+			message.Status = models.MessageStatusScheduled
+		}
+		message.ScheduledAt = &retryAt
+		message.RetryCount++
+	case failure.DeadLetterQueueID != nil:
+		originalQueueID := message.QueueID
+		message.Status = models.MessageStatusDeadLettered
+		message.OriginalQueueID = &originalQueueID
+		message.QueueID = *failure.DeadLetterQueueID
+		message.DeadLetteredAt = &now
+	default:
+		message.Status = models.MessageStatusFailed
+	}
+}
+
+// clone returns a deep copy of a message, so callers never share state with the store
+func clone(message *models.Message) *models.Message {
+	c := *message
+	c.Queue = nil
+	c.ErrorHistory = append([]models.MessageError(nil), message.ErrorHistory...)
+	if message.Headers != nil {
+		c.Headers = make(map[string]string, len(message.Headers))
+		for k, v := range message.Headers {
+			c.Headers[k] = v
+		}
+	}
+	return &c
+}
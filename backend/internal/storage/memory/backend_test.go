@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/shravan20/qafka/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storagetest.Harness {
+		var nextQueueID, nextWorkerID int64
+		return &storagetest.Harness{
+			Backend: New(),
+			NewQueue: func(t *testing.T) int64 {
+				nextQueueID++
+				return nextQueueID
+			},
+			NewWorker: func(t *testing.T) int64 {
+				nextWorkerID++
+				return nextWorkerID
+			},
+		}
+	})
+}
@@ -0,0 +1,596 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// Catalog implements storage.Catalog in process memory. A transaction holds the
+// catalog's lock until it ends, so transactions run one at a time.
+type Catalog struct {
+	mu   *sync.Mutex
+	data *catalogData
+	inTx bool // set on the Catalog handed to RunInTx's fn, which already holds mu
+}
+
+// catalogData holds the rows of the catalog. Stored rows are never changed in place,
+// only replaced, so a shallow copy of the maps is a snapshot.
+type catalogData struct {
+	nextID       int64
+	queues       map[int64]*models.Queue
+	workers      map[int64]*models.Worker
+	workerEvents map[int64][]*models.WorkerEvent // by worker, oldest first
+	schedules    map[int64]*models.Schedule
+	scheduleRuns map[int64][]*models.ScheduleRun // by schedule, in insertion order
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		mu: &sync.Mutex{},
+		data: &catalogData{
+			queues:       make(map[int64]*models.Queue),
+			workers:      make(map[int64]*models.Worker),
+			workerEvents: make(map[int64][]*models.WorkerEvent),
+			schedules:    make(map[int64]*models.Schedule),
+			scheduleRuns: make(map[int64][]*models.ScheduleRun),
+		},
+	}
+}
+
+var _ storage.Catalog = (*Catalog)(nil)
+
+// lock takes the catalog's lock unless c belongs to a transaction, and returns the
+// function releasing it
+func (c *Catalog) lock() func() {
+	if c.inTx {
+		return func() {}
+	}
+	c.mu.Lock()
+	return c.mu.Unlock
+}
+
+func (c *Catalog) RunInTx(ctx context.Context, fn func(ctx context.Context, tx storage.Catalog) error) error {
+	if c.inTx {
+		return fn(ctx, c)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := c.data.snapshot()
+	if err := fn(ctx, &Catalog{mu: c.mu, data: c.data, inTx: true}); err != nil {
+		*c.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+func (d *catalogData) snapshot() *catalogData {
+	return &catalogData{
+		nextID:       d.nextID,
+		queues:       maps.Clone(d.queues),
+		workers:      maps.Clone(d.workers),
+		workerEvents: maps.Clone(d.workerEvents),
+		schedules:    maps.Clone(d.schedules),
+		scheduleRuns: maps.Clone(d.scheduleRuns),
+	}
+}
+
+func (d *catalogData) newID() int64 {
+	d.nextID++
+	return d.nextID
+}
+
+// Queues
+
+func (c *Catalog) GetQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	defer c.lock()()
+
+	queue, ok := c.data.queues[id]
+	if !ok || queue.DeletedAt != nil {
+		return nil, storage.ErrQueueNotFound
+	}
+	return cloneQueue(queue), nil
+}
+
+func (c *Catalog) GetQueueForUpdate(ctx context.Context, id int64) (*models.Queue, error) {
+	defer c.lock()()
+
+	queue, ok := c.data.queues[id]
+	if !ok {
+		return nil, storage.ErrQueueNotFound
+	}
+	return cloneQueue(queue), nil
+}
+
+func (c *Catalog) GetQueueByName(ctx context.Context, name string) (*models.Queue, error) {
+	defer c.lock()()
+
+	for _, queue := range c.data.queues {
+		if queue.Name == name {
+			return cloneQueue(queue), nil
+		}
+	}
+	return nil, storage.ErrQueueNotFound
+}
+
+func (c *Catalog) ListQueues(ctx context.Context, opts storage.QueueListOptions) ([]*models.Queue, error) {
+	defer c.lock()()
+
+	queues := []*models.Queue{}
+	for _, queue := range c.data.queues {
+		deleted := queue.DeletedAt != nil
+		switch {
+		case opts.Deleted == storage.ExcludeDeleted && deleted,
+			opts.Deleted == storage.OnlyDeleted && !deleted:
+			continue
+		case opts.DeletedBefore != nil && (!deleted || !queue.DeletedAt.Before(*opts.DeletedBefore)):
+			continue
+		case opts.DeadLetterQueueID != 0 && (queue.DeadLetterQueueID == nil || *queue.DeadLetterQueueID != opts.DeadLetterQueueID):
+			continue
+		}
+		queues = append(queues, cloneQueue(queue))
+	}
+
+	sort.Slice(queues, func(i, j int) bool {
+		a, b := queues[i], queues[j]
+		if opts.Deleted == storage.OnlyDeleted && !a.DeletedAt.Equal(*b.DeletedAt) {
+			return a.DeletedAt.After(*b.DeletedAt)
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+	return queues, nil
+}
+
+func (c *Catalog) InsertQueue(ctx context.Context, queue *models.Queue) error {
+	defer c.lock()()
+
+	for _, other := range c.data.queues {
+		if other.Name == queue.Name {
+			return fmt.Errorf("%w: queue %q", storage.ErrNameTaken, queue.Name)
+		}
+	}
+
+	queue.ID = c.data.newID()
+	if queue.State == "" {
+		queue.State = models.QueueStateActive
+	}
+	if queue.Version == 0 {
+		queue.Version = 1
+	}
+	c.data.queues[queue.ID] = cloneQueue(queue)
+	return nil
+}
+
+func (c *Catalog) UpdateQueue(ctx context.Context, queue *models.Queue) error {
+	defer c.lock()()
+
+	stored, ok := c.data.queues[queue.ID]
+	if !ok {
+		return storage.ErrQueueNotFound
+	}
+
+	updated := cloneQueue(queue)
+	updated.Name = stored.Name
+	updated.Type = stored.Type
+	updated.CreatedAt = stored.CreatedAt
+	c.data.queues[queue.ID] = updated
+	return nil
+}
+
+func (c *Catalog) DeleteQueue(ctx context.Context, id int64) error {
+	defer c.lock()()
+
+	if _, ok := c.data.queues[id]; !ok {
+		return storage.ErrQueueNotFound
+	}
+	delete(c.data.queues, id)
+
+	for otherID, other := range c.data.queues {
+		if other.DeadLetterQueueID != nil && *other.DeadLetterQueueID == id {
+			unlinked := cloneQueue(other)
+			unlinked.DeadLetterQueueID = nil
+			c.data.queues[otherID] = unlinked
+		}
+	}
+	for workerID, worker := range c.data.workers {
+		if worker.QueueID == id {
+			delete(c.data.workers, workerID)
+			delete(c.data.workerEvents, workerID)
+		}
+	}
+	for scheduleID, schedule := range c.data.schedules {
+		if schedule.QueueID == id {
+			delete(c.data.schedules, scheduleID)
+			delete(c.data.scheduleRuns, scheduleID)
+		}
+	}
+	return nil
+}
+
+// Workers
+
+func (c *Catalog) InsertWorker(ctx context.Context, worker *models.Worker) error {
+	defer c.lock()()
+
+	if _, ok := c.data.queues[worker.QueueID]; !ok {
+		return fmt.Errorf("failed to register worker: %w", storage.ErrQueueNotFound)
+	}
+
+	worker.ID = c.data.newID()
+	c.data.workers[worker.ID] = cloneWorker(worker)
+	return nil
+}
+
+func (c *Catalog) GetWorker(ctx context.Context, id int64) (*models.Worker, error) {
+	defer c.lock()()
+
+	worker, ok := c.data.workers[id]
+	if !ok {
+		return nil, storage.ErrWorkerNotFound
+	}
+	return cloneWorker(worker), nil
+}
+
+func (c *Catalog) ListWorkers(ctx context.Context, queueID int64) ([]*models.Worker, error) {
+	defer c.lock()()
+
+	workers := []*models.Worker{}
+	for _, worker := range c.data.workers {
+		if queueID > 0 && worker.QueueID != queueID {
+			continue
+		}
+
+		w := cloneWorker(worker)
+		if queue, ok := c.data.queues[worker.QueueID]; ok && queue.DeletedAt == nil {
+			w.Queue = cloneQueue(queue)
+		}
+		workers = append(workers, w)
+	}
+
+	sort.Slice(workers, func(i, j int) bool {
+		if !workers[i].CreatedAt.Equal(workers[j].CreatedAt) {
+			return workers[i].CreatedAt.After(workers[j].CreatedAt)
+		}
+		return workers[i].ID > workers[j].ID
+	})
+	return workers, nil
+}
+
+func (c *Catalog) PingWorker(ctx context.Context, id int64, status string, messageIDs []int64, at time.Time) (*models.Worker, error) {
+	defer c.lock()()
+
+	stored, ok := c.data.workers[id]
+	if !ok {
+		return nil, storage.ErrWorkerNotFound
+	}
+	if stored.Status == models.WorkerStatusStopped {
+		return nil, storage.ErrWorkerStopped
+	}
+
+	worker := cloneWorker(stored)
+	worker.Status = status
+	worker.LastPing = at
+	worker.CurrentMessageIDs = slices.Clone(messageIDs)
+	worker.UpdatedAt = at
+	c.data.workers[id] = worker
+	return cloneWorker(worker), nil
+}
+
+func (c *Catalog) StopWorker(ctx context.Context, id int64, at time.Time) (*models.Worker, error) {
+	defer c.lock()()
+
+	stored, ok := c.data.workers[id]
+	if !ok {
+		return nil, storage.ErrWorkerNotFound
+	}
+
+	worker := stopped(stored, at)
+	c.data.workers[id] = worker
+	return cloneWorker(worker), nil
+}
+
+func (c *Catalog) StopLapsedWorkers(ctx context.Context, lastPingBefore time.Time, at time.Time) ([]*models.Worker, error) {
+	defer c.lock()()
+
+	lapsed := []*models.Worker{}
+	for id, stored := range c.data.workers {
+		if stored.Status == models.WorkerStatusStopped || !stored.LastPing.Before(lastPingBefore) {
+			continue
+		}
+
+		worker := stopped(stored, at)
+		c.data.workers[id] = worker
+		lapsed = append(lapsed, cloneWorker(worker))
+	}
+	return lapsed, nil
+}
+
+func (c *Catalog) CountWorker(ctx context.Context, id int64, processed, failed int64) error {
+	defer c.lock()()
+
+	stored, ok := c.data.workers[id]
+	if !ok {
+		return nil
+	}
+
+	worker := cloneWorker(stored)
+	worker.ProcessedCount += processed
+	worker.FailedCount += failed
+	worker.UpdatedAt = time.Now()
+	c.data.workers[id] = worker
+	return nil
+}
+
+func (c *Catalog) InsertWorkerEvent(ctx context.Context, event *models.WorkerEvent) error {
+	defer c.lock()()
+
+	if _, ok := c.data.workers[event.WorkerID]; !ok {
+		return fmt.Errorf("failed to record worker event: %w", storage.ErrWorkerNotFound)
+	}
+
+	event.ID = c.data.newID()
+	e := *event
+	// Appending to a copy keeps snapshots sharing the old slice intact
+	events := c.data.workerEvents[event.WorkerID]
+	c.data.workerEvents[event.WorkerID] = append(events[:len(events):len(events)], &e)
+	return nil
+}
+
+func (c *Catalog) ListWorkerEvents(ctx context.Context, workerID int64, limit int) ([]*models.WorkerEvent, error) {
+	defer c.lock()()
+
+	stored := c.data.workerEvents[workerID]
+	events := make([]*models.WorkerEvent, 0, len(stored))
+	for i := len(stored) - 1; i >= 0 && (limit <= 0 || len(events) < limit); i-- {
+		e := *stored[i]
+		events = append(events, &e)
+	}
+	return events, nil
+}
+
+// Schedules
+
+func (c *Catalog) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	defer c.lock()()
+
+	schedules := make([]*models.Schedule, 0, len(c.data.schedules))
+	for _, schedule := range c.data.schedules {
+		schedules = append(schedules, cloneSchedule(schedule))
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+	return schedules, nil
+}
+
+func (c *Catalog) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	defer c.lock()()
+
+	schedule, ok := c.data.schedules[id]
+	if !ok {
+		return nil, storage.ErrScheduleNotFound
+	}
+	return cloneSchedule(schedule), nil
+}
+
+func (c *Catalog) GetScheduleForUpdate(ctx context.Context, id int64) (*models.Schedule, error) {
+	return c.GetSchedule(ctx, id)
+}
+
+func (c *Catalog) InsertSchedule(ctx context.Context, schedule *models.Schedule) error {
+	defer c.lock()()
+
+	for _, other := range c.data.schedules {
+		if other.Name == schedule.Name {
+			return fmt.Errorf("%w: schedule %q", storage.ErrNameTaken, schedule.Name)
+		}
+	}
+	if _, ok := c.data.queues[schedule.QueueID]; !ok {
+		return fmt.Errorf("failed to create schedule: %w", storage.ErrQueueNotFound)
+	}
+
+	schedule.ID = c.data.newID()
+	c.data.schedules[schedule.ID] = cloneSchedule(schedule)
+	return nil
+}
+
+func (c *Catalog) UpdateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	defer c.lock()()
+
+	stored, ok := c.data.schedules[schedule.ID]
+	if !ok {
+		return storage.ErrScheduleNotFound
+	}
+
+	updated := cloneSchedule(schedule)
+	updated.Name = stored.Name
+	updated.CreatedAt = stored.CreatedAt
+	c.data.schedules[schedule.ID] = updated
+	return nil
+}
+
+func (c *Catalog) DeleteSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	defer c.lock()()
+
+	schedule, ok := c.data.schedules[id]
+	if !ok {
+		return nil, storage.ErrScheduleNotFound
+	}
+	delete(c.data.schedules, id)
+	delete(c.data.scheduleRuns, id)
+	return cloneSchedule(schedule), nil
+}
+
+func (c *Catalog) DueSchedules(ctx context.Context, now time.Time) ([]*models.Schedule, error) {
+	defer c.lock()()
+
+	due := []*models.Schedule{}
+	for _, schedule := range c.data.schedules {
+		if schedule.Enabled && schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			due = append(due, cloneSchedule(schedule))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextRunAt.Before(*due[j].NextRunAt) })
+	return due, nil
+}
+
+func (c *Catalog) AdvanceSchedule(ctx context.Context, schedule *models.Schedule, from time.Time) error {
+	defer c.lock()()
+
+	stored, ok := c.data.schedules[schedule.ID]
+	if !ok || stored.NextRunAt == nil || !stored.NextRunAt.Equal(from) {
+		return nil
+	}
+
+	advanced := cloneSchedule(stored)
+	advanced.NextRunAt = clonePtr(schedule.NextRunAt)
+	advanced.LastRunAt = clonePtr(schedule.LastRunAt)
+	advanced.UpdatedAt = schedule.UpdatedAt
+	c.data.schedules[schedule.ID] = advanced
+	return nil
+}
+
+func (c *Catalog) InsertScheduleRun(ctx context.Context, run *models.ScheduleRun, staleBefore time.Time) (bool, error) {
+	defer c.lock()()
+
+	runs := c.data.scheduleRuns[run.ScheduleID]
+	for i, other := range runs {
+		if !other.ScheduledFor.Equal(run.ScheduledFor) {
+			continue
+		}
+		if other.Status != models.ScheduleRunRunning || !other.StartedAt.Before(staleBefore) {
+			return false, nil
+		}
+
+		run.ID = other.ID
+		runs = slices.Clone(runs)
+		runs[i] = cloneRun(run)
+		c.data.scheduleRuns[run.ScheduleID] = runs
+		return true, nil
+	}
+
+	run.ID = c.data.newID()
+	r := cloneRun(run)
+	c.data.scheduleRuns[run.ScheduleID] = append(runs[:len(runs):len(runs)], r)
+	return true, nil
+}
+
+func (c *Catalog) UpdateScheduleRun(ctx context.Context, run *models.ScheduleRun) error {
+	defer c.lock()()
+
+	runs := slices.Clone(c.data.scheduleRuns[run.ScheduleID])
+	for i, stored := range runs {
+		if stored.ID != run.ID {
+			continue
+		}
+
+		updated := cloneRun(stored)
+		updated.Status = run.Status
+		updated.MessageID = clonePtr(run.MessageID)
+		updated.Error = run.Error
+		updated.FinishedAt = clonePtr(run.FinishedAt)
+		runs[i] = updated
+		c.data.scheduleRuns[run.ScheduleID] = runs
+		return nil
+	}
+	return nil
+}
+
+func (c *Catalog) FailStaleScheduleRuns(ctx context.Context, staleBefore, at time.Time, reason string) (int, error) {
+	defer c.lock()()
+
+	failed := 0
+	for scheduleID, runs := range c.data.scheduleRuns {
+		var updated []*models.ScheduleRun
+		for i, stored := range runs {
+			if stored.Status != models.ScheduleRunRunning || !stored.StartedAt.Before(staleBefore) {
+				continue
+			}
+			if updated == nil {
+				updated = slices.Clone(runs)
+			}
+
+			run := cloneRun(stored)
+			run.Status = models.ScheduleRunFailed
+			run.Error = reason
+			run.FinishedAt = &at
+			updated[i] = run
+			failed++
+		}
+		if updated != nil {
+			c.data.scheduleRuns[scheduleID] = updated
+		}
+	}
+	return failed, nil
+}
+
+func (c *Catalog) ListScheduleRuns(ctx context.Context, scheduleID int64, limit int) ([]*models.ScheduleRun, error) {
+	defer c.lock()()
+
+	runs := make([]*models.ScheduleRun, 0, len(c.data.scheduleRuns[scheduleID]))
+	for _, run := range c.data.scheduleRuns[scheduleID] {
+		runs = append(runs, cloneRun(run))
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].ScheduledFor.After(runs[j].ScheduledFor) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// stopped returns a copy of worker marked as stopped at the given time
+func stopped(worker *models.Worker, at time.Time) *models.Worker {
+	w := cloneWorker(worker)
+	w.Status = models.WorkerStatusStopped
+	w.CurrentMessageIDs = nil
+	w.UpdatedAt = at
+	return w
+}
+
+func cloneQueue(queue *models.Queue) *models.Queue {
+	q := *queue
+	q.DeadLetterQueueID = clonePtr(queue.DeadLetterQueueID)
+	q.DeletedAt = clonePtr(queue.DeletedAt)
+	return &q
+}
+
+func cloneWorker(worker *models.Worker) *models.Worker {
+	w := *worker
+	w.Queue = nil
+	w.CurrentMessageIDs = slices.Clone(worker.CurrentMessageIDs)
+	return &w
+}
+
+func cloneSchedule(schedule *models.Schedule) *models.Schedule {
+	s := *schedule
+	s.Queue = nil
+	s.Headers = maps.Clone(schedule.Headers)
+	s.NextRunAt = clonePtr(schedule.NextRunAt)
+	s.LastRunAt = clonePtr(schedule.LastRunAt)
+	return &s
+}
+
+func cloneRun(run *models.ScheduleRun) *models.ScheduleRun {
+	r := *run
+	r.MessageID = clonePtr(run.MessageID)
+	r.FinishedAt = clonePtr(run.FinishedAt)
+	return &r
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/shravan20/qafka/internal/storage"
+	"github.com/shravan20/qafka/internal/storage/storagetest"
+)
+
+func TestCatalogConformance(t *testing.T) {
+	storagetest.RunCatalog(t, func(t *testing.T) storage.Catalog {
+		return NewCatalog()
+	})
+}
@@ -0,0 +1,717 @@
+// Package postgres implements storage.Backend and storage.Catalog on PostgreSQL
+// using Bun.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/selector"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// expireBatchSize bounds the number of expired leases handled per transaction
+const expireBatchSize = 1000
+
+// finishedStatuses are the statuses of messages that will not be delivered again
+var finishedStatuses = []string{
+	models.MessageStatusCompleted,
+	models.MessageStatusFailed,
+	models.MessageStatusDeadLettered,
+}
+
+type Backend struct {
+	db *bun.DB
+}
+
+func New(db *bun.DB) *Backend {
+	return &Backend{db: db}
+}
+
+var _ storage.Backend = (*Backend)(nil)
+
+func (b *Backend) Enqueue(ctx context.Context, message *models.Message, dedupWindow time.Duration) error {
+	if message.DedupID != "" && dedupWindow > 0 {
+		return b.EnqueueBatch(ctx, []*models.Message{message}, dedupWindow)
+	}
+
+	_, err := b.db.NewInsert().Model(message).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+	return nil
+}
+
+// EnqueueBatch reserves the batch's deduplication keys in message_dedup before
+// inserting. A concurrent producer using the same key blocks on the reserved row
+// until this transaction ends, then finds the key taken.
+func (b *Backend) EnqueueBatch(ctx context.Context, messages []*models.Message, dedupWindow time.Duration) error {
+	err := b.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+
+		type key struct {
+			queueID int64
+			dedupID string
+		}
+		reserved := make(map[key]*models.Message)
+		repeats := make(map[*models.Message]*models.Message)
+		fresh := make([]*models.Message, 0, len(messages))
+
+		for _, message := range messages {
+			if message.DedupID == "" || dedupWindow <= 0 {
+				fresh = append(fresh, message)
+				continue
+			}
+
+			k := key{message.QueueID, message.DedupID}
+			if first, ok := reserved[k]; ok {
+				repeats[message] = first
+				continue
+			}
+
+			original, err := reserveDedupKey(ctx, tx, message, now.Add(dedupWindow))
+			if err != nil {
+				return err
+			}
+			if original != nil {
+				*message = *original
+				continue
+			}
+
+			reserved[k] = message
+			fresh = append(fresh, message)
+		}
+
+		if len(fresh) > 0 {
+			if _, err := tx.NewInsert().Model(&fresh).Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		for k, message := range reserved {
+			_, err := tx.NewUpdate().Model((*models.MessageDedup)(nil)).
+				Set("message_id = ?", message.ID).
+				Where("queue_id = ? AND dedup_id = ?", k.queueID, k.dedupID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		for message, first := range repeats {
+			*message = *first
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create messages: %w", err)
+	}
+	return nil
+}
+
+// reserveDedupKey claims the deduplication key of message until expiresAt. It returns
+// the message stored under the key when the key is still held.
+func reserveDedupKey(ctx context.Context, tx bun.Tx, message *models.Message, expiresAt time.Time) (*models.Message, error) {
+	// A held key is taken over once its window has passed or its message was deleted
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO message_dedup (queue_id, dedup_id, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (queue_id, dedup_id) DO UPDATE
+		SET message_id = NULL, expires_at = EXCLUDED.expires_at
+		WHERE message_dedup.expires_at <= ?
+			OR NOT EXISTS (SELECT 1 FROM messages WHERE messages.id = message_dedup.message_id)`,
+		message.QueueID, message.DedupID, expiresAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, nil
+	}
+
+	original := &models.Message{}
+	err = tx.NewSelect().Model(original).
+		Where("id = (SELECT message_id FROM message_dedup WHERE queue_id = ? AND dedup_id = ?)", message.QueueID, message.DedupID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return original, nil
+}
+
+func (b *Backend) Get(ctx context.Context, id int64) (*models.Message, error) {
+	message := &models.Message{}
+	err := b.db.NewSelect().Model(message).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	return message, nil
+}
+
+func (b *Backend) List(ctx context.Context, opts storage.ListOptions) ([]*models.Message, error) {
+	var messages []*models.Message
+	query := b.db.NewSelect().Model(&messages).Relation("Queue")
+
+	if opts.QueueID > 0 {
+		query = query.Where("queue_id = ?", opts.QueueID)
+	}
+
+	if opts.Status != "" {
+		query = query.Where("message.status = ?", opts.Status)
+	}
+
+	query = whereSelector(query, opts.Selector)
+
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+
+	err := query.Order("message.created_at DESC").Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (b *Backend) Claim(ctx context.Context, queueID int64, opts storage.ClaimOptions) (*models.Message, error) {
+	messages, err := b.ClaimBatch(ctx, queueID, 1, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, storage.ErrNoMessageAvailable
+	}
+	return messages[0], nil
+}
+
+// ClaimBatch selects the next messages with FOR UPDATE SKIP LOCKED, so concurrent
+// claims skip rows another transaction is about to take. A grouped message is only
+// eligible while no message of its group is in flight or pending ahead of it; a
+// concurrent claim that skips the locked head of a group still sees it pending, so
+// it cannot take the message behind it.
+func (b *Backend) ClaimBatch(ctx context.Context, queueID int64, limit int, opts storage.ClaimOptions) ([]*models.Message, error) {
+	var claimed []*models.Message
+
+	err := b.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+
+		var ids []int64
+		query := tx.NewSelect().Model((*models.Message)(nil)).
+			Column("id").
+			Where("queue_id = ? AND status = ?", queueID, models.MessageStatusPending).
+			Where("(scheduled_at IS NULL OR scheduled_at <= ?)", now).
+			Where(`(group_key IS NULL OR NOT EXISTS (
+				SELECT 1 FROM messages AS g
+				WHERE g.queue_id = message.queue_id AND g.group_key = message.group_key
+					AND (g.status = ? OR (g.status IN (?, ?) AND g.id < message.id))))`,
+				models.MessageStatusProcessing, models.MessageStatusPending, models.MessageStatusScheduled)
+
+		err := whereSelector(query, opts.Selector).
+			Order(claimOrder(opts.Order)...).
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx, &ids)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		err = tx.NewUpdate().Model((*models.Message)(nil)).
+			Set("status = ?", models.MessageStatusProcessing).
+			Set("worker_id = ?", opts.WorkerID).
+			Set("claimed_at = ?", now).
+			Set("lease_token = ?", opts.LeaseToken).
+			Set("lease_expires_at = ?", now.Add(opts.Lease)).
+			Set("updated_at = ?", now).
+			Where("id IN (?)", bun.In(ids)).
+			Returning("*").
+			Scan(ctx, &claimed)
+		if err != nil {
+			return err
+		}
+
+		// RETURNING does not preserve the selection order
+		position := make(map[int64]int, len(ids))
+		for i, id := range ids {
+			position[id] = i
+		}
+		sort.Slice(claimed, func(i, j int) bool {
+			return position[claimed[i].ID] < position[claimed[j].ID]
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim messages: %w", err)
+	}
+
+	if claimed == nil {
+		claimed = []*models.Message{}
+	}
+	return claimed, nil
+}
+
+func (b *Backend) NextDue(ctx context.Context, queueID int64) (*time.Time, error) {
+	var next sql.NullTime
+	query := b.db.NewSelect().Model((*models.Message)(nil)).
+		ColumnExpr("min(scheduled_at)").
+		Where("status = ?", models.MessageStatusScheduled).
+		Where("scheduled_at > ?", time.Now())
+	if queueID > 0 {
+		query = query.Where("queue_id = ?", queueID)
+	}
+
+	err := query.Scan(ctx, &next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next due message: %w", err)
+	}
+
+	if !next.Valid {
+		return nil, nil
+	}
+	return &next.Time, nil
+}
+
+// PromoteDue locks the due messages with SKIP LOCKED, so replicas promoting at the
+// same time split the work instead of promoting a message twice
+func (b *Backend) PromoteDue(ctx context.Context, limit int) ([]*models.Message, error) {
+	now := time.Now()
+
+	due := b.db.NewSelect().Model((*models.Message)(nil)).
+		Column("id").
+		Where("status = ? AND scheduled_at <= ?", models.MessageStatusScheduled, now).
+		OrderExpr("scheduled_at").
+		Limit(limit).
+		For("UPDATE SKIP LOCKED")
+
+	var promoted []*models.Message
+	err := b.db.NewUpdate().Model((*models.Message)(nil)).
+		Set("status = ?", models.MessageStatusPending).
+		Set("updated_at = ?", now).
+		Where("id IN (?)", due).
+		Returning("*").
+		Scan(ctx, &promoted)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to promote due messages: %w", err)
+	}
+
+	return promoted, nil
+}
+
+func (b *Backend) ExtendLease(ctx context.Context, id int64, leaseToken string, expiresAt time.Time) (*models.Message, error) {
+	return b.updateLeased(ctx, id, leaseToken, func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		return q.Set("lease_expires_at = ?", expiresAt)
+	})
+}
+
+func (b *Backend) Ack(ctx context.Context, id int64, leaseToken string) (*models.Message, error) {
+	return b.updateLeased(ctx, id, leaseToken, func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		return endLease(q).
+			Set("status = ?", models.MessageStatusCompleted).
+			Set("processed_at = ?", time.Now())
+	})
+}
+
+func (b *Backend) Nack(ctx context.Context, id int64, leaseToken string, failure storage.Failure) (*models.Message, error) {
+	return b.updateLeased(ctx, id, leaseToken, func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		return applyFailure(endLease(q), failure, time.Now())
+	})
+}
+
+func (b *Backend) Release(ctx context.Context, id int64, leaseToken string) (*models.Message, error) {
+	return b.updateLeased(ctx, id, leaseToken, func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		return endLease(q).
+			Set("status = ?", models.MessageStatusPending).
+			Set("worker_id = NULL").
+			Set("claimed_at = NULL")
+	})
+}
+
+func (b *Backend) ExpireLeases(ctx context.Context, decide func(*models.Message) storage.Failure) (int64, error) {
+	now := time.Now()
+	affected, err := b.failInFlight(ctx, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("lease_expires_at <= ?", now).
+			OrderExpr("lease_expires_at").
+			Limit(expireBatchSize)
+	}, decide)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire leases: %w", err)
+	}
+
+	return affected, nil
+}
+
+func (b *Backend) ReleaseWorker(ctx context.Context, workerID int64) ([]*models.Message, error) {
+	var released []*models.Message
+	err := endLease(b.db.NewUpdate().Model((*models.Message)(nil))).
+		Set("status = ?", models.MessageStatusPending).
+		Set("worker_id = NULL").
+		Set("claimed_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("worker_id = ? AND status = ?", workerID, models.MessageStatusProcessing).
+		Returning("*").
+		Scan(ctx, &released)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to release worker messages: %w", err)
+	}
+
+	return released, nil
+}
+
+func (b *Backend) FailWorker(ctx context.Context, workerID int64, decide func(*models.Message) storage.Failure) (int64, error) {
+	affected, err := b.failInFlight(ctx, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("worker_id = ?", workerID)
+	}, decide)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail worker messages: %w", err)
+	}
+
+	return affected, nil
+}
+
+// failInFlight records a failure, as decided by decide, for the in-flight messages
+// selected by filter, skipping those locked by a concurrent ack or nack
+func (b *Backend) failInFlight(ctx context.Context, filter func(*bun.SelectQuery) *bun.SelectQuery, decide func(*models.Message) storage.Failure) (int64, error) {
+	var affected int64
+
+	err := b.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var inFlight []*models.Message
+		err := filter(tx.NewSelect().Model(&inFlight).Where("status = ?", models.MessageStatusProcessing)).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range inFlight {
+			// Recorded after the decision, so a retry decided for right away is due
+			failure := decide(message)
+			failedAt := time.Now()
+			q := tx.NewUpdate().Model((*models.Message)(nil)).
+				Set("updated_at = ?", failedAt).
+				Where("id = ?", message.ID)
+			if _, err := applyFailure(endLease(q), failure, failedAt).Exec(ctx); err != nil {
+				return err
+			}
+			affected++
+		}
+
+		return nil
+	})
+
+	return affected, err
+}
+
+func (b *Backend) Redrive(ctx context.Context, opts storage.RedriveOptions) (int64, error) {
+	query := b.db.NewUpdate().Model((*models.Message)(nil)).
+		Set("queue_id = original_queue_id").
+		Set("original_queue_id = NULL").
+		Set("status = ?", models.MessageStatusPending).
+		Set("retry_count = 0").
+		Set("scheduled_at = NULL").
+		Set("dead_lettered_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("queue_id = ? AND status = ?", opts.DeadLetterQueueID, models.MessageStatusDeadLettered).
+		Where("original_queue_id IS NOT NULL")
+
+	if opts.SourceQueueID > 0 {
+		query = query.Where("original_queue_id = ?", opts.SourceQueueID)
+	}
+	if len(opts.MessageIDs) > 0 {
+		query = query.Where("id IN (?)", bun.In(opts.MessageIDs))
+	}
+	if opts.ErrorContains != "" {
+		query = query.Where("strpos(lower(error_message), lower(?)) > 0", opts.ErrorContains)
+	}
+	if opts.DeadLetteredBefore != nil {
+		query = query.Where("dead_lettered_at <= ?", *opts.DeadLetteredBefore)
+	}
+	if opts.DeadLetteredAfter != nil {
+		query = query.Where("dead_lettered_at >= ?", *opts.DeadLetteredAfter)
+	}
+
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to redrive messages: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (b *Backend) Depth(ctx context.Context, queueID int64) (map[string]int64, error) {
+	var rows []struct {
+		Status string `bun:"status"`
+		Count  int64  `bun:"count"`
+	}
+
+	err := b.db.NewSelect().Model((*models.Message)(nil)).
+		Column("status").
+		ColumnExpr("count(*) AS count").
+		Where("queue_id = ?", queueID).
+		Group("status").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue depth: %w", err)
+	}
+
+	depth := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		depth[row.Status] = row.Count
+	}
+	return depth, nil
+}
+
+func (b *Backend) Purge(ctx context.Context, queueID int64) (int64, error) {
+	res, err := b.db.NewDelete().Model((*models.Message)(nil)).Where("queue_id = ?", queueID).Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge queue: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Bulk locks the chunk's rows with SKIP LOCKED before changing them, so neither
+// claims nor other bulk operations wait on it for longer than one chunk
+func (b *Backend) Bulk(ctx context.Context, opts storage.BulkOptions) (int64, error) {
+	var affected int64
+
+	err := b.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		q := bulkSelect(tx, opts)
+		if opts.Limit > 0 {
+			q = q.Limit(opts.Limit)
+		}
+
+		var ids []int64
+		if err := q.OrderExpr("message.id").For("UPDATE SKIP LOCKED").Scan(ctx, &ids); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+
+		var res sql.Result
+		var err error
+		switch opts.Action {
+		case storage.BulkDelete:
+			res, err = tx.NewDelete().Model((*models.Message)(nil)).
+				Where("id IN (?)", bun.In(ids)).
+				Exec(ctx)
+		case storage.BulkRequeue:
+			res, err = tx.NewUpdate().Model((*models.Message)(nil)).
+				Set("status = ?", models.MessageStatusPending).
+				Set("retry_count = 0").
+				Set("scheduled_at = NULL").
+				Set("dead_lettered_at = NULL").
+				Set("worker_id = NULL").
+				Set("updated_at = ?", now).
+				Where("id IN (?)", bun.In(ids)).
+				Exec(ctx)
+		case storage.BulkReprioritize:
+			res, err = tx.NewUpdate().Model((*models.Message)(nil)).
+				Set("priority = ?", opts.Priority).
+				Set("updated_at = ?", now).
+				Where("id IN (?)", bun.In(ids)).
+				Exec(ctx)
+		case storage.BulkArchive:
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO archived_messages (id, queue_id, queue_name, message, archived_at)
+				SELECT m.id, m.queue_id, q.name, to_jsonb(m), ?
+				FROM messages AS m JOIN queues AS q ON q.id = m.queue_id
+				WHERE m.id IN (?)
+				ON CONFLICT (id) DO NOTHING`, now, bun.In(ids))
+			if err != nil {
+				return err
+			}
+			res, err = tx.NewDelete().Model((*models.Message)(nil)).
+				Where("id IN (?)", bun.In(ids)).
+				Exec(ctx)
+		default:
+			return fmt.Errorf("unknown bulk action %d", opts.Action)
+		}
+		if err != nil {
+			return err
+		}
+
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply bulk operation: %w", err)
+	}
+
+	return affected, nil
+}
+
+func (b *Backend) BulkRemaining(ctx context.Context, opts storage.BulkOptions) (int64, error) {
+	n, err := bulkSelect(b.db, opts).Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count bulk operation messages: %w", err)
+	}
+	return int64(n), nil
+}
+
+// bulkSelect selects the IDs of every message Bulk applies opts to
+func bulkSelect(db bun.IDB, opts storage.BulkOptions) *bun.SelectQuery {
+	q := whereFilter(db.NewSelect().Model((*models.Message)(nil)).Column("message.id"), opts.Filter)
+	switch opts.Action {
+	case storage.BulkRequeue:
+		q = q.Where("message.status NOT IN (?)", bun.In([]string{models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusProcessing}))
+	case storage.BulkReprioritize:
+		q = q.Where("message.priority <> ?", opts.Priority)
+	}
+	return q
+}
+
+// whereFilter restricts a query to the messages selected by f
+func whereFilter(q *bun.SelectQuery, f storage.MessageFilter) *bun.SelectQuery {
+	q = q.Where("message.queue_id = ?", f.QueueID)
+	if len(f.Statuses) > 0 {
+		q = q.Where("message.status IN (?)", bun.In(f.Statuses))
+	}
+	if len(f.MessageIDs) > 0 {
+		q = q.Where("message.id IN (?)", bun.In(f.MessageIDs))
+	}
+	if f.CreatedBefore != nil {
+		q = q.Where("message.created_at < ?", *f.CreatedBefore)
+	}
+	if f.CreatedAfter != nil {
+		q = q.Where("message.created_at >= ?", *f.CreatedAfter)
+	}
+	return whereSelector(q, f.Selector)
+}
+
+// updateLeased applies an update to a message while the caller's lease token is
+// current and unexpired, returning the updated message
+func (b *Backend) updateLeased(ctx context.Context, id int64, leaseToken string, apply func(*bun.UpdateQuery) *bun.UpdateQuery) (*models.Message, error) {
+	now := time.Now()
+	message := &models.Message{}
+
+	q := b.db.NewUpdate().Model(message).
+		Set("updated_at = ?", now).
+		Where("id = ? AND status = ?", id, models.MessageStatusProcessing).
+		Where("lease_token = ? AND lease_expires_at > ?", leaseToken, now).
+		Returning("*")
+
+	res, err := apply(q).Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		exists, err := b.db.NewSelect().Model((*models.Message)(nil)).Where("id = ?", id).Exists(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message: %w", err)
+		}
+		if !exists {
+			return nil, storage.ErrMessageNotFound
+		}
+		return nil, storage.ErrLeaseNotHeld
+	}
+
+	return message, nil
+}
+
+// endLease clears the lease columns of a claimed message
+func endLease(q *bun.UpdateQuery) *bun.UpdateQuery {
+	return q.
+		Set("lease_token = NULL").
+		Set("lease_expires_at = NULL")
+}
+
+// applyFailure records a failed delivery and moves the message to the state the failure calls for
+func applyFailure(q *bun.UpdateQuery, failure storage.Failure, now time.Time) *bun.UpdateQuery {
+	q = q.
+		Set("failed_at = ?", now).
+		Set("error_message = ?", failure.Error).
+		// A JSON null, like SQL NULL, is no history yet: || would keep it as an element
+		Set("error_history = (CASE WHEN jsonb_typeof(error_history) = 'array' THEN error_history ELSE '[]'::jsonb END) || "+
+			"jsonb_build_array(jsonb_build_object('attempt', retry_count + 1, 'error', ?, 'failed_at', ?::timestamptz))",
+			failure.Error, now).
+		Set("worker_id = NULL")
+
+	switch {
+	case failure.RetryAt != nil:
+		status := models.MessageStatusPending
+		if failure.RetryAt.After(now) {
+			status = models.MessageStatusScheduled
+		}
+		return q.
+			Set("status = ?", status).
+			Set("scheduled_at = ?", *failure.RetryAt).
+			Set("retry_count = retry_count + 1")
+	case failure.DeadLetterQueueID != nil:
+		return q.
+			Set("status = ?", models.MessageStatusDeadLettered).
+			Set("original_queue_id = queue_id").
+			Set("queue_id = ?", *failure.DeadLetterQueueID).
+			Set("dead_lettered_at = ?", now)
+	default:
+		return q.Set("status = ?", models.MessageStatusFailed)
+	}
+}
+
+func (b *Backend) DeleteFinished(ctx context.Context, queueID int64, before time.Time) (int64, error) {
+	res, err := b.db.NewDelete().Model((*models.Message)(nil)).
+		Where("queue_id = ?", queueID).
+		Where("status IN (?)", bun.In(finishedStatuses)).
+		Where("updated_at < ?", before).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished messages: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (b *Backend) ExpireDedupKeys(ctx context.Context) (int64, error) {
+	res, err := b.db.NewDelete().Model((*models.MessageDedup)(nil)).
+		Where("expires_at <= ?", time.Now()).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire dedup keys: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// claimOrder returns the ORDER BY clauses implementing order
+func claimOrder(order storage.Order) []string {
+	switch order {
+	case storage.OrderFIFO:
+		return []string{"created_at ASC", "id ASC"}
+	case storage.OrderLIFO:
+		return []string{"created_at DESC", "id DESC"}
+	default:
+		return []string{"priority DESC", "created_at ASC", "id ASC"}
+	}
+}
+
+// whereSelector restricts a query to messages whose headers match sel. Equality
+// terms use jsonb containment so they are served by the GIN index on headers.
+func whereSelector(q *bun.SelectQuery, sel *selector.Selector) *bun.SelectQuery {
+	if sel == nil {
+		return q
+	}
+
+	for _, term := range sel.Terms {
+		if term.Negate {
+			q = q.Where("(message.headers ->> ?) IS DISTINCT FROM ?", term.Key, term.Value)
+		} else {
+			q = q.Where("message.headers @> jsonb_build_object(?::text, ?::text)", term.Key, term.Value)
+		}
+	}
+	return q
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/shravan20/qafka/internal/database"
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+	"github.com/shravan20/qafka/internal/storage/storagetest"
+)
+
+// testDB connects to the database at DATABASE_URL and migrates it, or skips the
+// test when it is not set
+func testDB(t *testing.T) *bun.DB {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	db, err := database.Connect(databaseURL)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	return db
+}
+
+// newTestQueue creates a queue that holds no messages yet and removes it with its
+// messages when the test ends
+func newTestQueue(t *testing.T, db *bun.DB) int64 {
+	ctx := context.Background()
+	queue := &models.Queue{
+		Name:     fmt.Sprintf("conformance-%d", time.Now().UnixNano()),
+		Type:     "fifo",
+		Config:   "{}",
+		IsActive: true,
+	}
+	if _, err := db.NewInsert().Model(queue).Exec(ctx); err != nil {
+		t.Fatalf("create queue: %v", err)
+	}
+
+	t.Cleanup(func() {
+		// Messages, dedup keys and workers go with the queue
+		db.NewDelete().Model((*models.ArchivedMessage)(nil)).Where("queue_id = ?", queue.ID).Exec(ctx)
+		db.NewDelete().Model((*models.Queue)(nil)).Where("id = ?", queue.ID).ForceDelete().Exec(ctx)
+	})
+
+	return queue.ID
+}
+
+func TestConformance(t *testing.T) {
+	db := testDB(t)
+
+	newQueue := func(t *testing.T) int64 {
+		return newTestQueue(t, db)
+	}
+
+	storagetest.Run(t, func(t *testing.T) *storagetest.Harness {
+		return &storagetest.Harness{
+			Backend:  New(db),
+			NewQueue: newQueue,
+			NewWorker: func(t *testing.T) int64 {
+				// Messages reference their worker, so claims need a registered one
+				worker := &models.Worker{Name: "conformance", QueueID: newQueue(t), Status: models.WorkerStatusIdle}
+				if _, err := db.NewInsert().Model(worker).Exec(context.Background()); err != nil {
+					t.Fatalf("create worker: %v", err)
+				}
+				return worker.ID
+			},
+		}
+	})
+}
+
+func TestBulkSkipsLockedMessages(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	backend := New(db)
+	queueID := newTestQueue(t, db)
+
+	var ids []int64
+	for i := 0; i < 2; i++ {
+		now := time.Now()
+		message := &models.Message{QueueID: queueID, Payload: "bulk", Status: models.MessageStatusPending, MaxRetries: 3, CreatedAt: now, UpdatedAt: now}
+		if err := backend.Enqueue(ctx, message, 0); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	// Lock the first message as a concurrent claim would
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.NewSelect().Model((*models.Message)(nil)).Where("id = ?", ids[0]).For("UPDATE").Exec(ctx); err != nil {
+		t.Fatalf("lock message: %v", err)
+	}
+
+	opts := storage.BulkOptions{
+		Filter: storage.MessageFilter{QueueID: queueID},
+		Action: storage.BulkDelete,
+		Limit:  10,
+	}
+	for _, want := range []int64{1, 0} {
+		if n, err := backend.Bulk(ctx, opts); err != nil || n != want {
+			t.Fatalf("Bulk with a locked message: deleted %d, %v, want %d", n, err, want)
+		}
+	}
+	if n, err := backend.BulkRemaining(ctx, opts); err != nil || n != 1 {
+		t.Fatalf("BulkRemaining with a locked message: %d, %v, want 1", n, err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if n, err := backend.Bulk(ctx, opts); err != nil || n != 1 {
+		t.Fatalf("Bulk after the lock is released: deleted %d, %v, want 1", n, err)
+	}
+	if n, err := backend.BulkRemaining(ctx, opts); err != nil || n != 0 {
+		t.Fatalf("BulkRemaining after the lock is released: %d, %v, want 0", n, err)
+	}
+}
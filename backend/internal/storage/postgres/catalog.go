@@ -0,0 +1,410 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// Catalog implements storage.Catalog on the queues, workers and schedules tables
+type Catalog struct {
+	db bun.IDB // *bun.DB, or the bun.Tx of a Catalog handed out by RunInTx
+}
+
+func NewCatalog(db *bun.DB) *Catalog {
+	return &Catalog{db: db}
+}
+
+var _ storage.Catalog = (*Catalog)(nil)
+
+func (c *Catalog) RunInTx(ctx context.Context, fn func(ctx context.Context, tx storage.Catalog) error) error {
+	if _, ok := c.db.(bun.Tx); ok {
+		return fn(ctx, c)
+	}
+	return c.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(ctx, &Catalog{db: tx})
+	})
+}
+
+// Queues
+
+func (c *Catalog) GetQueue(ctx context.Context, id int64) (*models.Queue, error) {
+	queue := &models.Queue{}
+	err := c.db.NewSelect().Model(queue).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrQueueNotFound, "failed to get queue")
+	}
+	return queue, nil
+}
+
+func (c *Catalog) GetQueueForUpdate(ctx context.Context, id int64) (*models.Queue, error) {
+	queue := &models.Queue{}
+	err := c.db.NewSelect().Model(queue).WhereAllWithDeleted().Where("id = ?", id).For("UPDATE").Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrQueueNotFound, "failed to get queue")
+	}
+	return queue, nil
+}
+
+func (c *Catalog) GetQueueByName(ctx context.Context, name string) (*models.Queue, error) {
+	queue := &models.Queue{}
+	err := c.db.NewSelect().Model(queue).WhereAllWithDeleted().Where("name = ?", name).Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrQueueNotFound, "failed to get queue")
+	}
+	return queue, nil
+}
+
+func (c *Catalog) ListQueues(ctx context.Context, opts storage.QueueListOptions) ([]*models.Queue, error) {
+	queues := []*models.Queue{}
+	query := c.db.NewSelect().Model(&queues)
+
+	switch opts.Deleted {
+	case storage.OnlyDeleted:
+		query = query.WhereDeleted().Order("deleted_at DESC")
+	case storage.IncludeDeleted:
+		query = query.WhereAllWithDeleted()
+	}
+	if opts.DeletedBefore != nil {
+		query = query.Where("deleted_at < ?", *opts.DeletedBefore)
+	}
+	if opts.DeadLetterQueueID != 0 {
+		query = query.Where("dead_letter_queue_id = ?", opts.DeadLetterQueueID)
+	}
+
+	if err := query.Order("created_at DESC", "id DESC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get queues: %w", err)
+	}
+	return queues, nil
+}
+
+func (c *Catalog) InsertQueue(ctx context.Context, queue *models.Queue) error {
+	_, err := c.db.NewInsert().Model(queue).Exec(ctx)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: queue %q", storage.ErrNameTaken, queue.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create queue: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) UpdateQueue(ctx context.Context, queue *models.Queue) error {
+	res, err := c.db.NewUpdate().Model(queue).
+		WhereAllWithDeleted().
+		Column("description", "config", "is_active", "state", "dead_letter_queue_id", "managed_by",
+			"version", "delete_mode", "deleted_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update queue: %w", err)
+	}
+	return affected(res, storage.ErrQueueNotFound)
+}
+
+func (c *Catalog) DeleteQueue(ctx context.Context, id int64) error {
+	// Workers, schedules and dedup keys go with the queue
+	res, err := c.db.NewDelete().Model((*models.Queue)(nil)).
+		WhereAllWithDeleted().
+		Where("id = ?", id).
+		ForceDelete().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete queue: %w", err)
+	}
+	return affected(res, storage.ErrQueueNotFound)
+}
+
+// Workers
+
+func (c *Catalog) InsertWorker(ctx context.Context, worker *models.Worker) error {
+	if _, err := c.db.NewInsert().Model(worker).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) GetWorker(ctx context.Context, id int64) (*models.Worker, error) {
+	worker := &models.Worker{}
+	err := c.db.NewSelect().Model(worker).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrWorkerNotFound, "failed to get worker")
+	}
+	return worker, nil
+}
+
+func (c *Catalog) ListWorkers(ctx context.Context, queueID int64) ([]*models.Worker, error) {
+	workers := []*models.Worker{}
+	query := c.db.NewSelect().Model(&workers).Relation("Queue")
+	if queueID > 0 {
+		query = query.Where("worker.queue_id = ?", queueID)
+	}
+
+	if err := query.Order("worker.created_at DESC", "worker.id DESC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get workers: %w", err)
+	}
+	return workers, nil
+}
+
+func (c *Catalog) PingWorker(ctx context.Context, id int64, status string, messageIDs []int64, at time.Time) (*models.Worker, error) {
+	worker := &models.Worker{
+		Status:            status,
+		LastPing:          at,
+		CurrentMessageIDs: messageIDs,
+		UpdatedAt:         at,
+	}
+	err := c.db.NewUpdate().Model(worker).
+		Column("last_ping", "status", "current_message_ids", "updated_at").
+		Where("id = ? AND status != ?", id, models.WorkerStatusStopped).
+		Returning("*").
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := c.GetWorker(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, storage.ErrWorkerStopped
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update worker ping: %w", err)
+	}
+	return worker, nil
+}
+
+func (c *Catalog) StopWorker(ctx context.Context, id int64, at time.Time) (*models.Worker, error) {
+	worker := &models.Worker{}
+	err := c.db.NewUpdate().Model(worker).
+		Set("status = ?", models.WorkerStatusStopped).
+		Set("current_message_ids = NULL").
+		Set("updated_at = ?", at).
+		Where("id = ?", id).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrWorkerNotFound, "failed to stop worker")
+	}
+	return worker, nil
+}
+
+// StopLapsedWorkers stops the workers in a single UPDATE, so a worker is returned
+// to the one caller whose statement changed it
+func (c *Catalog) StopLapsedWorkers(ctx context.Context, lastPingBefore time.Time, at time.Time) ([]*models.Worker, error) {
+	lapsed := []*models.Worker{}
+	err := c.db.NewUpdate().Model((*models.Worker)(nil)).
+		Set("status = ?", models.WorkerStatusStopped).
+		Set("current_message_ids = NULL").
+		Set("updated_at = ?", at).
+		Where("status != ? AND last_ping < ?", models.WorkerStatusStopped, lastPingBefore).
+		Returning("*").
+		Scan(ctx, &lapsed)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to stop lapsed workers: %w", err)
+	}
+	return lapsed, nil
+}
+
+func (c *Catalog) CountWorker(ctx context.Context, id int64, processed, failed int64) error {
+	_, err := c.db.NewUpdate().Model((*models.Worker)(nil)).
+		Set("processed_count = processed_count + ?", processed).
+		Set("failed_count = failed_count + ?", failed).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count worker messages: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) InsertWorkerEvent(ctx context.Context, event *models.WorkerEvent) error {
+	if _, err := c.db.NewInsert().Model(event).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record worker event: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) ListWorkerEvents(ctx context.Context, workerID int64, limit int) ([]*models.WorkerEvent, error) {
+	events := []*models.WorkerEvent{}
+	query := c.db.NewSelect().Model(&events).Where("worker_id = ?", workerID).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get worker events: %w", err)
+	}
+	return events, nil
+}
+
+// Schedules
+
+func (c *Catalog) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	schedules := []*models.Schedule{}
+	if err := c.db.NewSelect().Model(&schedules).Order("name").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (c *Catalog) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	schedule := &models.Schedule{}
+	err := c.db.NewSelect().Model(schedule).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrScheduleNotFound, "failed to get schedule")
+	}
+	return schedule, nil
+}
+
+func (c *Catalog) GetScheduleForUpdate(ctx context.Context, id int64) (*models.Schedule, error) {
+	schedule := &models.Schedule{}
+	err := c.db.NewSelect().Model(schedule).Where("id = ?", id).For("UPDATE").Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrScheduleNotFound, "failed to get schedule")
+	}
+	return schedule, nil
+}
+
+func (c *Catalog) InsertSchedule(ctx context.Context, schedule *models.Schedule) error {
+	_, err := c.db.NewInsert().Model(schedule).Exec(ctx)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: schedule %q", storage.ErrNameTaken, schedule.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) UpdateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	res, err := c.db.NewUpdate().Model(schedule).
+		Column("description", "cron_expr", "timezone", "queue_id", "payload_template", "headers",
+			"priority", "catch_up", "enabled", "next_run_at", "last_run_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return affected(res, storage.ErrScheduleNotFound)
+}
+
+func (c *Catalog) DeleteSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	// Runs go with the schedule
+	schedule := &models.Schedule{}
+	err := c.db.NewDelete().Model(schedule).Where("id = ?", id).Returning("*").Scan(ctx)
+	if err != nil {
+		return nil, notFound(err, storage.ErrScheduleNotFound, "failed to delete schedule")
+	}
+	return schedule, nil
+}
+
+func (c *Catalog) DueSchedules(ctx context.Context, now time.Time) ([]*models.Schedule, error) {
+	due := []*models.Schedule{}
+	err := c.db.NewSelect().Model(&due).
+		Where("enabled AND next_run_at <= ?", now).
+		Order("next_run_at").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due schedules: %w", err)
+	}
+	return due, nil
+}
+
+func (c *Catalog) AdvanceSchedule(ctx context.Context, schedule *models.Schedule, from time.Time) error {
+	_, err := c.db.NewUpdate().Model(schedule).
+		Column("next_run_at", "last_run_at", "updated_at").
+		Where("id = ? AND next_run_at = ?", schedule.ID, from).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to advance schedule: %w", err)
+	}
+	return nil
+}
+
+// InsertScheduleRun takes a stale run over in the same statement, so of two
+// runners retrying it only the first gets the row back
+func (c *Catalog) InsertScheduleRun(ctx context.Context, run *models.ScheduleRun, staleBefore time.Time) (bool, error) {
+	res, err := c.db.NewInsert().Model(run).
+		On("CONFLICT (schedule_id, scheduled_for) DO UPDATE").
+		Set("started_at = EXCLUDED.started_at").
+		Where("?TableAlias.status = ? AND ?TableAlias.started_at < ?", models.ScheduleRunRunning, staleBefore).
+		Returning("id").
+		Exec(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record schedule run: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *Catalog) UpdateScheduleRun(ctx context.Context, run *models.ScheduleRun) error {
+	_, err := c.db.NewUpdate().Model(run).
+		Column("status", "message_id", "error", "finished_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record schedule run: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) FailStaleScheduleRuns(ctx context.Context, staleBefore, at time.Time, reason string) (int, error) {
+	res, err := c.db.NewUpdate().Model((*models.ScheduleRun)(nil)).
+		Set("status = ?", models.ScheduleRunFailed).
+		Set("error = ?", reason).
+		Set("finished_at = ?", at).
+		Where("status = ? AND started_at < ?", models.ScheduleRunRunning, staleBefore).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire schedule runs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire schedule runs: %w", err)
+	}
+	return int(n), nil
+}
+
+func (c *Catalog) ListScheduleRuns(ctx context.Context, scheduleID int64, limit int) ([]*models.ScheduleRun, error) {
+	runs := []*models.ScheduleRun{}
+	query := c.db.NewSelect().Model(&runs).Where("schedule_id = ?", scheduleID).Order("scheduled_for DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get schedule runs: %w", err)
+	}
+	return runs, nil
+}
+
+// notFound maps a missing row to errNotFound and wraps other errors with msg
+func notFound(err error, errNotFound error, msg string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotFound
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// affected returns errNotFound when a statement changed no row
+func affected(res sql.Result, errNotFound error) error {
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == "23505"
+}
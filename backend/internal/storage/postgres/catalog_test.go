@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/shravan20/qafka/internal/storage"
+	"github.com/shravan20/qafka/internal/storage/storagetest"
+)
+
+func TestCatalogConformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunCatalog(t, func(t *testing.T) storage.Catalog {
+		return NewCatalog(db)
+	})
+}
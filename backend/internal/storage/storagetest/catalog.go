@@ -0,0 +1,625 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// RunCatalog runs the conformance suite of storage.Catalog. newCatalog is called
+// once per subtest. The catalog may hold rows of other tests, so the suite only
+// looks at the rows it created.
+func RunCatalog(t *testing.T, newCatalog func(t *testing.T) storage.Catalog) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, c storage.Catalog)
+	}{
+		{"Queues", testQueues},
+		{"ListQueues", testListQueues},
+		{"DeleteQueue", testDeleteQueue},
+		{"RunInTx", testRunInTx},
+		{"Workers", testWorkers},
+		{"StopLapsedWorkers", testStopLapsedWorkers},
+		{"WorkerEvents", testWorkerEvents},
+		{"Schedules", testSchedules},
+		{"ScheduleRuns", testScheduleRuns},
+		{"StaleScheduleRuns", testStaleScheduleRuns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newCatalog(t))
+		})
+	}
+}
+
+func testQueues(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	queue := insertQueue(t, c, "queues")
+	if queue.ID == 0 || queue.Version != 1 {
+		t.Fatalf("InsertQueue returned %+v, want an ID at version 1", queue)
+	}
+
+	got, err := c.GetQueue(ctx, queue.ID)
+	if err != nil {
+		t.Fatalf("GetQueue: %v", err)
+	}
+	if got.Name != queue.Name || got.Type != queue.Type || got.State != models.QueueStateActive {
+		t.Fatalf("GetQueue returned %+v", got)
+	}
+	if _, err := c.GetQueue(ctx, queue.ID+1000000); !errors.Is(err, storage.ErrQueueNotFound) {
+		t.Fatalf("GetQueue of unknown queue: got %v, want ErrQueueNotFound", err)
+	}
+
+	taken := &models.Queue{Name: queue.Name, Type: "fifo", Config: "{}", IsActive: true, State: models.QueueStateActive}
+	if err := c.InsertQueue(ctx, taken); !errors.Is(err, storage.ErrNameTaken) {
+		t.Fatalf("InsertQueue with a taken name: got %v, want ErrNameTaken", err)
+	}
+
+	got.Description = "updated"
+	got.State = models.QueueStatePaused
+	got.IsActive = false
+	got.ManagedBy = "conformance"
+	got.Version++
+	if err := c.UpdateQueue(ctx, got); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+	got, err = c.GetQueue(ctx, queue.ID)
+	if err != nil {
+		t.Fatalf("GetQueue: %v", err)
+	}
+	if got.Description != "updated" || got.State != models.QueueStatePaused || got.IsActive || got.ManagedBy != "conformance" || got.Version != 2 {
+		t.Fatalf("GetQueue after UpdateQueue returned %+v", got)
+	}
+
+	// A deleted queue is hidden from GetQueue but keeps its name
+	deletedAt := time.Now().Truncate(time.Second)
+	got.DeletedAt = &deletedAt
+	got.DeleteMode = models.QueueDeleteCascade
+	if err := c.UpdateQueue(ctx, got); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+	if _, err := c.GetQueue(ctx, queue.ID); !errors.Is(err, storage.ErrQueueNotFound) {
+		t.Fatalf("GetQueue of deleted queue: got %v, want ErrQueueNotFound", err)
+	}
+	byName, err := c.GetQueueByName(ctx, queue.Name)
+	if err != nil {
+		t.Fatalf("GetQueueByName: %v", err)
+	}
+	if byName.ID != queue.ID || byName.DeletedAt == nil || byName.DeleteMode != models.QueueDeleteCascade {
+		t.Fatalf("GetQueueByName of deleted queue returned %+v", byName)
+	}
+
+	locked, err := c.GetQueueForUpdate(ctx, queue.ID)
+	if err != nil || locked.DeletedAt == nil {
+		t.Fatalf("GetQueueForUpdate of deleted queue: %+v, %v", locked, err)
+	}
+
+	// Restoring clears the deletion
+	locked.DeletedAt = nil
+	locked.DeleteMode = ""
+	if err := c.UpdateQueue(ctx, locked); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+	if _, err := c.GetQueue(ctx, queue.ID); err != nil {
+		t.Fatalf("GetQueue of restored queue: %v", err)
+	}
+}
+
+func testListQueues(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	dlq := insertQueue(t, c, "list-dlq")
+	older := insertQueue(t, c, "list-older")
+	time.Sleep(10 * time.Millisecond)
+	newer := insertQueue(t, c, "list-newer")
+	deleted := insertQueue(t, c, "list-deleted")
+
+	older.DeadLetterQueueID = &dlq.ID
+	if err := c.UpdateQueue(ctx, older); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+	deletedAt := time.Now().Add(-time.Hour)
+	deleted.DeletedAt = &deletedAt
+	deleted.DeleteMode = models.QueueDeleteRefuse
+	if err := c.UpdateQueue(ctx, deleted); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+
+	ours := []int64{dlq.ID, older.ID, newer.ID, deleted.ID}
+	hourAgo := time.Now().Add(-30 * time.Minute)
+	tests := []struct {
+		name string
+		opts storage.QueueListOptions
+		want []int64
+	}{
+		{"not deleted, newest first", storage.QueueListOptions{}, []int64{newer.ID, older.ID, dlq.ID}},
+		{"only deleted", storage.QueueListOptions{Deleted: storage.OnlyDeleted}, []int64{deleted.ID}},
+		{"deleted before", storage.QueueListOptions{Deleted: storage.OnlyDeleted, DeletedBefore: &deletedAt}, nil},
+		{"deleted before later", storage.QueueListOptions{Deleted: storage.OnlyDeleted, DeletedBefore: &hourAgo}, []int64{deleted.ID}},
+		{"including deleted", storage.QueueListOptions{Deleted: storage.IncludeDeleted}, []int64{deleted.ID, newer.ID, older.ID, dlq.ID}},
+		{"by dead letter queue", storage.QueueListOptions{DeadLetterQueueID: dlq.ID}, []int64{older.ID}},
+	}
+
+	for _, tt := range tests {
+		queues, err := c.ListQueues(ctx, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: ListQueues: %v", tt.name, err)
+		}
+		got := queueIDs(queues, ours)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("%s: ListQueues returned %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testDeleteQueue(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	dlq := insertQueue(t, c, "delete-dlq")
+	source := insertQueue(t, c, "delete-source")
+	source.DeadLetterQueueID = &dlq.ID
+	if err := c.UpdateQueue(ctx, source); err != nil {
+		t.Fatalf("UpdateQueue: %v", err)
+	}
+	worker := insertWorker(t, c, dlq.ID)
+	schedule := insertSchedule(t, c, dlq.ID, time.Now())
+
+	if err := c.DeleteQueue(ctx, dlq.ID); err != nil {
+		t.Fatalf("DeleteQueue: %v", err)
+	}
+	if _, err := c.GetQueueByName(ctx, dlq.Name); !errors.Is(err, storage.ErrQueueNotFound) {
+		t.Fatalf("GetQueueByName of removed queue: got %v, want ErrQueueNotFound", err)
+	}
+	if _, err := c.GetWorker(ctx, worker.ID); !errors.Is(err, storage.ErrWorkerNotFound) {
+		t.Fatalf("GetWorker of removed queue's worker: got %v, want ErrWorkerNotFound", err)
+	}
+	if _, err := c.GetSchedule(ctx, schedule.ID); !errors.Is(err, storage.ErrScheduleNotFound) {
+		t.Fatalf("GetSchedule of removed queue's schedule: got %v, want ErrScheduleNotFound", err)
+	}
+
+	got, err := c.GetQueue(ctx, source.ID)
+	if err != nil {
+		t.Fatalf("GetQueue: %v", err)
+	}
+	if got.DeadLetterQueueID != nil {
+		t.Fatalf("source queue still links removed dead letter queue %d", *got.DeadLetterQueueID)
+	}
+
+	if err := c.DeleteQueue(ctx, dlq.ID); !errors.Is(err, storage.ErrQueueNotFound) {
+		t.Fatalf("DeleteQueue of removed queue: got %v, want ErrQueueNotFound", err)
+	}
+}
+
+func testRunInTx(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	failure := errors.New("rolled back")
+
+	var rolledBack *models.Queue
+	err := c.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		rolledBack = insertQueue(t, tx, "tx-rolled-back")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("RunInTx: got %v, want the error of fn", err)
+	}
+	if _, err := c.GetQueueByName(ctx, rolledBack.Name); !errors.Is(err, storage.ErrQueueNotFound) {
+		t.Fatalf("queue inserted by a failed transaction: got %v, want ErrQueueNotFound", err)
+	}
+
+	var kept *models.Queue
+	err = c.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+		// A nested transaction is part of the outer one
+		return tx.RunInTx(ctx, func(ctx context.Context, tx storage.Catalog) error {
+			kept = insertQueue(t, tx, "tx-kept")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("RunInTx: %v", err)
+	}
+	if _, err := c.GetQueue(ctx, kept.ID); err != nil {
+		t.Fatalf("GetQueue of queue inserted by a transaction: %v", err)
+	}
+}
+
+func testWorkers(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	queue := insertQueue(t, c, "workers")
+	other := insertQueue(t, c, "workers-other")
+
+	first := insertWorker(t, c, queue.ID)
+	time.Sleep(10 * time.Millisecond)
+	second := insertWorker(t, c, queue.ID)
+	insertWorker(t, c, other.ID)
+
+	workers, err := c.ListWorkers(ctx, queue.ID)
+	if err != nil {
+		t.Fatalf("ListWorkers: %v", err)
+	}
+	if len(workers) != 2 || workers[0].ID != second.ID || workers[1].ID != first.ID {
+		t.Fatalf("ListWorkers returned %d workers, want [%d %d]", len(workers), second.ID, first.ID)
+	}
+	if workers[0].Queue == nil || workers[0].Queue.ID != queue.ID {
+		t.Fatalf("ListWorkers did not load the queue: %+v", workers[0].Queue)
+	}
+
+	at := time.Now().Truncate(time.Second)
+	pinged, err := c.PingWorker(ctx, first.ID, models.WorkerStatusBusy, []int64{7, 8}, at)
+	if err != nil {
+		t.Fatalf("PingWorker: %v", err)
+	}
+	if pinged.Status != models.WorkerStatusBusy || !pinged.LastPing.Equal(at) || len(pinged.CurrentMessageIDs) != 2 {
+		t.Fatalf("PingWorker returned %+v", pinged)
+	}
+
+	if err := c.CountWorker(ctx, first.ID, 3, 1); err != nil {
+		t.Fatalf("CountWorker: %v", err)
+	}
+	if err := c.CountWorker(ctx, first.ID, 1, 0); err != nil {
+		t.Fatalf("CountWorker: %v", err)
+	}
+
+	stopped, err := c.StopWorker(ctx, first.ID, time.Now())
+	if err != nil {
+		t.Fatalf("StopWorker: %v", err)
+	}
+	if stopped.Status != models.WorkerStatusStopped || len(stopped.CurrentMessageIDs) != 0 {
+		t.Fatalf("StopWorker returned %+v", stopped)
+	}
+	if stopped.ProcessedCount != 4 || stopped.FailedCount != 1 {
+		t.Fatalf("counters are %d processed and %d failed, want 4 and 1", stopped.ProcessedCount, stopped.FailedCount)
+	}
+
+	if _, err := c.PingWorker(ctx, first.ID, models.WorkerStatusIdle, nil, time.Now()); !errors.Is(err, storage.ErrWorkerStopped) {
+		t.Fatalf("PingWorker of stopped worker: got %v, want ErrWorkerStopped", err)
+	}
+	if _, err := c.PingWorker(ctx, first.ID+1000000, models.WorkerStatusIdle, nil, time.Now()); !errors.Is(err, storage.ErrWorkerNotFound) {
+		t.Fatalf("PingWorker of unknown worker: got %v, want ErrWorkerNotFound", err)
+	}
+	if _, err := c.StopWorker(ctx, first.ID+1000000, time.Now()); !errors.Is(err, storage.ErrWorkerNotFound) {
+		t.Fatalf("StopWorker of unknown worker: got %v, want ErrWorkerNotFound", err)
+	}
+}
+
+func testStopLapsedWorkers(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	queue := insertQueue(t, c, "lapsed")
+
+	// Pings from long ago, so that workers of other tests are not affected
+	longAgo := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	lapsed := insertWorker(t, c, queue.ID)
+	if _, err := c.PingWorker(ctx, lapsed.ID, models.WorkerStatusBusy, []int64{1}, longAgo); err != nil {
+		t.Fatalf("PingWorker: %v", err)
+	}
+	live := insertWorker(t, c, queue.ID)
+	if _, err := c.PingWorker(ctx, live.ID, models.WorkerStatusIdle, nil, longAgo.Add(2*time.Hour)); err != nil {
+		t.Fatalf("PingWorker: %v", err)
+	}
+
+	stopped, err := c.StopLapsedWorkers(ctx, longAgo.Add(time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("StopLapsedWorkers: %v", err)
+	}
+	if len(stopped) != 1 || stopped[0].ID != lapsed.ID || stopped[0].Status != models.WorkerStatusStopped {
+		t.Fatalf("StopLapsedWorkers returned %d workers, want only %d", len(stopped), lapsed.ID)
+	}
+
+	// A stopped worker is not stopped again
+	stopped, err = c.StopLapsedWorkers(ctx, longAgo.Add(time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("StopLapsedWorkers: %v", err)
+	}
+	if len(stopped) != 0 {
+		t.Fatalf("StopLapsedWorkers stopped %d workers again", len(stopped))
+	}
+}
+
+func testWorkerEvents(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	worker := insertWorker(t, c, insertQueue(t, c, "events").ID)
+
+	for _, typ := range []string{models.WorkerEventRegistered, models.WorkerEventLapsed} {
+		event := &models.WorkerEvent{WorkerID: worker.ID, Type: typ, CreatedAt: time.Now()}
+		if err := c.InsertWorkerEvent(ctx, event); err != nil {
+			t.Fatalf("InsertWorkerEvent: %v", err)
+		}
+	}
+
+	events, err := c.ListWorkerEvents(ctx, worker.ID, 0)
+	if err != nil {
+		t.Fatalf("ListWorkerEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != models.WorkerEventLapsed || events[1].Type != models.WorkerEventRegistered {
+		t.Fatalf("ListWorkerEvents returned %d events, want lapsed then registered", len(events))
+	}
+
+	events, err = c.ListWorkerEvents(ctx, worker.ID, 1)
+	if err != nil {
+		t.Fatalf("ListWorkerEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != models.WorkerEventLapsed {
+		t.Fatalf("ListWorkerEvents with limit 1 returned %d events", len(events))
+	}
+}
+
+func testSchedules(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	queue := insertQueue(t, c, "schedules")
+
+	due := time.Now().Add(-time.Minute).Truncate(time.Second)
+	schedule := insertSchedule(t, c, queue.ID, due)
+	later := insertSchedule(t, c, queue.ID, due.Add(time.Hour))
+
+	taken := *schedule
+	taken.ID = 0
+	if err := c.InsertSchedule(ctx, &taken); !errors.Is(err, storage.ErrNameTaken) {
+		t.Fatalf("InsertSchedule with a taken name: got %v, want ErrNameTaken", err)
+	}
+
+	got, err := c.GetScheduleForUpdate(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("GetScheduleForUpdate: %v", err)
+	}
+	if got.Name != schedule.Name || got.Headers["source"] != "conformance" || !got.NextRunAt.Equal(due) {
+		t.Fatalf("GetScheduleForUpdate returned %+v", got)
+	}
+
+	dueSchedules, err := c.DueSchedules(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("DueSchedules: %v", err)
+	}
+	if !containsSchedule(dueSchedules, schedule.ID) || containsSchedule(dueSchedules, later.ID) {
+		t.Fatalf("DueSchedules should list schedule %d only", schedule.ID)
+	}
+
+	// Advancing from a stale next run changes nothing
+	next := due.Add(time.Hour)
+	advanced := *got
+	advanced.NextRunAt = &next
+	advanced.LastRunAt = &due
+	if err := c.AdvanceSchedule(ctx, &advanced, due.Add(-time.Hour)); err != nil {
+		t.Fatalf("AdvanceSchedule: %v", err)
+	}
+	if got, _ := c.GetSchedule(ctx, schedule.ID); !got.NextRunAt.Equal(due) {
+		t.Fatalf("AdvanceSchedule from a stale next run moved it to %v", got.NextRunAt)
+	}
+
+	if err := c.AdvanceSchedule(ctx, &advanced, due); err != nil {
+		t.Fatalf("AdvanceSchedule: %v", err)
+	}
+	got, err = c.GetSchedule(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("GetSchedule: %v", err)
+	}
+	if !got.NextRunAt.Equal(next) || got.LastRunAt == nil || !got.LastRunAt.Equal(due) {
+		t.Fatalf("AdvanceSchedule stored next %v and last %v", got.NextRunAt, got.LastRunAt)
+	}
+
+	got.Enabled = false
+	got.NextRunAt = nil
+	got.CronExpr = "@daily"
+	if err := c.UpdateSchedule(ctx, got); err != nil {
+		t.Fatalf("UpdateSchedule: %v", err)
+	}
+	got, err = c.GetSchedule(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("GetSchedule: %v", err)
+	}
+	if got.Enabled || got.NextRunAt != nil || got.CronExpr != "@daily" {
+		t.Fatalf("GetSchedule after UpdateSchedule returned %+v", got)
+	}
+
+	deleted, err := c.DeleteSchedule(ctx, schedule.ID)
+	if err != nil || deleted.ID != schedule.ID {
+		t.Fatalf("DeleteSchedule: %+v, %v", deleted, err)
+	}
+	if _, err := c.GetSchedule(ctx, schedule.ID); !errors.Is(err, storage.ErrScheduleNotFound) {
+		t.Fatalf("GetSchedule of deleted schedule: got %v, want ErrScheduleNotFound", err)
+	}
+	if _, err := c.DeleteSchedule(ctx, schedule.ID); !errors.Is(err, storage.ErrScheduleNotFound) {
+		t.Fatalf("DeleteSchedule of deleted schedule: got %v, want ErrScheduleNotFound", err)
+	}
+}
+
+func testScheduleRuns(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	schedule := insertSchedule(t, c, insertQueue(t, c, "runs").ID, time.Now())
+
+	tick := time.Now().Truncate(time.Minute)
+	for _, at := range []time.Time{tick.Add(-time.Minute), tick} {
+		run := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: at, Status: models.ScheduleRunRunning, StartedAt: time.Now()}
+		inserted, err := c.InsertScheduleRun(ctx, run, time.Now().Add(-time.Hour))
+		if err != nil || !inserted || run.ID == 0 {
+			t.Fatalf("InsertScheduleRun: inserted %v, ID %d, %v", inserted, run.ID, err)
+		}
+	}
+
+	repeat := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick, Status: models.ScheduleRunRunning, StartedAt: time.Now()}
+	inserted, err := c.InsertScheduleRun(ctx, repeat, time.Now().Add(-time.Hour))
+	if err != nil || inserted {
+		t.Fatalf("InsertScheduleRun of a recorded tick: inserted %v, %v", inserted, err)
+	}
+
+	runs, err := c.ListScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("ListScheduleRuns: %v", err)
+	}
+	if len(runs) != 2 || !runs[0].ScheduledFor.Equal(tick) {
+		t.Fatalf("ListScheduleRuns returned %d runs, want 2 latest tick first", len(runs))
+	}
+
+	run := runs[0]
+	finished := time.Now()
+	run.Status = models.ScheduleRunFailed
+	run.Error = "boom"
+	run.FinishedAt = &finished
+	if err := c.UpdateScheduleRun(ctx, run); err != nil {
+		t.Fatalf("UpdateScheduleRun: %v", err)
+	}
+
+	runs, err = c.ListScheduleRuns(ctx, schedule.ID, 1)
+	if err != nil {
+		t.Fatalf("ListScheduleRuns: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != models.ScheduleRunFailed || runs[0].Error != "boom" || runs[0].FinishedAt == nil {
+		t.Fatalf("ListScheduleRuns with limit 1 returned %+v", runs)
+	}
+}
+
+func testStaleScheduleRuns(t *testing.T, c storage.Catalog) {
+	ctx := context.Background()
+	schedule := insertSchedule(t, c, insertQueue(t, c, "stale-runs").ID, time.Now())
+
+	now := time.Now()
+	tick := now.Truncate(time.Minute)
+	crashed := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick, Status: models.ScheduleRunRunning, StartedAt: now.Add(-10 * time.Minute)}
+	if inserted, err := c.InsertScheduleRun(ctx, crashed, now.Add(-time.Minute)); err != nil || !inserted {
+		t.Fatalf("InsertScheduleRun: inserted %v, %v", inserted, err)
+	}
+
+	// A run started after staleBefore still has a live runner
+	live := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick, Status: models.ScheduleRunRunning, StartedAt: now}
+	if inserted, err := c.InsertScheduleRun(ctx, live, now.Add(-time.Hour)); err != nil || inserted {
+		t.Fatalf("InsertScheduleRun of a live run: inserted %v, %v", inserted, err)
+	}
+
+	retry := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick, Status: models.ScheduleRunRunning, StartedAt: now}
+	inserted, err := c.InsertScheduleRun(ctx, retry, now.Add(-time.Minute))
+	if err != nil || !inserted || retry.ID != crashed.ID {
+		t.Fatalf("InsertScheduleRun of a stale run: inserted %v, ID %d, %v; want the ID %d taken over", inserted, retry.ID, err, crashed.ID)
+	}
+	if inserted, err := c.InsertScheduleRun(ctx, retry, now.Add(-time.Minute)); err != nil || inserted {
+		t.Fatalf("InsertScheduleRun of a run just taken over: inserted %v, %v", inserted, err)
+	}
+
+	finished := now
+	retry.Status = models.ScheduleRunEnqueued
+	retry.FinishedAt = &finished
+	if err := c.UpdateScheduleRun(ctx, retry); err != nil {
+		t.Fatalf("UpdateScheduleRun: %v", err)
+	}
+	if inserted, err := c.InsertScheduleRun(ctx, retry, now.Add(time.Hour)); err != nil || inserted {
+		t.Fatalf("InsertScheduleRun of a finished run: inserted %v, %v", inserted, err)
+	}
+
+	// An older tick interrupted for good, and one still running
+	interrupted := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick.Add(-2 * time.Minute), Status: models.ScheduleRunRunning, StartedAt: now.Add(-10 * time.Minute)}
+	running := &models.ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: tick.Add(-time.Minute), Status: models.ScheduleRunRunning, StartedAt: now}
+	for _, run := range []*models.ScheduleRun{interrupted, running} {
+		if inserted, err := c.InsertScheduleRun(ctx, run, now.Add(-time.Hour)); err != nil || !inserted {
+			t.Fatalf("InsertScheduleRun: inserted %v, %v", inserted, err)
+		}
+	}
+
+	failed, err := c.FailStaleScheduleRuns(ctx, now.Add(-time.Minute), now, "interrupted")
+	if err != nil || failed != 1 {
+		t.Fatalf("FailStaleScheduleRuns: failed %d, %v; want 1", failed, err)
+	}
+
+	runs, err := c.ListScheduleRuns(ctx, schedule.ID, 0)
+	if err != nil {
+		t.Fatalf("ListScheduleRuns: %v", err)
+	}
+	status := make(map[int64]*models.ScheduleRun, len(runs))
+	for _, run := range runs {
+		status[run.ID] = run
+	}
+	if run := status[interrupted.ID]; run == nil || run.Status != models.ScheduleRunFailed || run.Error != "interrupted" || run.FinishedAt == nil {
+		t.Fatalf("interrupted run after FailStaleScheduleRuns: %+v", run)
+	}
+	if run := status[running.ID]; run == nil || run.Status != models.ScheduleRunRunning {
+		t.Fatalf("live run after FailStaleScheduleRuns: %+v", run)
+	}
+	if run := status[retry.ID]; run == nil || run.Status != models.ScheduleRunEnqueued {
+		t.Fatalf("finished run after FailStaleScheduleRuns: %+v", run)
+	}
+}
+
+// insertQueue stores a queue under a unique name and removes it when the test ends
+func insertQueue(t *testing.T, c storage.Catalog, name string) *models.Queue {
+	t.Helper()
+
+	now := time.Now()
+	queue := &models.Queue{
+		Name:      fmt.Sprintf("conformance-%s-%d", name, now.UnixNano()),
+		Type:      "fifo",
+		Config:    "{}",
+		IsActive:  true,
+		State:     models.QueueStateActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := c.InsertQueue(context.Background(), queue); err != nil {
+		t.Fatalf("InsertQueue: %v", err)
+	}
+
+	t.Cleanup(func() { c.DeleteQueue(context.Background(), queue.ID) })
+	return queue
+}
+
+func insertWorker(t *testing.T, c storage.Catalog, queueID int64) *models.Worker {
+	t.Helper()
+
+	now := time.Now()
+	worker := &models.Worker{
+		Name:      "conformance",
+		QueueID:   queueID,
+		Status:    models.WorkerStatusIdle,
+		LastPing:  now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := c.InsertWorker(context.Background(), worker); err != nil {
+		t.Fatalf("InsertWorker: %v", err)
+	}
+	return worker
+}
+
+func insertSchedule(t *testing.T, c storage.Catalog, queueID int64, nextRunAt time.Time) *models.Schedule {
+	t.Helper()
+
+	now := time.Now()
+	schedule := &models.Schedule{
+		Name:            fmt.Sprintf("conformance-%d", now.UnixNano()),
+		CronExpr:        "@hourly",
+		Timezone:        "UTC",
+		QueueID:         queueID,
+		PayloadTemplate: "{}",
+		Headers:         map[string]string{"source": "conformance"},
+		CatchUp:         models.ScheduleCatchUpLatest,
+		Enabled:         true,
+		NextRunAt:       &nextRunAt,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := c.InsertSchedule(context.Background(), schedule); err != nil {
+		t.Fatalf("InsertSchedule: %v", err)
+	}
+	return schedule
+}
+
+// queueIDs returns the IDs of queues that are among ours, in order
+func queueIDs(queues []*models.Queue, ours []int64) []int64 {
+	var got []int64
+	for _, queue := range queues {
+		for _, id := range ours {
+			if queue.ID == id {
+				got = append(got, id)
+			}
+		}
+	}
+	return got
+}
+
+func containsSchedule(schedules []*models.Schedule, id int64) bool {
+	for _, schedule := range schedules {
+		if schedule.ID == id {
+			return true
+		}
+	}
+	return false
+}
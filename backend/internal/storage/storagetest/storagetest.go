@@ -0,0 +1,1193 @@
+// Package storagetest provides a conformance suite that every storage.Backend
+// implementation must pass.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shravan20/qafka/internal/models"
+	"github.com/shravan20/qafka/internal/selector"
+	"github.com/shravan20/qafka/internal/storage"
+)
+
+// Harness gives the suite access to a backend under test
+type Harness struct {
+	Backend storage.Backend
+
+	// NewQueue returns the ID of a queue that holds no messages yet
+	NewQueue func(t *testing.T) int64
+
+	// NewWorker returns the ID of a newly registered worker
+	NewWorker func(t *testing.T) int64
+
+	worker int64 // claims on behalf of the test, registered on first use
+}
+
+// workerID returns the worker claiming on behalf of the test
+func (h *Harness) workerID(t *testing.T) int64 {
+	if h.worker == 0 {
+		h.worker = h.NewWorker(t)
+	}
+	return h.worker
+}
+
+// Run runs the conformance suite. newHarness is called once per subtest.
+func Run(t *testing.T, newHarness func(t *testing.T) *Harness) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, h *Harness)
+	}{
+		{"EnqueueAndGet", testEnqueueAndGet},
+		{"List", testList},
+		{"ClaimOrder", testClaimOrder},
+		{"ClaimOrderFIFOAndLIFO", testClaimOrderFIFOAndLIFO},
+		{"ClaimSkipsScheduled", testClaimSkipsScheduled},
+		{"PromoteDue", testPromoteDue},
+		{"Batch", testBatch},
+		{"Selector", testSelector},
+		{"Dedup", testDedup},
+		{"MessageGroups", testMessageGroups},
+		{"ConcurrentClaimsAreExclusive", testConcurrentClaimsAreExclusive},
+		{"Ack", testAck},
+		{"NackRetry", testNackRetry},
+		{"ErrorHistory", testErrorHistory},
+		{"NackDeadLetter", testNackDeadLetter},
+		{"NackFail", testNackFail},
+		{"Release", testRelease},
+		{"ExtendLease", testExtendLease},
+		{"ExpireLeases", testExpireLeases},
+		{"ReleaseAndFailWorker", testReleaseAndFailWorker},
+		{"Redrive", testRedrive},
+		{"DepthAndPurge", testDepthAndPurge},
+		{"DeleteFinished", testDeleteFinished},
+		{"BulkDeleteInChunks", testBulkDeleteInChunks},
+		{"BulkRequeue", testBulkRequeue},
+		{"BulkReprioritize", testBulkReprioritize},
+		{"BulkArchive", testBulkArchive},
+		{"BulkRemaining", testBulkRemaining},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newHarness(t))
+		})
+	}
+}
+
+func testEnqueueAndGet(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	message := enqueue(t, h, queueID, "hello", 0)
+	if message.ID == 0 {
+		t.Fatal("Enqueue did not assign an ID")
+	}
+
+	got, err := h.Backend.Get(ctx, message.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Payload != "hello" || got.QueueID != queueID || got.Status != models.MessageStatusPending {
+		t.Fatalf("Get returned %+v", got)
+	}
+
+	if _, err := h.Backend.Get(ctx, message.ID+1000000); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Fatalf("Get of unknown message: got %v, want ErrMessageNotFound", err)
+	}
+}
+
+func testList(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+	otherQueueID := h.NewQueue(t)
+
+	first := enqueue(t, h, queueID, "first", 0)
+	time.Sleep(time.Millisecond)
+	second := enqueue(t, h, queueID, "second", 0)
+	enqueue(t, h, otherQueueID, "other", 0)
+
+	messages, err := h.Backend.List(ctx, storage.ListOptions{QueueID: queueID})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != second.ID || messages[1].ID != first.ID {
+		t.Fatalf("List returned %v, want newest first", ids(messages))
+	}
+
+	messages, err = h.Backend.List(ctx, storage.ListOptions{QueueID: queueID, Limit: 1})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("List with limit 1 returned %d messages", len(messages))
+	}
+
+	claim(t, h, queueID)
+	messages, err = h.Backend.List(ctx, storage.ListOptions{QueueID: queueID, Status: models.MessageStatusProcessing})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != first.ID {
+		t.Fatalf("List by status returned %v, want [%d]", ids(messages), first.ID)
+	}
+}
+
+func testClaimOrder(t *testing.T, h *Harness) {
+	queueID := h.NewQueue(t)
+
+	low := enqueue(t, h, queueID, "low", 0)
+	time.Sleep(time.Millisecond)
+	high := enqueue(t, h, queueID, "high", 5)
+	time.Sleep(time.Millisecond)
+	lowLater := enqueue(t, h, queueID, "low later", 0)
+
+	for _, want := range []int64{high.ID, low.ID, lowLater.ID} {
+		got := claim(t, h, queueID)
+		if got.ID != want {
+			t.Fatalf("claimed message %d, want %d", got.ID, want)
+		}
+		if got.Status != models.MessageStatusProcessing || got.LeaseToken == "" || got.LeaseExpiresAt == nil {
+			t.Fatalf("claimed message has no lease: %+v", got)
+		}
+	}
+
+	expectEmpty(t, h, queueID)
+}
+
+func testClaimOrderFIFOAndLIFO(t *testing.T, h *Harness) {
+	tests := []struct {
+		order storage.Order
+		want  func(first, second, third *models.Message) []int64
+	}{
+		{storage.OrderFIFO, func(first, second, third *models.Message) []int64 {
+			return []int64{first.ID, second.ID, third.ID}
+		}},
+		{storage.OrderLIFO, func(first, second, third *models.Message) []int64 {
+			return []int64{third.ID, second.ID, first.ID}
+		}},
+	}
+
+	for _, tt := range tests {
+		queueID := h.NewQueue(t)
+
+		first := enqueue(t, h, queueID, "first", 0)
+		time.Sleep(time.Millisecond)
+		second := enqueue(t, h, queueID, "second", 5)
+		time.Sleep(time.Millisecond)
+		third := enqueue(t, h, queueID, "third", 0)
+
+		opts := claimOptions(h.workerID(t))
+		opts.Order = tt.order
+		claimed, err := h.Backend.ClaimBatch(context.Background(), queueID, 3, opts)
+		if err != nil {
+			t.Fatalf("ClaimBatch: %v", err)
+		}
+
+		want := tt.want(first, second, third)
+		got := ids(claimed)
+		if len(got) != len(want) {
+			t.Fatalf("order %d: claimed %v, want %v", tt.order, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("order %d: claimed %v, want %v", tt.order, got, want)
+			}
+		}
+	}
+}
+
+func testClaimSkipsScheduled(t *testing.T, h *Harness) {
+	queueID := h.NewQueue(t)
+
+	future := time.Now().Add(time.Hour)
+	message := newMessage(queueID, "later", 0)
+	message.Status = models.MessageStatusScheduled
+	message.ScheduledAt = &future
+	if err := h.Backend.Enqueue(context.Background(), message, 0); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	expectEmpty(t, h, queueID)
+
+	next, err := h.Backend.NextDue(context.Background(), queueID)
+	if err != nil {
+		t.Fatalf("NextDue: %v", err)
+	}
+	if next == nil || next.Sub(future).Abs() > time.Millisecond {
+		t.Fatalf("NextDue returned %v, want %v", next, future)
+	}
+}
+
+func testPromoteDue(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	scheduled := func(payload string, at time.Time) *models.Message {
+		message := newMessage(queueID, payload, 0)
+		message.Status = models.MessageStatusScheduled
+		message.ScheduledAt = &at
+		if err := h.Backend.Enqueue(ctx, message, 0); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		return message
+	}
+
+	now := time.Now()
+	first := scheduled("first", now.Add(-time.Minute))
+	second := scheduled("second", now.Add(-time.Second))
+	later := scheduled("later", now.Add(time.Hour))
+
+	// A due message is not delivered before it is promoted
+	expectEmpty(t, h, queueID)
+
+	// Promote concurrently: every due message is promoted exactly once
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var promoted []int64
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			messages, err := h.Backend.PromoteDue(ctx, 1)
+			if err != nil {
+				t.Errorf("PromoteDue: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, message := range messages {
+				if message.QueueID == queueID {
+					if message.Status != models.MessageStatusPending {
+						t.Errorf("PromoteDue returned %+v", message)
+					}
+					promoted = append(promoted, message.ID)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(promoted) != 2 || promoted[0] == promoted[1] {
+		t.Fatalf("promoted %v, want %d and %d once each", promoted, first.ID, second.ID)
+	}
+	if got := claim(t, h, queueID); got.ID != first.ID {
+		t.Fatalf("claimed %d, want %d", got.ID, first.ID)
+	}
+	if got := claim(t, h, queueID); got.ID != second.ID {
+		t.Fatalf("claimed %d, want %d", got.ID, second.ID)
+	}
+	expectEmpty(t, h, queueID)
+
+	next, err := h.Backend.NextDue(ctx, 0)
+	if err != nil {
+		t.Fatalf("NextDue: %v", err)
+	}
+	if next == nil || next.After(*later.ScheduledAt) {
+		t.Fatalf("NextDue of all queues returned %v, want at most %v", next, later.ScheduledAt)
+	}
+}
+
+func testBatch(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	messages := []*models.Message{
+		newMessage(queueID, "a", 0),
+		newMessage(queueID, "b", 9),
+		newMessage(queueID, "c", 0),
+	}
+	if err := h.Backend.EnqueueBatch(ctx, messages, 0); err != nil {
+		t.Fatalf("EnqueueBatch: %v", err)
+	}
+	for _, message := range messages {
+		if message.ID == 0 {
+			t.Fatal("EnqueueBatch did not assign IDs")
+		}
+	}
+
+	claimed, err := h.Backend.ClaimBatch(ctx, queueID, 2, claimOptions(h.workerID(t)))
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].ID != messages[1].ID || claimed[1].ID != messages[0].ID {
+		t.Fatalf("ClaimBatch returned %v, want [%d %d]", ids(claimed), messages[1].ID, messages[0].ID)
+	}
+
+	claimed, err = h.Backend.ClaimBatch(ctx, queueID, 10, claimOptions(h.workerID(t)))
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != messages[2].ID {
+		t.Fatalf("ClaimBatch returned %v, want [%d]", ids(claimed), messages[2].ID)
+	}
+
+	claimed, err = h.Backend.ClaimBatch(ctx, queueID, 10, claimOptions(h.workerID(t)))
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	if claimed == nil || len(claimed) != 0 {
+		t.Fatalf("ClaimBatch on an empty queue returned %v, want an empty slice", claimed)
+	}
+}
+
+func testSelector(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	headers := []map[string]string{
+		{"type": "invoice", "region": "us"},
+		{"type": "invoice", "region": "eu"},
+		{"type": "receipt", "region": "eu"},
+		nil,
+	}
+	messages := make([]*models.Message, len(headers))
+	for i, h := range headers {
+		messages[i] = newMessage(queueID, "job", 0)
+		messages[i].Headers = h
+	}
+	if err := h.Backend.EnqueueBatch(ctx, messages, 0); err != nil {
+		t.Fatalf("EnqueueBatch: %v", err)
+	}
+
+	sel, err := selector.Parse("type=invoice AND region=eu")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	listed, err := h.Backend.List(ctx, storage.ListOptions{QueueID: queueID, Selector: sel})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != messages[1].ID || listed[0].Headers["region"] != "eu" {
+		t.Fatalf("List with selector returned %v, want [%d]", ids(listed), messages[1].ID)
+	}
+
+	sel, err = selector.Parse("region!=us")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	opts := claimOptions(h.workerID(t))
+	opts.Selector = sel
+	claimed, err := h.Backend.ClaimBatch(ctx, queueID, 10, opts)
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	if len(claimed) != 3 {
+		t.Fatalf("ClaimBatch with selector returned %v, want the three messages outside us", ids(claimed))
+	}
+	for _, message := range claimed {
+		if message.ID == messages[0].ID {
+			t.Fatalf("ClaimBatch with selector claimed excluded message %d", message.ID)
+		}
+	}
+}
+
+func testDedup(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+	otherQueueID := h.NewQueue(t)
+
+	first := newMessage(queueID, "first", 0)
+	first.DedupID = "order-1"
+	if err := h.Backend.Enqueue(ctx, first, time.Minute); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	retry := newMessage(queueID, "retry", 0)
+	retry.DedupID = "order-1"
+	if err := h.Backend.Enqueue(ctx, retry, time.Minute); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if retry.ID != first.ID || retry.Payload != "first" {
+		t.Fatalf("duplicate Enqueue returned message %d %q, want original %d", retry.ID, retry.Payload, first.ID)
+	}
+
+	// Keys are scoped to their queue
+	other := newMessage(otherQueueID, "other", 0)
+	other.DedupID = "order-1"
+	if err := h.Backend.Enqueue(ctx, other, time.Minute); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if other.ID == first.ID {
+		t.Fatal("dedup key of one queue suppressed a message of another")
+	}
+
+	batch := []*models.Message{
+		newMessage(queueID, "batch-a", 0),
+		newMessage(queueID, "batch-b", 0),
+		newMessage(queueID, "batch-c", 0),
+		newMessage(queueID, "batch-d", 0),
+	}
+	batch[0].DedupID = "order-1"
+	batch[1].DedupID = "order-2"
+	batch[2].DedupID = "order-2"
+	if err := h.Backend.EnqueueBatch(ctx, batch, time.Minute); err != nil {
+		t.Fatalf("EnqueueBatch: %v", err)
+	}
+	if batch[0].ID != first.ID {
+		t.Fatalf("batch item repeating a stored key got message %d, want %d", batch[0].ID, first.ID)
+	}
+	if batch[2].ID != batch[1].ID || batch[1].Payload != "batch-b" {
+		t.Fatalf("batch items sharing a key got messages %d and %d", batch[1].ID, batch[2].ID)
+	}
+	if batch[3].ID == 0 {
+		t.Fatal("EnqueueBatch did not store the message without a key")
+	}
+
+	listed, err := h.Backend.List(ctx, storage.ListOptions{QueueID: queueID})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("queue holds %d messages, want 3", len(listed))
+	}
+
+	// Without a window, keys are ignored
+	undeduped := newMessage(queueID, "undeduped", 0)
+	undeduped.DedupID = "order-1"
+	if err := h.Backend.Enqueue(ctx, undeduped, 0); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if undeduped.ID == first.ID {
+		t.Fatal("Enqueue deduplicated a message without a window")
+	}
+
+	// Once the window has passed, the key can be used again
+	short := newMessage(queueID, "short", 0)
+	short.DedupID = "order-3"
+	if err := h.Backend.Enqueue(ctx, short, time.Millisecond); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := h.Backend.ExpireDedupKeys(ctx); err != nil {
+		t.Fatalf("ExpireDedupKeys: %v", err)
+	}
+
+	again := newMessage(queueID, "again", 0)
+	again.DedupID = "order-3"
+	if err := h.Backend.Enqueue(ctx, again, time.Minute); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if again.ID == short.ID {
+		t.Fatal("Enqueue deduplicated against an expired key")
+	}
+}
+
+func testMessageGroups(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	// a2 outranks everything, but must wait for a1 of its group
+	messages := []*models.Message{
+		newMessage(queueID, "a1", 0),
+		newMessage(queueID, "a2", 10),
+		newMessage(queueID, "b1", 0),
+		newMessage(queueID, "plain", 0),
+	}
+	messages[0].GroupKey = "a"
+	messages[1].GroupKey = "a"
+	messages[2].GroupKey = "b"
+	if err := h.Backend.EnqueueBatch(ctx, messages, 0); err != nil {
+		t.Fatalf("EnqueueBatch: %v", err)
+	}
+	a1, a2, b1, plain := messages[0], messages[1], messages[2], messages[3]
+
+	opts := claimOptions(h.workerID(t))
+	claimed, err := h.Backend.ClaimBatch(ctx, queueID, 10, opts)
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	want := map[int64]bool{a1.ID: true, b1.ID: true, plain.ID: true}
+	if len(claimed) != len(want) {
+		t.Fatalf("ClaimBatch claimed %v, want a1, b1 and plain", ids(claimed))
+	}
+	for _, message := range claimed {
+		if !want[message.ID] {
+			t.Fatalf("ClaimBatch claimed %v, want a1, b1 and plain", ids(claimed))
+		}
+	}
+
+	// The group stays blocked while a1 is in flight
+	expectEmpty(t, h, queueID)
+
+	if _, err := h.Backend.Ack(ctx, a1.ID, opts.LeaseToken); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+
+	next := claim(t, h, queueID)
+	if next.ID != a2.ID {
+		t.Fatalf("after acking a1 claimed %d, want a2 (%d)", next.ID, a2.ID)
+	}
+}
+
+func testConcurrentClaimsAreExclusive(t *testing.T, h *Harness) {
+	const messages, consumers = 50, 8
+	queueID := h.NewQueue(t)
+
+	for i := 0; i < messages; i++ {
+		enqueue(t, h, queueID, "work", 0)
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = make(map[int64]int)
+		wg      sync.WaitGroup
+	)
+
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func(workerID int64) {
+			defer wg.Done()
+			for {
+				message, err := h.Backend.Claim(context.Background(), queueID, claimOptions(workerID))
+				if errors.Is(err, storage.ErrNoMessageAvailable) {
+					return
+				}
+				if err != nil {
+					t.Errorf("Claim: %v", err)
+					return
+				}
+				mu.Lock()
+				claimed[message.ID]++
+				mu.Unlock()
+			}
+		}(h.NewWorker(t))
+	}
+	wg.Wait()
+
+	if len(claimed) != messages {
+		t.Fatalf("claimed %d distinct messages, want %d", len(claimed), messages)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Fatalf("message %d was claimed %d times", id, n)
+		}
+	}
+}
+
+func testAck(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message := claim(t, h, queueID)
+
+	if _, err := h.Backend.Ack(ctx, message.ID, "not-the-token"); !errors.Is(err, storage.ErrLeaseNotHeld) {
+		t.Fatalf("Ack with wrong token: got %v, want ErrLeaseNotHeld", err)
+	}
+
+	acked, err := h.Backend.Ack(ctx, message.ID, message.LeaseToken)
+	if err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if acked.Status != models.MessageStatusCompleted || acked.ProcessedAt == nil || acked.LeaseToken != "" {
+		t.Fatalf("Ack returned %+v", acked)
+	}
+
+	if _, err := h.Backend.Ack(ctx, message.ID, message.LeaseToken); !errors.Is(err, storage.ErrLeaseNotHeld) {
+		t.Fatalf("second Ack: got %v, want ErrLeaseNotHeld", err)
+	}
+	if _, err := h.Backend.Ack(ctx, message.ID+1000000, message.LeaseToken); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Fatalf("Ack of unknown message: got %v, want ErrMessageNotFound", err)
+	}
+}
+
+func testNackRetry(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message := claim(t, h, queueID)
+
+	retryAt := time.Now().Add(time.Hour)
+	nacked, err := h.Backend.Nack(ctx, message.ID, message.LeaseToken, storage.Failure{Error: "boom", RetryAt: &retryAt})
+	if err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	if nacked.Status != models.MessageStatusScheduled || nacked.RetryCount != 1 || nacked.ErrorMessage != "boom" || nacked.FailedAt == nil {
+		t.Fatalf("Nack returned %+v", nacked)
+	}
+	if len(nacked.ErrorHistory) != 1 || nacked.ErrorHistory[0].Error != "boom" || nacked.ErrorHistory[0].Attempt != 1 {
+		t.Fatalf("Nack recorded error history %+v", nacked.ErrorHistory)
+	}
+
+	expectEmpty(t, h, queueID)
+}
+
+func testErrorHistory(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	message := enqueue(t, h, queueID, "job", 0)
+	stored, err := h.Backend.Get(ctx, message.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(stored.ErrorHistory) != 0 {
+		t.Fatalf("new message has error history %+v", stored.ErrorHistory)
+	}
+
+	// A retry that is already due returns the message to pending
+	for attempt := 1; attempt <= 2; attempt++ {
+		claimed := claim(t, h, queueID)
+		retryAt := time.Now().Add(-time.Second)
+		nacked, err := h.Backend.Nack(ctx, claimed.ID, claimed.LeaseToken, storage.Failure{Error: fmt.Sprintf("attempt %d", attempt), RetryAt: &retryAt})
+		if err != nil {
+			t.Fatalf("Nack: %v", err)
+		}
+		if nacked.Status != models.MessageStatusPending {
+			t.Fatalf("Nack with a due retry: status %s, want pending", nacked.Status)
+		}
+	}
+
+	stored, err = h.Backend.Get(ctx, message.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(stored.ErrorHistory) != 2 {
+		t.Fatalf("error history has %d entries, want 2: %+v", len(stored.ErrorHistory), stored.ErrorHistory)
+	}
+	for i, entry := range stored.ErrorHistory {
+		if entry.Attempt != i+1 || entry.Error != fmt.Sprintf("attempt %d", i+1) || entry.FailedAt.IsZero() {
+			t.Fatalf("error history entry %d is %+v", i, entry)
+		}
+	}
+}
+
+func testNackDeadLetter(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+	dlqID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message := claim(t, h, queueID)
+
+	nacked, err := h.Backend.Nack(ctx, message.ID, message.LeaseToken, storage.Failure{Error: "poison", DeadLetterQueueID: &dlqID})
+	if err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	if nacked.Status != models.MessageStatusDeadLettered || nacked.QueueID != dlqID || nacked.DeadLetteredAt == nil {
+		t.Fatalf("Nack returned %+v", nacked)
+	}
+	if nacked.OriginalQueueID == nil || *nacked.OriginalQueueID != queueID {
+		t.Fatalf("Nack did not record the original queue: %+v", nacked.OriginalQueueID)
+	}
+}
+
+func testNackFail(t *testing.T, h *Harness) {
+	queueID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message := claim(t, h, queueID)
+
+	nacked, err := h.Backend.Nack(context.Background(), message.ID, message.LeaseToken, storage.Failure{Error: "fatal"})
+	if err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	if nacked.Status != models.MessageStatusFailed || nacked.QueueID != queueID {
+		t.Fatalf("Nack returned %+v", nacked)
+	}
+
+	expectEmpty(t, h, queueID)
+}
+
+func testRelease(t *testing.T, h *Harness) {
+	queueID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message := claim(t, h, queueID)
+
+	released, err := h.Backend.Release(context.Background(), message.ID, message.LeaseToken)
+	if err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if released.Status != models.MessageStatusPending || released.RetryCount != 0 || released.WorkerID != nil {
+		t.Fatalf("Release returned %+v", released)
+	}
+
+	if again := claim(t, h, queueID); again.ID != message.ID || again.LeaseToken == message.LeaseToken {
+		t.Fatalf("reclaimed %+v, want message %d under a new lease", again, message.ID)
+	}
+}
+
+func testExtendLease(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message := claim(t, h, queueID)
+
+	until := time.Now().Add(time.Hour)
+	extended, err := h.Backend.ExtendLease(ctx, message.ID, message.LeaseToken, until)
+	if err != nil {
+		t.Fatalf("ExtendLease: %v", err)
+	}
+	if extended.LeaseExpiresAt == nil || extended.LeaseExpiresAt.Before(until.Add(-time.Second)) {
+		t.Fatalf("ExtendLease set expiry %v, want %v", extended.LeaseExpiresAt, until)
+	}
+
+	if _, err := h.Backend.ExtendLease(ctx, message.ID, "not-the-token", until); !errors.Is(err, storage.ErrLeaseNotHeld) {
+		t.Fatalf("ExtendLease with wrong token: got %v, want ErrLeaseNotHeld", err)
+	}
+}
+
+func testExpireLeases(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	enqueue(t, h, queueID, "job", 0)
+	message, err := h.Backend.Claim(ctx, queueID, storage.ClaimOptions{WorkerID: h.workerID(t), LeaseToken: "short", Lease: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := h.Backend.Ack(ctx, message.ID, message.LeaseToken); !errors.Is(err, storage.ErrLeaseNotHeld) {
+		t.Fatalf("Ack after expiry: got %v, want ErrLeaseNotHeld", err)
+	}
+
+	n, err := h.Backend.ExpireLeases(ctx, func(m *models.Message) storage.Failure {
+		now := time.Now()
+		return storage.Failure{Error: "lease expired", RetryAt: &now}
+	})
+	if err != nil {
+		t.Fatalf("ExpireLeases: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("ExpireLeases affected %d messages, want 1", n)
+	}
+
+	again := claim(t, h, queueID)
+	if again.ID != message.ID || again.RetryCount != 1 {
+		t.Fatalf("redelivered %+v, want message %d with one retry", again, message.ID)
+	}
+}
+
+func testReleaseAndFailWorker(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+	dead := h.NewWorker(t)
+	alive := h.NewWorker(t)
+
+	for _, payload := range []string{"a", "b", "c"} {
+		enqueue(t, h, queueID, payload, 0)
+	}
+	held, err := h.Backend.ClaimBatch(ctx, queueID, 2, claimOptions(dead))
+	if err != nil || len(held) != 2 {
+		t.Fatalf("ClaimBatch: %v, %d messages", err, len(held))
+	}
+	other, err := h.Backend.Claim(ctx, queueID, claimOptions(alive))
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+
+	released, err := h.Backend.ReleaseWorker(ctx, dead)
+	if err != nil {
+		t.Fatalf("ReleaseWorker: %v", err)
+	}
+	if len(released) != 2 {
+		t.Fatalf("ReleaseWorker released %d messages, want 2", len(released))
+	}
+	for _, message := range released {
+		if message.Status != models.MessageStatusPending || message.RetryCount != 0 || message.WorkerID != nil || message.LeaseToken != "" {
+			t.Fatalf("ReleaseWorker returned %+v", message)
+		}
+	}
+
+	held, err = h.Backend.ClaimBatch(ctx, queueID, 2, claimOptions(dead))
+	if err != nil || len(held) != 2 {
+		t.Fatalf("ClaimBatch after release: %v, %d messages", err, len(held))
+	}
+
+	n, err := h.Backend.FailWorker(ctx, dead, func(m *models.Message) storage.Failure {
+		now := time.Now()
+		return storage.Failure{Error: "worker stopped", RetryAt: &now}
+	})
+	if err != nil {
+		t.Fatalf("FailWorker: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("FailWorker affected %d messages, want 2", n)
+	}
+
+	for _, message := range held {
+		got, err := h.Backend.Get(ctx, message.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != models.MessageStatusPending || got.RetryCount != 1 || got.WorkerID != nil {
+			t.Fatalf("FailWorker left %+v", got)
+		}
+	}
+
+	// Messages held by other workers are left alone
+	got, err := h.Backend.Get(ctx, other.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != models.MessageStatusProcessing || got.WorkerID == nil || *got.WorkerID != alive {
+		t.Fatalf("message of another worker is %+v", got)
+	}
+}
+
+func testRedrive(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+	dlqID := h.NewQueue(t)
+
+	for _, reason := range []string{"Timeout talking to billing", "bad payload"} {
+		enqueue(t, h, queueID, "job", 0)
+		message := claim(t, h, queueID)
+		if _, err := h.Backend.Nack(ctx, message.ID, message.LeaseToken, storage.Failure{Error: reason, DeadLetterQueueID: &dlqID}); err != nil {
+			t.Fatalf("Nack: %v", err)
+		}
+	}
+
+	n, err := h.Backend.Redrive(ctx, storage.RedriveOptions{DeadLetterQueueID: dlqID, ErrorContains: "timeout"})
+	if err != nil {
+		t.Fatalf("Redrive: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Redrive by error text moved %d messages, want 1", n)
+	}
+
+	redriven := claim(t, h, queueID)
+	if redriven.ErrorMessage != "Timeout talking to billing" || redriven.RetryCount != 0 || redriven.OriginalQueueID != nil {
+		t.Fatalf("redriven message %+v", redriven)
+	}
+
+	n, err = h.Backend.Redrive(ctx, storage.RedriveOptions{DeadLetterQueueID: dlqID, SourceQueueID: queueID})
+	if err != nil {
+		t.Fatalf("Redrive: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Redrive of the rest moved %d messages, want 1", n)
+	}
+}
+
+func testDepthAndPurge(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	for i := 0; i < 3; i++ {
+		enqueue(t, h, queueID, "job", 0)
+	}
+	claim(t, h, queueID)
+
+	depth, err := h.Backend.Depth(ctx, queueID)
+	if err != nil {
+		t.Fatalf("Depth: %v", err)
+	}
+	if depth[models.MessageStatusPending] != 2 || depth[models.MessageStatusProcessing] != 1 {
+		t.Fatalf("Depth returned %v", depth)
+	}
+
+	n, err := h.Backend.Purge(ctx, queueID)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("Purge deleted %d messages, want 3", n)
+	}
+
+	depth, err = h.Backend.Depth(ctx, queueID)
+	if err != nil {
+		t.Fatalf("Depth: %v", err)
+	}
+	if len(depth) != 0 {
+		t.Fatalf("Depth after purge returned %v", depth)
+	}
+}
+
+func testDeleteFinished(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	pending := enqueue(t, h, queueID, "pending", 0)
+	done := enqueue(t, h, queueID, "done", 0)
+
+	opts := claimOptions(h.workerID(t))
+	claimed, err := h.Backend.ClaimBatch(ctx, queueID, 2, opts)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("ClaimBatch: %v, %v", ids(claimed), err)
+	}
+	if _, err := h.Backend.Ack(ctx, done.ID, opts.LeaseToken); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if _, err := h.Backend.Release(ctx, pending.ID, opts.LeaseToken); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	n, err := h.Backend.DeleteFinished(ctx, queueID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteFinished: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("DeleteFinished deleted %d recent messages", n)
+	}
+
+	n, err = h.Backend.DeleteFinished(ctx, queueID, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("DeleteFinished: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("DeleteFinished deleted %d messages, want 1", n)
+	}
+
+	if _, err := h.Backend.Get(ctx, done.ID); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Fatalf("Get completed message: got %v, want ErrMessageNotFound", err)
+	}
+	if _, err := h.Backend.Get(ctx, pending.ID); err != nil {
+		t.Fatalf("DeleteFinished deleted a pending message: %v", err)
+	}
+}
+
+func testBulkDeleteInChunks(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	for i := 0; i < 5; i++ {
+		enqueue(t, h, queueID, "old", 0)
+	}
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	recent := enqueue(t, h, queueID, "recent", 0)
+
+	opts := storage.BulkOptions{
+		Filter: storage.MessageFilter{QueueID: queueID, CreatedBefore: &cutoff},
+		Action: storage.BulkDelete,
+		Limit:  2,
+	}
+
+	var chunks []int64
+	for {
+		n, err := h.Backend.Bulk(ctx, opts)
+		if err != nil {
+			t.Fatalf("Bulk: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		chunks = append(chunks, n)
+	}
+	if len(chunks) != 3 || chunks[0] != 2 || chunks[2] != 1 {
+		t.Fatalf("Bulk deleted chunks of %v, want [2 2 1]", chunks)
+	}
+
+	depth, err := h.Backend.Depth(ctx, queueID)
+	if err != nil {
+		t.Fatalf("Depth: %v", err)
+	}
+	if depth[models.MessageStatusPending] != 1 {
+		t.Fatalf("Depth after bulk delete returned %v", depth)
+	}
+	if _, err := h.Backend.Get(ctx, recent.ID); err != nil {
+		t.Fatalf("Bulk deleted a message newer than the cutoff: %v", err)
+	}
+}
+
+func testBulkRequeue(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	failed := enqueue(t, h, queueID, "failed", 0)
+	inFlight := enqueue(t, h, queueID, "in flight", 0)
+	enqueue(t, h, queueID, "pending", 0)
+
+	opts := claimOptions(h.workerID(t))
+	if _, err := h.Backend.ClaimBatch(ctx, queueID, 2, opts); err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	if _, err := h.Backend.Nack(ctx, failed.ID, opts.LeaseToken, storage.Failure{Error: "boom"}); err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+
+	// Without a status filter only finished messages are requeued
+	for i := 0; i < 2; i++ {
+		n, err := h.Backend.Bulk(ctx, storage.BulkOptions{
+			Filter: storage.MessageFilter{QueueID: queueID},
+			Action: storage.BulkRequeue,
+			Limit:  10,
+		})
+		if err != nil {
+			t.Fatalf("Bulk: %v", err)
+		}
+		if want := int64(1 - i); n != want {
+			t.Fatalf("Bulk requeued %d messages on pass %d, want %d", n, i+1, want)
+		}
+	}
+
+	requeued, err := h.Backend.Get(ctx, failed.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if requeued.Status != models.MessageStatusPending || requeued.RetryCount != 0 {
+		t.Fatalf("requeued message has status %q and retry count %d", requeued.Status, requeued.RetryCount)
+	}
+
+	current, err := h.Backend.Get(ctx, inFlight.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if current.Status != models.MessageStatusProcessing {
+		t.Fatalf("Bulk requeue touched an in-flight message, status %q", current.Status)
+	}
+}
+
+func testBulkReprioritize(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	low := newMessage(queueID, "low", 1)
+	low.Headers = map[string]string{"tenant": "acme"}
+	if err := h.Backend.Enqueue(ctx, low, 0); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	high := enqueue(t, h, queueID, "high", 5)
+
+	sel, err := selector.Parse("tenant=acme")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	opts := storage.BulkOptions{
+		Filter:   storage.MessageFilter{QueueID: queueID, Selector: sel},
+		Action:   storage.BulkReprioritize,
+		Priority: 9,
+		Limit:    10,
+	}
+	for _, want := range []int64{1, 0} {
+		n, err := h.Backend.Bulk(ctx, opts)
+		if err != nil {
+			t.Fatalf("Bulk: %v", err)
+		}
+		if n != want {
+			t.Fatalf("Bulk reprioritized %d messages, want %d", n, want)
+		}
+	}
+
+	if got := claim(t, h, queueID); got.ID != low.ID {
+		t.Fatalf("claimed message %d first, want reprioritized message %d", got.ID, low.ID)
+	}
+	if got := claim(t, h, queueID); got.ID != high.ID {
+		t.Fatalf("claimed message %d second, want %d", got.ID, high.ID)
+	}
+}
+
+func testBulkArchive(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	archived := enqueue(t, h, queueID, "archived", 0)
+	claim(t, h, queueID)
+
+	opts := storage.BulkOptions{
+		Filter: storage.MessageFilter{QueueID: queueID},
+		Action: storage.BulkArchive,
+		Limit:  10,
+	}
+	for _, want := range []int64{1, 0} {
+		n, err := h.Backend.Bulk(ctx, opts)
+		if err != nil {
+			t.Fatalf("Bulk: %v", err)
+		}
+		if n != want {
+			t.Fatalf("Bulk archived %d messages, want %d", n, want)
+		}
+	}
+
+	if _, err := h.Backend.Get(ctx, archived.ID); !errors.Is(err, storage.ErrMessageNotFound) {
+		t.Fatalf("Get archived message: got %v, want ErrMessageNotFound", err)
+	}
+}
+
+func testBulkRemaining(t *testing.T, h *Harness) {
+	ctx := context.Background()
+	queueID := h.NewQueue(t)
+
+	for i := 0; i < 3; i++ {
+		enqueue(t, h, queueID, "pending", 0)
+	}
+	claim(t, h, queueID)
+
+	tests := []struct {
+		name string
+		opts storage.BulkOptions
+		want int64
+	}{
+		{"delete ignores the limit", storage.BulkOptions{Action: storage.BulkDelete, Limit: 1}, 3},
+		{"requeue skips pending and in-flight", storage.BulkOptions{Action: storage.BulkRequeue}, 0},
+		{"reprioritize skips the new priority", storage.BulkOptions{Action: storage.BulkReprioritize, Priority: 0}, 0},
+		{"reprioritize", storage.BulkOptions{Action: storage.BulkReprioritize, Priority: 5}, 3},
+	}
+
+	for _, tt := range tests {
+		tt.opts.Filter = storage.MessageFilter{QueueID: queueID}
+		n, err := h.Backend.BulkRemaining(ctx, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: BulkRemaining: %v", tt.name, err)
+		}
+		if n != tt.want {
+			t.Fatalf("%s: BulkRemaining returned %d, want %d", tt.name, n, tt.want)
+		}
+	}
+}
+
+func newMessage(queueID int64, payload string, priority int) *models.Message {
+	now := time.Now()
+	return &models.Message{
+		QueueID:    queueID,
+		Payload:    payload,
+		Priority:   priority,
+		Status:     models.MessageStatusPending,
+		MaxRetries: 3,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+func enqueue(t *testing.T, h *Harness, queueID int64, payload string, priority int) *models.Message {
+	t.Helper()
+	message := newMessage(queueID, payload, priority)
+	if err := h.Backend.Enqueue(context.Background(), message, 0); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return message
+}
+
+func claimOptions(workerID int64) storage.ClaimOptions {
+	return storage.ClaimOptions{
+		WorkerID:   workerID,
+		LeaseToken: time.Now().Format(time.RFC3339Nano),
+		Lease:      time.Minute,
+	}
+}
+
+func claim(t *testing.T, h *Harness, queueID int64) *models.Message {
+	t.Helper()
+	message, err := h.Backend.Claim(context.Background(), queueID, claimOptions(h.workerID(t)))
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	return message
+}
+
+func expectEmpty(t *testing.T, h *Harness, queueID int64) {
+	t.Helper()
+	if _, err := h.Backend.Claim(context.Background(), queueID, claimOptions(h.workerID(t))); !errors.Is(err, storage.ErrNoMessageAvailable) {
+		t.Fatalf("Claim: got %v, want ErrNoMessageAvailable", err)
+	}
+}
+
+func ids(messages []*models.Message) []int64 {
+	out := make([]int64, len(messages))
+	for i, message := range messages {
+		out[i] = message.ID
+	}
+	return out
+}
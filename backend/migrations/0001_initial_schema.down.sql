@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS workers;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS queues;
@@ -0,0 +1,50 @@
+-- Queues, messages and workers as first released
+CREATE TABLE IF NOT EXISTS queues (
+    id BIGSERIAL NOT NULL,
+    name VARCHAR NOT NULL,
+    description VARCHAR,
+    type VARCHAR NOT NULL,
+    config JSONB,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id BIGSERIAL NOT NULL,
+    queue_id BIGINT NOT NULL,
+    payload VARCHAR NOT NULL,
+    priority BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    scheduled_at TIMESTAMPTZ,
+    processed_at TIMESTAMPTZ,
+    failed_at TIMESTAMPTZ,
+    retry_count BIGINT NOT NULL DEFAULT 0,
+    max_retries BIGINT NOT NULL DEFAULT 3,
+    error_message VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS workers (
+    id BIGSERIAL NOT NULL,
+    name VARCHAR NOT NULL,
+    queue_id BIGINT NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'idle',
+    last_ping TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    processed_count BIGINT NOT NULL DEFAULT 0,
+    failed_count BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_queue_id ON messages(queue_id);
+CREATE INDEX IF NOT EXISTS idx_messages_status ON messages(status);
+CREATE INDEX IF NOT EXISTS idx_messages_scheduled_at ON messages(scheduled_at);
+CREATE INDEX IF NOT EXISTS idx_messages_priority ON messages(priority DESC);
+CREATE INDEX IF NOT EXISTS idx_workers_queue_id ON workers(queue_id);
+CREATE INDEX IF NOT EXISTS idx_workers_status ON workers(status);
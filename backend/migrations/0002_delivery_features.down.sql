@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS archived_messages;
+DROP TABLE IF EXISTS message_dedup;
+
+DROP INDEX IF EXISTS idx_queues_deleted_at;
+DROP INDEX IF EXISTS idx_messages_claim;
+
+ALTER TABLE queues DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE queues DROP COLUMN IF EXISTS delete_mode;
+ALTER TABLE queues DROP COLUMN IF EXISTS state;
+ALTER TABLE queues DROP COLUMN IF EXISTS version;
+ALTER TABLE queues DROP COLUMN IF EXISTS dead_letter_queue_id;
+
+-- Dropping a column drops the indexes on it
+ALTER TABLE messages DROP COLUMN IF EXISTS group_key;
+ALTER TABLE messages DROP COLUMN IF EXISTS dedup_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS headers;
+ALTER TABLE messages DROP COLUMN IF EXISTS error_history;
+ALTER TABLE messages DROP COLUMN IF EXISTS dead_lettered_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS original_queue_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS lease_expires_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS lease_token;
+ALTER TABLE messages DROP COLUMN IF EXISTS claimed_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS worker_id;
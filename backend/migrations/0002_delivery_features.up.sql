@@ -0,0 +1,45 @@
+-- Leases, dead letter queues, retries, headers, deduplication, message groups,
+-- queue versions and states, and soft deletion. Databases set up before versioned
+-- migrations may have some of these already.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS worker_id BIGINT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS lease_token VARCHAR;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS original_queue_id BIGINT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS error_history JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS headers JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS dedup_id VARCHAR;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS group_key VARCHAR;
+ALTER TABLE queues ADD COLUMN IF NOT EXISTS dead_letter_queue_id BIGINT;
+ALTER TABLE queues ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE queues ADD COLUMN IF NOT EXISTS state VARCHAR NOT NULL DEFAULT 'active';
+ALTER TABLE queues ADD COLUMN IF NOT EXISTS delete_mode VARCHAR;
+ALTER TABLE queues ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS message_dedup (
+    queue_id BIGINT NOT NULL,
+    dedup_id VARCHAR NOT NULL,
+    message_id BIGINT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (queue_id, dedup_id)
+);
+
+CREATE TABLE IF NOT EXISTS archived_messages (
+    id BIGINT NOT NULL,
+    queue_id BIGINT NOT NULL,
+    queue_name VARCHAR NOT NULL,
+    message JSONB NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_claim ON messages(queue_id, priority DESC, created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_messages_lease_expires_at ON messages(lease_expires_at) WHERE status = 'processing';
+CREATE INDEX IF NOT EXISTS idx_messages_original_queue_id ON messages(original_queue_id) WHERE status = 'dead_lettered';
+CREATE INDEX IF NOT EXISTS idx_messages_headers ON messages USING GIN (headers jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_messages_group ON messages(queue_id, group_key, id) WHERE group_key IS NOT NULL AND status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_messages_worker_id ON messages(worker_id) WHERE worker_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_message_dedup_expires_at ON message_dedup(expires_at);
+CREATE INDEX IF NOT EXISTS idx_queues_deleted_at ON queues(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_archived_messages_queue_id ON archived_messages(queue_id);
@@ -0,0 +1,7 @@
+ALTER TABLE message_dedup DROP CONSTRAINT IF EXISTS fk_message_dedup_message_id;
+ALTER TABLE message_dedup DROP CONSTRAINT IF EXISTS fk_message_dedup_queue_id;
+ALTER TABLE queues DROP CONSTRAINT IF EXISTS fk_queues_dead_letter_queue_id;
+ALTER TABLE workers DROP CONSTRAINT IF EXISTS fk_workers_queue_id;
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_messages_worker_id;
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_messages_original_queue_id;
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_messages_queue_id;
@@ -0,0 +1,41 @@
+-- Link messages, workers and dedup keys to their queue. Rows orphaned by queue
+-- deletes from before the constraints existed are cleaned up first.
+DELETE FROM messages m WHERE NOT EXISTS (SELECT 1 FROM queues q WHERE q.id = m.queue_id);
+UPDATE messages m SET original_queue_id = NULL
+    WHERE original_queue_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM queues q WHERE q.id = m.original_queue_id);
+DELETE FROM workers w WHERE NOT EXISTS (SELECT 1 FROM queues q WHERE q.id = w.queue_id);
+UPDATE messages m SET worker_id = NULL
+    WHERE worker_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM workers w WHERE w.id = m.worker_id);
+UPDATE queues s SET dead_letter_queue_id = NULL
+    WHERE dead_letter_queue_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM queues q WHERE q.id = s.dead_letter_queue_id);
+DELETE FROM message_dedup d WHERE NOT EXISTS (SELECT 1 FROM queues q WHERE q.id = d.queue_id);
+UPDATE message_dedup d SET message_id = NULL
+    WHERE message_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.id = d.message_id);
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_messages_queue_id;
+ALTER TABLE messages ADD CONSTRAINT fk_messages_queue_id
+    FOREIGN KEY (queue_id) REFERENCES queues(id) ON DELETE CASCADE;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_messages_original_queue_id;
+ALTER TABLE messages ADD CONSTRAINT fk_messages_original_queue_id
+    FOREIGN KEY (original_queue_id) REFERENCES queues(id) ON DELETE SET NULL;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_messages_worker_id;
+ALTER TABLE messages ADD CONSTRAINT fk_messages_worker_id
+    FOREIGN KEY (worker_id) REFERENCES workers(id) ON DELETE SET NULL;
+
+ALTER TABLE workers DROP CONSTRAINT IF EXISTS fk_workers_queue_id;
+ALTER TABLE workers ADD CONSTRAINT fk_workers_queue_id
+    FOREIGN KEY (queue_id) REFERENCES queues(id) ON DELETE CASCADE;
+
+ALTER TABLE queues DROP CONSTRAINT IF EXISTS fk_queues_dead_letter_queue_id;
+ALTER TABLE queues ADD CONSTRAINT fk_queues_dead_letter_queue_id
+    FOREIGN KEY (dead_letter_queue_id) REFERENCES queues(id) ON DELETE SET NULL;
+
+ALTER TABLE message_dedup DROP CONSTRAINT IF EXISTS fk_message_dedup_queue_id;
+ALTER TABLE message_dedup ADD CONSTRAINT fk_message_dedup_queue_id
+    FOREIGN KEY (queue_id) REFERENCES queues(id) ON DELETE CASCADE;
+
+ALTER TABLE message_dedup DROP CONSTRAINT IF EXISTS fk_message_dedup_message_id;
+ALTER TABLE message_dedup ADD CONSTRAINT fk_message_dedup_message_id
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_scheduled;
+
+UPDATE messages SET status = 'pending' WHERE status = 'scheduled';
@@ -0,0 +1,5 @@
+-- Messages waiting for their scheduled_at get their own status, promoted to pending
+-- by the scheduler once due.
+UPDATE messages SET status = 'scheduled' WHERE status = 'pending' AND scheduled_at > now();
+
+CREATE INDEX IF NOT EXISTS idx_messages_scheduled ON messages(scheduled_at) WHERE status = 'scheduled';
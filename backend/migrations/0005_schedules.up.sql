@@ -0,0 +1,40 @@
+-- Cron schedules enqueueing messages, and the history of their runs
+CREATE TABLE IF NOT EXISTS schedules (
+    id BIGSERIAL NOT NULL,
+    name VARCHAR NOT NULL,
+    description VARCHAR,
+    cron_expr VARCHAR NOT NULL,
+    timezone VARCHAR NOT NULL DEFAULT 'UTC',
+    queue_id BIGINT NOT NULL,
+    payload_template VARCHAR NOT NULL,
+    headers JSONB,
+    priority BIGINT NOT NULL DEFAULT 0,
+    catch_up VARCHAR NOT NULL DEFAULT 'latest',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    UNIQUE (name),
+    CONSTRAINT fk_schedules_queue_id FOREIGN KEY (queue_id) REFERENCES queues(id) ON DELETE CASCADE
+);
+
+-- A tick runs at most once, even while leadership changes hands
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id BIGSERIAL NOT NULL,
+    schedule_id BIGINT NOT NULL,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    status VARCHAR NOT NULL,
+    message_id BIGINT,
+    error VARCHAR,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    finished_at TIMESTAMPTZ,
+    PRIMARY KEY (id),
+    UNIQUE (schedule_id, scheduled_for),
+    CONSTRAINT fk_schedule_runs_schedule_id FOREIGN KEY (schedule_id) REFERENCES schedules(id) ON DELETE CASCADE,
+    CONSTRAINT fk_schedule_runs_message_id FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_next_run_at ON schedules(next_run_at) WHERE enabled;
+CREATE INDEX IF NOT EXISTS idx_schedules_queue_id ON schedules(queue_id);
@@ -0,0 +1,2 @@
+-- Messages a worker reported in hand at its last heartbeat
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS current_message_ids JSONB;
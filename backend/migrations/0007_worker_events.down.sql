@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_workers_last_ping;
+DROP TABLE IF EXISTS worker_events;
@@ -0,0 +1,14 @@
+-- Lifecycle events of workers: registration, deregistration and lapsed heartbeats
+CREATE TABLE IF NOT EXISTS worker_events (
+    id BIGSERIAL NOT NULL,
+    worker_id BIGINT NOT NULL,
+    type VARCHAR NOT NULL,
+    detail VARCHAR,
+    messages BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_worker_events_worker_id FOREIGN KEY (worker_id) REFERENCES workers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_events_worker_id ON worker_events(worker_id);
+CREATE INDEX IF NOT EXISTS idx_workers_last_ping ON workers(last_ping) WHERE status != 'stopped';
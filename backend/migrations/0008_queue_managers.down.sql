@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_queues_managed_by;
+ALTER TABLE queues DROP COLUMN IF EXISTS managed_by;
@@ -0,0 +1,4 @@
+-- Owner of a queue declared with apply; apply never changes queues it does not manage
+ALTER TABLE queues ADD COLUMN IF NOT EXISTS managed_by VARCHAR;
+
+CREATE INDEX IF NOT EXISTS idx_queues_managed_by ON queues(managed_by) WHERE managed_by IS NOT NULL;
@@ -0,0 +1,2 @@
+-- The null entries removed carried no information, so there is nothing to restore
+SELECT 1;
@@ -0,0 +1,11 @@
+-- Messages inserted without error history held a JSON null, which the first failure
+-- kept as a null entry ahead of the real one. Drop those entries.
+UPDATE messages SET error_history = NULL WHERE jsonb_typeof(error_history) = 'null';
+
+UPDATE messages
+SET error_history = (
+    SELECT COALESCE(jsonb_agg(entry ORDER BY position), '[]'::jsonb)
+    FROM jsonb_array_elements(error_history) WITH ORDINALITY AS history(entry, position)
+    WHERE jsonb_typeof(entry) <> 'null'
+)
+WHERE jsonb_typeof(error_history) = 'array' AND error_history @> '[null]'::jsonb;
@@ -0,0 +1,11 @@
+// Package migrations holds the versioned SQL migrations of the Qafka schema.
+//
+// Each version has an up and a down script named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Versions are applied in order and recorded in the
+// schema_migrations table; never edit a migration once it has been released.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,154 @@
+// Package client is a Go client for the Qafka HTTP API. Client exposes typed
+// methods for queues, messages and workers; Consumer builds on them to process
+// the messages of a queue with a handler function.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the API of a Qafka server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient makes the client send its requests with hc. Leave hc's Timeout
+// unset or above the longest poll wait, or long polls fail before the server answers.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithHeader adds a header to every request, e.g. the credentials expected by a
+// proxy in front of the server
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// New returns a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/") + "/api/v1",
+		httpClient: &http.Client{},
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the server answers a request with an error status
+type APIError struct {
+	StatusCode int
+	Message    string       `json:"error"`
+	Errors     []FieldError `json:"errors"` // set when the request failed validation
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s (HTTP %d)", e.Message, e.StatusCode)
+	}
+
+	fields := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		if fe.Field == "" {
+			fields[i] = fe.Message
+		} else {
+			fields[i] = fe.Field + ": " + fe.Message
+		}
+	}
+	return fmt.Sprintf("%s (HTTP %d): %s", e.Message, e.StatusCode, strings.Join(fields, "; "))
+}
+
+// IsNotFound reports whether err is an APIError for a missing resource
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is an APIError for a request conflicting with
+// the resource's state, such as a lease no longer held or a stale queue version
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// do sends a request to path, encoding body as JSON unless it is nil, and
+// decodes the response into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
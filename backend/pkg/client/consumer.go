@@ -0,0 +1,307 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handler processes one message. Returning nil acks the message; returning an
+// error nacks it with the error's text, so the queue's retry policy decides whether
+// and when it is delivered again. Wrap the error with RetryAfter to choose the delay.
+//
+// ctx is cancelled when the consumer loses the message's lease, since another
+// worker may then be processing it. It is not cancelled on shutdown: Run waits for
+// running handlers to return.
+type Handler func(ctx context.Context, msg *Message) error
+
+// RetryAfter wraps a handler error so the message is delivered again after delay
+// instead of after the retry policy's backoff
+func RetryAfter(delay time.Duration, err error) error {
+	return &retryError{err: err, delay: delay}
+}
+
+type retryError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryError) Error() string { return e.err.Error() }
+
+func (e *retryError) Unwrap() error { return e.err }
+
+// ConsumerConfig configures a Consumer. Only QueueID is required.
+type ConsumerConfig struct {
+	QueueID           int64
+	Name              string        // worker name, defaults to the hostname
+	Concurrency       int           // messages handled at once, defaults to 1
+	Selector          string        // only consume messages whose headers match
+	PollWait          time.Duration // how long a poll waits for a message, defaults to 20s
+	Lease             time.Duration // lease requested by every extension, defaults to the queue's lease duration
+	HeartbeatInterval time.Duration // defaults to 10s, keep it well below the server's worker timeout
+	Logger            *log.Logger   // defaults to the standard logger
+}
+
+// Consumer processes the messages of a queue with a Handler. It registers as a
+// worker, long-polls for messages on Concurrency loops, heartbeats, and extends
+// the lease of every message until its handler returns.
+type Consumer struct {
+	client  *Client
+	config  ConsumerConfig
+	handler Handler
+
+	workerID atomic.Int64
+
+	mu       sync.Mutex
+	inFlight map[int64]struct{} // messages being handled, reported by heartbeats
+}
+
+// NewConsumer returns a consumer calling handler for the messages of config.QueueID
+func NewConsumer(client *Client, config ConsumerConfig, handler Handler) *Consumer {
+	if config.Name == "" {
+		config.Name, _ = os.Hostname()
+		if config.Name == "" {
+			config.Name = "qafka-consumer"
+		}
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	if config.PollWait <= 0 {
+		config.PollWait = 20 * time.Second
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = 10 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = log.Default()
+	}
+
+	return &Consumer{
+		client:   client,
+		config:   config,
+		handler:  handler,
+		inFlight: make(map[int64]struct{}),
+	}
+}
+
+// Run consumes messages until ctx is cancelled. It then stops polling, waits for
+// running handlers to return and settles their messages, and deregisters the worker.
+// It only returns an error when the worker cannot register.
+func (c *Consumer) Run(ctx context.Context) error {
+	if err := c.register(ctx); err != nil {
+		return err
+	}
+
+	// In-flight messages are finished on shutdown, so their handlers, leases and
+	// heartbeats live on a context that outlives ctx
+	workCtx, stopWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopWork()
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		c.heartbeat(workCtx)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.poll(ctx, workCtx)
+		}()
+	}
+	wg.Wait()
+
+	stopWork()
+	<-heartbeatDone
+
+	// Messages claimed by a poll cut short by the shutdown go back to the queue
+	deregisterCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := c.client.DeregisterWorker(deregisterCtx, c.workerID.Load()); err != nil {
+		c.config.Logger.Printf("Consumer: failed to deregister worker %d: %v", c.workerID.Load(), err)
+	}
+
+	return nil
+}
+
+// register registers the consumer as a new worker of its queue
+func (c *Consumer) register(ctx context.Context) error {
+	worker, err := c.client.RegisterWorker(ctx, c.config.Name, c.config.QueueID)
+	if err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+	c.workerID.Store(worker.ID)
+	return nil
+}
+
+// poll claims and handles messages one at a time until ctx is cancelled
+func (c *Consumer) poll(ctx, workCtx context.Context) {
+	for ctx.Err() == nil {
+		messages, err := c.client.Poll(ctx, c.config.QueueID, &PollRequest{
+			WorkerID: c.workerID.Load(),
+			Max:      1,
+			Wait:     c.config.PollWait,
+			Selector: c.config.Selector,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.config.Logger.Printf("Consumer: failed to poll queue %d: %v", c.config.QueueID, err)
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		for _, msg := range messages {
+			c.handle(workCtx, msg)
+		}
+	}
+}
+
+// handle runs the handler on msg while keeping its lease alive, then acks or
+// nacks the message by the handler's result
+func (c *Consumer) handle(ctx context.Context, msg *Message) {
+	c.track(msg.ID, true)
+	defer c.track(msg.ID, false)
+
+	handlerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	leaseDone := make(chan struct{})
+	go func() {
+		defer close(leaseDone)
+		c.keepLease(handlerCtx, cancel, msg)
+	}()
+
+	err := c.run(handlerCtx, msg)
+	cancel()
+	<-leaseDone
+
+	if err == nil {
+		if _, err := c.client.Ack(ctx, msg.ID, msg.LeaseToken); err != nil {
+			c.config.Logger.Printf("Consumer: failed to ack message %d: %v", msg.ID, err)
+		}
+		return
+	}
+
+	var requeueDelay *time.Duration
+	var retryErr *retryError
+	if errors.As(err, &retryErr) {
+		requeueDelay = &retryErr.delay
+	}
+	if _, nackErr := c.client.Nack(ctx, msg.ID, msg.LeaseToken, err.Error(), requeueDelay); nackErr != nil {
+		c.config.Logger.Printf("Consumer: failed to nack message %d: %v", msg.ID, nackErr)
+	}
+}
+
+// run calls the handler, turning a panic into an error so the message is nacked
+func (c *Consumer) run(ctx context.Context, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return c.handler(ctx, msg)
+}
+
+// keepLease extends the lease of msg at half its duration until ctx is done. It
+// calls lost when the lease can no longer be extended because it is not held.
+func (c *Consumer) keepLease(ctx context.Context, lost context.CancelFunc, msg *Message) {
+	lease := c.config.Lease
+	if lease <= 0 && msg.ClaimedAt != nil && msg.LeaseExpiresAt != nil {
+		lease = msg.LeaseExpiresAt.Sub(*msg.ClaimedAt)
+	}
+	if lease < 2*time.Second {
+		lease = 2 * time.Second
+	}
+
+	for {
+		if !sleep(ctx, lease/2) {
+			return
+		}
+
+		_, err := c.client.ExtendLease(ctx, msg.ID, msg.LeaseToken, c.config.Lease)
+		if ctx.Err() != nil {
+			return
+		}
+		if IsConflict(err) || IsNotFound(err) {
+			c.config.Logger.Printf("Consumer: lost lease of message %d", msg.ID)
+			lost()
+			return
+		}
+		if err != nil {
+			c.config.Logger.Printf("Consumer: failed to extend lease of message %d: %v", msg.ID, err)
+		}
+	}
+}
+
+// heartbeat reports the messages in flight every HeartbeatInterval until ctx is
+// done. A worker the server has stopped, after missing heartbeats, registers again.
+func (c *Consumer) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(c.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := c.client.Heartbeat(ctx, c.workerID.Load(), "", c.messageIDs())
+			if ctx.Err() != nil {
+				return
+			}
+			if IsConflict(err) || IsNotFound(err) {
+				c.config.Logger.Printf("Consumer: worker %d was stopped, registering again", c.workerID.Load())
+				if err := c.register(ctx); err != nil {
+					c.config.Logger.Printf("Consumer: %v", err)
+				}
+				continue
+			}
+			if err != nil {
+				c.config.Logger.Printf("Consumer: failed to send heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+func (c *Consumer) track(id int64, inFlight bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if inFlight {
+		c.inFlight[id] = struct{}{}
+	} else {
+		delete(c.inFlight, id)
+	}
+}
+
+func (c *Consumer) messageIDs() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]int64, 0, len(c.inFlight))
+	for id := range c.inFlight {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// sleep waits for d, reporting false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,331 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers the worker and message endpoints a Consumer uses. Polls
+// deliver the messages sent on messages, waiting for one like the server does.
+type fakeServer struct {
+	*httptest.Server
+	messages chan *Message
+
+	mu           sync.Mutex
+	lastWorkerID int64
+	stopped      map[int64]bool // workers whose heartbeats are answered with 404
+	pollWorkers  []int64        // worker of every poll, in order
+	leaseStatus  int            // status of lease extensions, 200 when zero
+	extensions   int
+	acked        []int64
+	nacked       []int64
+	deregistered []int64
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	s := &fakeServer{messages: make(chan *Message), stopped: make(map[int64]bool)}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1"), "/"), "/")
+	var id int64
+	if len(parts) > 1 {
+		id, _ = strconv.ParseInt(parts[1], 10, 64)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "workers":
+		s.mu.Lock()
+		s.lastWorkerID++
+		worker := &Worker{ID: s.lastWorkerID, Status: "idle"}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusCreated, worker)
+
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "workers" && parts[2] == "heartbeat":
+		s.mu.Lock()
+		stopped := s.stopped[id]
+		s.mu.Unlock()
+		if stopped {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Worker not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &Worker{ID: id})
+
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "workers":
+		s.mu.Lock()
+		s.deregistered = append(s.deregistered, id)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, &Worker{ID: id, Status: "stopped"})
+
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "queues" && parts[3] == "next":
+		workerID, _ := strconv.ParseInt(r.URL.Query().Get("worker_id"), 10, 64)
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		s.mu.Lock()
+		s.pollWorkers = append(s.pollWorkers, workerID)
+		s.mu.Unlock()
+
+		select {
+		case msg := <-s.messages:
+			writeJSON(w, http.StatusOK, []*Message{msg})
+		case <-time.After(wait):
+			writeJSON(w, http.StatusOK, []*Message{})
+		case <-r.Context().Done():
+		}
+
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "messages":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch parts[2] {
+		case "lease":
+			s.extensions++
+			if s.leaseStatus != 0 && s.leaseStatus != http.StatusOK {
+				writeJSON(w, s.leaseStatus, map[string]string{"error": "Lease not held"})
+				return
+			}
+		case "ack":
+			s.acked = append(s.acked, id)
+		case "nack":
+			s.nacked = append(s.nacked, id)
+		}
+		writeJSON(w, http.StatusOK, &Message{ID: id})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// deliver hands msg to the next poll, failing the test if none comes
+func (s *fakeServer) deliver(t *testing.T, msg *Message) {
+	t.Helper()
+
+	claimed := time.Now()
+	expires := claimed.Add(2 * time.Second)
+	msg.LeaseToken = "token-" + strconv.FormatInt(msg.ID, 10)
+	msg.ClaimedAt, msg.LeaseExpiresAt = &claimed, &expires
+
+	select {
+	case s.messages <- msg:
+	case <-time.After(time.Second):
+		t.Fatal("no poll was waiting for a message")
+	}
+}
+
+// eventually fails the test unless cond holds within a second
+func (s *fakeServer) eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		ok := cond()
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// startConsumer runs a consumer of queue 1 until the returned stop is called,
+// which waits for Run to return
+func startConsumer(t *testing.T, s *fakeServer, config ConsumerConfig, handler Handler) (stop func()) {
+	t.Helper()
+
+	config.QueueID = 1
+	config.Logger = log.New(io.Discard, "", 0)
+	consumer := NewConsumer(New(s.URL), config, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(ctx) }()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Run: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("Run did not return after ctx was cancelled")
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestConsumerLongPollWakesOnMessage(t *testing.T) {
+	s := newFakeServer(t)
+	handled := make(chan int64, 1)
+	startConsumer(t, s, ConsumerConfig{PollWait: 10 * time.Second}, func(ctx context.Context, msg *Message) error {
+		handled <- msg.ID
+		return nil
+	})
+
+	// The message arrives while the poll is waiting, long before its wait ends
+	time.Sleep(50 * time.Millisecond)
+	s.deliver(t, &Message{ID: 7})
+
+	select {
+	case id := <-handled:
+		if id != 7 {
+			t.Fatalf("handler got message %d, want 7", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiting poll did not hand the message to the handler")
+	}
+	s.eventually(t, "the message is acked", func() bool { return len(s.acked) == 1 && s.acked[0] == 7 })
+}
+
+func TestConsumerPollsAgainAfterEmptyWait(t *testing.T) {
+	s := newFakeServer(t)
+	startConsumer(t, s, ConsumerConfig{PollWait: 10 * time.Millisecond}, func(ctx context.Context, msg *Message) error {
+		return nil
+	})
+
+	s.eventually(t, "several empty polls ran", func() bool { return len(s.pollWorkers) >= 3 })
+}
+
+func TestConsumerRegistersAgainAfterHeartbeatNotFound(t *testing.T) {
+	s := newFakeServer(t)
+	stop := startConsumer(t, s, ConsumerConfig{PollWait: 10 * time.Millisecond, HeartbeatInterval: 10 * time.Millisecond}, func(ctx context.Context, msg *Message) error {
+		return nil
+	})
+
+	s.eventually(t, "the worker polls", func() bool { return len(s.pollWorkers) > 0 })
+	s.mu.Lock()
+	s.stopped[1] = true // the server timed the worker out
+	s.mu.Unlock()
+
+	s.eventually(t, "the consumer registers again", func() bool { return s.lastWorkerID == 2 })
+	s.eventually(t, "polls use the new worker", func() bool { return s.pollWorkers[len(s.pollWorkers)-1] == 2 })
+
+	stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.deregistered) != 1 || s.deregistered[0] != 2 {
+		t.Fatalf("deregistered workers %v, want the new worker 2", s.deregistered)
+	}
+}
+
+func TestConsumerKeepsLease(t *testing.T) {
+	tests := []struct {
+		name        string
+		leaseStatus int
+		wantLost    bool
+	}{
+		{name: "extended", leaseStatus: http.StatusOK},
+		{name: "lost to a conflict", leaseStatus: http.StatusConflict, wantLost: true},
+		{name: "lost to a missing message", leaseStatus: http.StatusNotFound, wantLost: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer(t)
+			s.leaseStatus = tt.leaseStatus
+
+			// The handler outlives the first extension at half the 2s lease
+			result := make(chan error, 1)
+			startConsumer(t, s, ConsumerConfig{PollWait: time.Second, Lease: 2 * time.Second}, func(ctx context.Context, msg *Message) error {
+				select {
+				case <-ctx.Done():
+					result <- ctx.Err()
+					return ctx.Err()
+				case <-time.After(1500 * time.Millisecond):
+					result <- nil
+					return nil
+				}
+			})
+			s.deliver(t, &Message{ID: 3})
+
+			var err error
+			select {
+			case err = <-result:
+			case <-time.After(3 * time.Second):
+				t.Fatal("handler did not return")
+			}
+
+			if lost := errors.Is(err, context.Canceled); lost != tt.wantLost {
+				t.Fatalf("handler context cancelled: %v, want %v", lost, tt.wantLost)
+			}
+			s.eventually(t, "the message is settled", func() bool { return len(s.acked)+len(s.nacked) == 1 })
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.extensions != 1 {
+				t.Fatalf("lease extended %d times, want once", s.extensions)
+			}
+			if tt.wantLost && len(s.nacked) != 1 || !tt.wantLost && len(s.acked) != 1 {
+				t.Fatalf("acked %v and nacked %v", s.acked, s.nacked)
+			}
+		})
+	}
+}
+
+func TestConsumerShutdownFinishesInFlightMessages(t *testing.T) {
+	s := newFakeServer(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handlerErr := make(chan error, 1)
+	stop := startConsumer(t, s, ConsumerConfig{PollWait: time.Second}, func(ctx context.Context, msg *Message) error {
+		close(started)
+		<-release
+		handlerErr <- ctx.Err()
+		return nil
+	})
+	s.deliver(t, &Message{ID: 9})
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+
+	// Run waits for the handler instead of cutting it short
+	select {
+	case <-stopped:
+		t.Fatal("Run returned while a handler was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	<-stopped
+
+	if err := <-handlerErr; err != nil {
+		t.Fatalf("handler context was cancelled by the shutdown: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.acked) != 1 || s.acked[0] != 9 {
+		t.Fatalf("acked %v after shutdown, want message 9", s.acked)
+	}
+	if len(s.deregistered) != 1 || s.deregistered[0] != 1 {
+		t.Fatalf("deregistered %v, want worker 1", s.deregistered)
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrNoMessageAvailable is returned by Claim when the queue has no message eligible for delivery
+var ErrNoMessageAvailable = errors.New("no message available")
+
+// noMessageAvailable is the message of the server's 404 answer to a claim of an empty queue
+const noMessageAvailable = "No message available"
+
+// Produce adds a message to the queue req.QueueID. A retried produce with the same
+// DedupID within the queue's window returns the original message.
+func (c *Client) Produce(ctx context.Context, req *CreateMessageRequest) (*Message, error) {
+	var message Message
+	if err := c.do(ctx, http.MethodPost, "/messages", nil, nil, req, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ProduceBatch adds several messages to a queue in one request. Items fail
+// independently; check the Error of every result.
+func (c *Client) ProduceBatch(ctx context.Context, queueID int64, reqs []CreateMessageRequest) ([]BatchMessageResult, error) {
+	body := struct {
+		Messages []CreateMessageRequest `json:"messages"`
+	}{reqs}
+
+	var resp struct {
+		Results []BatchMessageResult `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodPost, queuePath(queueID, "/messages:batch"), nil, nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// ListMessages returns up to limit messages of a queue matching selector. A zero
+// queueID lists the messages of every queue and a zero limit uses the server's default.
+func (c *Client) ListMessages(ctx context.Context, queueID int64, limit int, selector string) ([]*Message, error) {
+	query := url.Values{}
+	if queueID != 0 {
+		query.Set("queue_id", strconv.FormatInt(queueID, 10))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if selector != "" {
+		query.Set("selector", selector)
+	}
+
+	var messages []*Message
+	if err := c.do(ctx, http.MethodGet, "/messages", query, nil, nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Claim claims the next eligible message of a queue, returning ErrNoMessageAvailable
+// when there is none
+func (c *Client) Claim(ctx context.Context, queueID int64, req *ClaimRequest) (*Message, error) {
+	var message Message
+	err := c.do(ctx, http.MethodPost, queuePath(queueID, "/claim"), nil, nil, req, &message)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && apiErr.Message == noMessageAvailable {
+		return nil, ErrNoMessageAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ClaimBatch claims up to req.Max eligible messages of a queue at once. It returns
+// an empty slice when none is eligible.
+func (c *Client) ClaimBatch(ctx context.Context, queueID int64, req *ClaimRequest) ([]*Message, error) {
+	var messages []*Message
+	if err := c.do(ctx, http.MethodPost, queuePath(queueID, "/claim:batch"), nil, nil, req, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Poll claims up to req.Max messages of a queue, waiting up to req.Wait for one to
+// become eligible. It returns an empty slice when the wait ends without a message.
+func (c *Client) Poll(ctx context.Context, queueID int64, req *PollRequest) ([]*Message, error) {
+	query := url.Values{"worker_id": {strconv.FormatInt(req.WorkerID, 10)}}
+	if req.Max != 0 {
+		query.Set("max", strconv.Itoa(req.Max))
+	}
+	if req.Wait > 0 {
+		query.Set("wait", req.Wait.String())
+	}
+	if req.Selector != "" {
+		query.Set("selector", req.Selector)
+	}
+
+	var messages []*Message
+	if err := c.do(ctx, http.MethodGet, queuePath(queueID, "/messages/next"), query, nil, nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ExtendLease pushes back the lease expiry of a claimed message to lease from now.
+// A zero lease uses the queue's lease duration.
+func (c *Client) ExtendLease(ctx context.Context, id int64, leaseToken string, lease time.Duration) (*Message, error) {
+	body := struct {
+		LeaseToken   string `json:"lease_token"`
+		LeaseSeconds int    `json:"lease_seconds,omitempty"`
+	}{leaseToken, int(lease / time.Second)}
+	return c.settle(ctx, id, "/lease", body)
+}
+
+// Ack marks a claimed message as completed
+func (c *Client) Ack(ctx context.Context, id int64, leaseToken string) (*Message, error) {
+	return c.settle(ctx, id, "/ack", leaseBody{leaseToken})
+}
+
+// Nack reports that processing a claimed message failed with reason. The queue's
+// retry policy decides when the message is delivered again, unless requeueDelay is
+// not nil, and whether it is dead-lettered.
+func (c *Client) Nack(ctx context.Context, id int64, leaseToken, reason string, requeueDelay *time.Duration) (*Message, error) {
+	body := struct {
+		LeaseToken          string `json:"lease_token"`
+		Error               string `json:"error"`
+		RequeueDelaySeconds *int   `json:"requeue_delay_seconds,omitempty"`
+	}{LeaseToken: leaseToken, Error: reason}
+	if requeueDelay != nil {
+		seconds := int(*requeueDelay / time.Second)
+		body.RequeueDelaySeconds = &seconds
+	}
+	return c.settle(ctx, id, "/nack", body)
+}
+
+// Release hands a claimed message back to its queue without counting a retry
+func (c *Client) Release(ctx context.Context, id int64, leaseToken string) (*Message, error) {
+	return c.settle(ctx, id, "/release", leaseBody{leaseToken})
+}
+
+type leaseBody struct {
+	LeaseToken string `json:"lease_token"`
+}
+
+// settle posts a lease-holding request to a message endpoint
+func (c *Client) settle(ctx context.Context, id int64, action string, body any) (*Message, error) {
+	var message Message
+	path := "/messages/" + strconv.FormatInt(id, 10) + action
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, body, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListQueues returns every queue that is not deleted
+func (c *Client) ListQueues(ctx context.Context) ([]*Queue, error) {
+	var queues []*Queue
+	if err := c.do(ctx, http.MethodGet, "/queues", nil, nil, nil, &queues); err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// ListDeletedQueues returns the deleted queues that can still be restored
+func (c *Client) ListDeletedQueues(ctx context.Context) ([]*Queue, error) {
+	var queues []*Queue
+	query := url.Values{"deleted": {"true"}}
+	if err := c.do(ctx, http.MethodGet, "/queues", query, nil, nil, &queues); err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// GetQueue returns a queue by ID
+func (c *Client) GetQueue(ctx context.Context, id int64) (*Queue, error) {
+	var queue Queue
+	if err := c.do(ctx, http.MethodGet, queuePath(id, ""), nil, nil, nil, &queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+// CreateQueue creates a queue
+func (c *Client) CreateQueue(ctx context.Context, req *CreateQueueRequest) (*Queue, error) {
+	return c.createQueue(ctx, req, nil)
+}
+
+// ValidateQueue checks a create request the way CreateQueue would, returning the
+// queue that would be created without creating it
+func (c *Client) ValidateQueue(ctx context.Context, req *CreateQueueRequest) (*Queue, error) {
+	return c.createQueue(ctx, req, url.Values{"dry_run": {"true"}})
+}
+
+func (c *Client) createQueue(ctx context.Context, req *CreateQueueRequest, query url.Values) (*Queue, error) {
+	var queue Queue
+	if err := c.do(ctx, http.MethodPost, "/queues", query, nil, req, &queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+// UpdateQueue changes the fields set in req. With a non-zero version the update is
+// rejected with a conflict if the queue changed since the caller read that version.
+func (c *Client) UpdateQueue(ctx context.Context, id int64, req *UpdateQueueRequest, version int64) (*Queue, error) {
+	var header http.Header
+	if version != 0 {
+		header = http.Header{"If-Match": {strconv.Quote(strconv.FormatInt(version, 10))}}
+	}
+
+	var queue Queue
+	if err := c.do(ctx, http.MethodPatch, queuePath(id, ""), nil, header, req, &queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+// DeleteQueue deletes a queue in the given mode: refuse, cascade or archive.
+// An empty mode uses the server's default, refuse.
+func (c *Client) DeleteQueue(ctx context.Context, id int64, mode string) (*Queue, error) {
+	var query url.Values
+	if mode != "" {
+		query = url.Values{"mode": {mode}}
+	}
+
+	var queue Queue
+	if err := c.do(ctx, http.MethodDelete, queuePath(id, ""), query, nil, nil, &queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+// RestoreQueue undeletes a queue still within its grace period
+func (c *Client) RestoreQueue(ctx context.Context, id int64) (*Queue, error) {
+	return c.queueAction(ctx, id, "/restore")
+}
+
+// PauseQueue stops a queue handing out claims
+func (c *Client) PauseQueue(ctx context.Context, id int64) (*Queue, error) {
+	return c.queueAction(ctx, id, "/pause")
+}
+
+// ResumeQueue returns a paused or draining queue to the active state
+func (c *Client) ResumeQueue(ctx context.Context, id int64) (*Queue, error) {
+	return c.queueAction(ctx, id, "/resume")
+}
+
+// DrainQueue makes a queue reject produces while handing out its remaining messages
+func (c *Client) DrainQueue(ctx context.Context, id int64) (*Queue, error) {
+	return c.queueAction(ctx, id, "/drain")
+}
+
+func (c *Client) queueAction(ctx context.Context, id int64, action string) (*Queue, error) {
+	var queue Queue
+	if err := c.do(ctx, http.MethodPost, queuePath(id, action), nil, nil, emptyBody, &queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+// PurgeQueue starts deleting the messages of a queue selected by req. The purge
+// runs in the background; follow it with GetOperation.
+func (c *Client) PurgeQueue(ctx context.Context, id int64, req *PurgeQueueRequest) (*BulkOperation, error) {
+	return c.bulkOperation(ctx, queuePath(id, "/purge"), req)
+}
+
+// DeleteMessages starts deleting the messages of a queue matching filter
+func (c *Client) DeleteMessages(ctx context.Context, queueID int64, filter *MessageFilter) (*BulkOperation, error) {
+	return c.bulkOperation(ctx, queuePath(queueID, "/messages:delete"), filter)
+}
+
+// RequeueMessages starts making the messages of a queue matching filter pending again
+func (c *Client) RequeueMessages(ctx context.Context, queueID int64, filter *MessageFilter) (*BulkOperation, error) {
+	return c.bulkOperation(ctx, queuePath(queueID, "/messages:requeue"), filter)
+}
+
+// ReprioritizeMessages starts setting the priority of the messages of a queue matching filter
+func (c *Client) ReprioritizeMessages(ctx context.Context, queueID int64, filter *MessageFilter, priority int) (*BulkOperation, error) {
+	req := struct {
+		*MessageFilter
+		Priority int `json:"priority"`
+	}{filter, priority}
+	if req.MessageFilter == nil {
+		req.MessageFilter = &MessageFilter{}
+	}
+	return c.bulkOperation(ctx, queuePath(queueID, "/messages:reprioritize"), req)
+}
+
+func (c *Client) bulkOperation(ctx context.Context, path string, req any) (*BulkOperation, error) {
+	var op BulkOperation
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, req, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// GetOperation reports the progress of a bulk operation. Operations are only known
+// to the server instance that started them.
+func (c *Client) GetOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	var op BulkOperation
+	if err := c.do(ctx, http.MethodGet, "/operations/"+url.PathEscape(id), nil, nil, nil, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// CancelOperation stops a running bulk operation after its current chunk
+func (c *Client) CancelOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	var op BulkOperation
+	if err := c.do(ctx, http.MethodPost, "/operations/"+url.PathEscape(id)+"/cancel", nil, nil, emptyBody, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// Redrive moves dead-lettered messages of the source queue id back to it, returning
+// the number of messages moved
+func (c *Client) Redrive(ctx context.Context, id int64, req *RedriveRequest) (int64, error) {
+	var resp struct {
+		Redriven int64 `json:"redriven"`
+	}
+	if err := c.do(ctx, http.MethodPost, queuePath(id, "/redrive"), nil, nil, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Redriven, nil
+}
+
+// ApplyQueues brings the queues declared in req in line with the server's queues,
+// creating, updating and, with req.Prune, deleting queues of req.Manager. With
+// dryRun set it only returns the plan of changes.
+func (c *Client) ApplyQueues(ctx context.Context, req *ApplyQueuesRequest, dryRun bool) (*ApplyPlan, error) {
+	var query url.Values
+	if dryRun {
+		query = url.Values{"dry_run": {"true"}}
+	}
+
+	var plan ApplyPlan
+	if err := c.do(ctx, http.MethodPost, "/queues:apply", query, nil, req, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func queuePath(id int64, suffix string) string {
+	return "/queues/" + strconv.FormatInt(id, 10) + suffix
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Queue is a message queue
+type Queue struct {
+	ID                int64      `json:"id"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description"`
+	Type              string     `json:"type"`   // fifo, lifo, priority or delay
+	Config            string     `json:"config"` // JSON configuration
+	IsActive          bool       `json:"is_active"`
+	State             string     `json:"state"` // active, paused or draining
+	DeadLetterQueueID *int64     `json:"dead_letter_queue_id,omitempty"`
+	Version           int64      `json:"version"` // pass to UpdateQueue to reject concurrent changes
+	DeleteMode        string     `json:"delete_mode,omitempty"`
+	ManagedBy         string     `json:"managed_by,omitempty"` // manager that declared the queue with ApplyQueues
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Queue states
+const (
+	QueueStateActive   = "active"
+	QueueStatePaused   = "paused"
+	QueueStateDraining = "draining"
+)
+
+// Queue delete modes
+const (
+	QueueDeleteRefuse  = "refuse"
+	QueueDeleteCascade = "cascade"
+	QueueDeleteArchive = "archive"
+)
+
+// Message is a message in a queue
+type Message struct {
+	ID              int64             `json:"id"`
+	QueueID         int64             `json:"queue_id"`
+	Payload         string            `json:"payload"`
+	Priority        int               `json:"priority"`
+	Status          string            `json:"status"`
+	ScheduledAt     *time.Time        `json:"scheduled_at,omitempty"`
+	ProcessedAt     *time.Time        `json:"processed_at,omitempty"`
+	FailedAt        *time.Time        `json:"failed_at,omitempty"`
+	RetryCount      int               `json:"retry_count"`
+	MaxRetries      int               `json:"max_retries"`
+	ErrorMessage    string            `json:"error_message,omitempty"`
+	WorkerID        *int64            `json:"worker_id,omitempty"`
+	ClaimedAt       *time.Time        `json:"claimed_at,omitempty"`
+	LeaseToken      string            `json:"lease_token,omitempty"` // proves ownership of the claim, only set on claimed messages
+	LeaseExpiresAt  *time.Time        `json:"lease_expires_at,omitempty"`
+	OriginalQueueID *int64            `json:"original_queue_id,omitempty"`
+	DeadLetteredAt  *time.Time        `json:"dead_lettered_at,omitempty"`
+	ErrorHistory    []MessageError    `json:"error_history,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	DedupID         string            `json:"dedup_id,omitempty"`
+	GroupKey        string            `json:"group_key,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// Message statuses
+const (
+	MessageStatusScheduled    = "scheduled"
+	MessageStatusPending      = "pending"
+	MessageStatusProcessing   = "processing"
+	MessageStatusCompleted    = "completed"
+	MessageStatusFailed       = "failed"
+	MessageStatusDeadLettered = "dead_lettered"
+)
+
+// MessageError records one failed delivery attempt of a message
+type MessageError struct {
+	Attempt  int       `json:"attempt"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Worker is a registered consumer of a queue
+type Worker struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	QueueID           int64     `json:"queue_id"`
+	Status            string    `json:"status"` // idle, busy or stopped
+	LastPing          time.Time `json:"last_ping"`
+	CurrentMessageIDs []int64   `json:"current_message_ids,omitempty"`
+	ProcessedCount    int64     `json:"processed_count"`
+	FailedCount       int64     `json:"failed_count"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Worker statuses
+const (
+	WorkerStatusIdle    = "idle"
+	WorkerStatusBusy    = "busy"
+	WorkerStatusStopped = "stopped"
+)
+
+// WorkerEvent records a change in the lifecycle of a worker
+type WorkerEvent struct {
+	ID        int64     `json:"id"`
+	WorkerID  int64     `json:"worker_id"`
+	Type      string    `json:"type"` // registered, deregistered or lapsed
+	Detail    string    `json:"detail,omitempty"`
+	Messages  int64     `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BulkOperation reports the progress of a purge or bulk message operation
+type BulkOperation struct {
+	ID         string     `json:"id"`
+	QueueID    int64      `json:"queue_id"`
+	Action     string     `json:"action"`
+	Status     string     `json:"status"` // running, completed, failed, cancelled or partial
+	Processed  int64      `json:"processed"`
+	Chunks     int        `json:"chunks"`
+	Remaining  int64      `json:"remaining,omitempty"` // messages left locked by other transactions, when partial
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Bulk operation statuses
+const (
+	BulkOperationRunning   = "running"
+	BulkOperationCompleted = "completed"
+	BulkOperationFailed    = "failed"
+	BulkOperationCancelled = "cancelled"
+	BulkOperationPartial   = "partial"
+)
+
+// FieldError describes one invalid field of a rejected request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// CreateQueueRequest is the request to create a queue
+type CreateQueueRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Config      string `json:"config"`
+}
+
+// UpdateQueueRequest is a partial update of a queue. Nil fields are left unchanged.
+type UpdateQueueRequest struct {
+	Description *string `json:"description,omitempty"`
+	Config      *string `json:"config,omitempty"`
+	IsActive    *bool   `json:"is_active,omitempty"`
+}
+
+// QueueSpec declares the desired state of a queue for ApplyQueues. The YAML tags
+// let declarations be kept in files.
+type QueueSpec struct {
+	Name            string         `json:"name" yaml:"name"`
+	Description     string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Type            string         `json:"type" yaml:"type"`
+	Config          map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+	DeadLetterQueue string         `json:"dead_letter_queue,omitempty" yaml:"dead_letter_queue,omitempty"` // shorthand for config.dead_letter_queue
+	RetryPolicy     map[string]any `json:"retry_policy,omitempty" yaml:"retry_policy,omitempty"`           // shorthand for config.retry_policy
+}
+
+// ApplyQueuesRequest declares every queue owned by a manager
+type ApplyQueuesRequest struct {
+	Manager    string      `json:"manager,omitempty"` // defaults to "default"
+	Queues     []QueueSpec `json:"queues"`
+	Prune      bool        `json:"prune,omitempty"`       // delete queues of the manager that are not declared
+	Adopt      bool        `json:"adopt,omitempty"`       // take over existing unmanaged queues that are declared
+	DeleteMode string      `json:"delete_mode,omitempty"` // how pruned queues are deleted, defaults to refuse
+}
+
+// ApplyPlan lists the changes ApplyQueues made, or would make on a dry run
+type ApplyPlan struct {
+	Manager string        `json:"manager"`
+	DryRun  bool          `json:"dry_run"`
+	Changes []ApplyChange `json:"changes"`
+}
+
+// ApplyChange is what ApplyQueues does to one queue
+type ApplyChange struct {
+	Action  string        `json:"action"` // create, update, delete or unchanged
+	Name    string        `json:"name"`
+	QueueID *int64        `json:"queue_id,omitempty"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a field a change sets, with its current and desired values
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Apply actions
+const (
+	ApplyCreate    = "create"
+	ApplyUpdate    = "update"
+	ApplyDelete    = "delete"
+	ApplyUnchanged = "unchanged"
+)
+
+// CreateMessageRequest is the request to produce a message
+type CreateMessageRequest struct {
+	QueueID      int64             `json:"queue_id"`
+	Payload      string            `json:"payload"`
+	Priority     int               `json:"priority,omitempty"`
+	ScheduledAt  *time.Time        `json:"scheduled_at,omitempty"`
+	DelaySeconds *int              `json:"delay_seconds,omitempty"`
+	MaxRetries   *int              `json:"max_retries,omitempty"` // nil uses the queue's retry policy
+	Headers      map[string]string `json:"headers,omitempty"`
+	DedupID      string            `json:"dedup_id,omitempty"`
+	GroupKey     string            `json:"group_key,omitempty"`
+}
+
+// BatchMessageResult reports the outcome of one item of a batch produce
+type BatchMessageResult struct {
+	Index   int      `json:"index"`
+	Message *Message `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ClaimRequest is the request to claim messages of a queue
+type ClaimRequest struct {
+	WorkerID int64  `json:"worker_id"`
+	Max      int    `json:"max,omitempty"`      // only used by ClaimBatch, defaults to 1
+	Selector string `json:"selector,omitempty"` // e.g. "type=invoice AND region=eu"
+}
+
+// PollRequest is the request to wait for the next messages of a queue
+type PollRequest struct {
+	WorkerID int64
+	Max      int           // defaults to 1
+	Wait     time.Duration // how long the server holds the request open when no message is eligible
+	Selector string
+}
+
+// RedriveRequest selects the dead-lettered messages to move back to their source
+// queue. Without any filter every dead-lettered message of the queue is redriven.
+type RedriveRequest struct {
+	MessageIDs    []int64 `json:"message_ids,omitempty"`
+	ErrorContains string  `json:"error_contains,omitempty"`
+	MinAgeSeconds int     `json:"min_age_seconds,omitempty"`
+	MaxAgeSeconds int     `json:"max_age_seconds,omitempty"`
+}
+
+// PurgeQueueRequest selects the messages a purge deletes. Without any filter every
+// message of the queue is deleted.
+type PurgeQueueRequest struct {
+	Statuses      []string `json:"statuses,omitempty"`
+	MinAgeSeconds int      `json:"min_age_seconds,omitempty"`
+	MaxAgeSeconds int      `json:"max_age_seconds,omitempty"`
+}
+
+// MessageFilter selects the messages changed by a bulk operation. Without any
+// filter every message of the queue is selected.
+type MessageFilter struct {
+	Statuses      []string `json:"statuses,omitempty"`
+	MessageIDs    []int64  `json:"message_ids,omitempty"`
+	Selector      string   `json:"selector,omitempty"`
+	MinAgeSeconds int      `json:"min_age_seconds,omitempty"`
+	MaxAgeSeconds int      `json:"max_age_seconds,omitempty"`
+}
+
+// emptyBody is sent to endpoints that take no parameters in their body
+var emptyBody = json.RawMessage(`{}`)
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListWorkers returns the workers of a queue, or of every queue with a zero queueID
+func (c *Client) ListWorkers(ctx context.Context, queueID int64) ([]*Worker, error) {
+	var query url.Values
+	if queueID != 0 {
+		query = url.Values{"queue_id": {strconv.FormatInt(queueID, 10)}}
+	}
+
+	var workers []*Worker
+	if err := c.do(ctx, http.MethodGet, "/workers", query, nil, nil, &workers); err != nil {
+		return nil, err
+	}
+	return workers, nil
+}
+
+// GetWorker returns a worker by ID
+func (c *Client) GetWorker(ctx context.Context, id int64) (*Worker, error) {
+	var worker Worker
+	if err := c.do(ctx, http.MethodGet, workerPath(id, ""), nil, nil, nil, &worker); err != nil {
+		return nil, err
+	}
+	return &worker, nil
+}
+
+// RegisterWorker registers a worker consuming a queue. The worker must then send
+// heartbeats more often than the server's worker timeout to stay registered.
+func (c *Client) RegisterWorker(ctx context.Context, name string, queueID int64) (*Worker, error) {
+	body := struct {
+		Name    string `json:"name"`
+		QueueID int64  `json:"queue_id"`
+	}{name, queueID}
+
+	var worker Worker
+	if err := c.do(ctx, http.MethodPost, "/workers", nil, nil, body, &worker); err != nil {
+		return nil, err
+	}
+	return &worker, nil
+}
+
+// Heartbeat reports that a worker is alive and which messages it is processing. An
+// empty status is derived from messageIDs. Once the server has stopped the worker
+// the heartbeat fails with a conflict and the worker must register again.
+func (c *Client) Heartbeat(ctx context.Context, id int64, status string, messageIDs []int64) (*Worker, error) {
+	body := struct {
+		Status     string  `json:"status,omitempty"`
+		MessageIDs []int64 `json:"message_ids"`
+	}{status, messageIDs}
+
+	var worker Worker
+	if err := c.do(ctx, http.MethodPost, workerPath(id, "/heartbeat"), nil, nil, body, &worker); err != nil {
+		return nil, err
+	}
+	return &worker, nil
+}
+
+// DeregisterWorker stops a worker, handing the messages it still holds back to their queue
+func (c *Client) DeregisterWorker(ctx context.Context, id int64) (*Worker, error) {
+	var worker Worker
+	if err := c.do(ctx, http.MethodDelete, workerPath(id, ""), nil, nil, nil, &worker); err != nil {
+		return nil, err
+	}
+	return &worker, nil
+}
+
+// GetWorkerEvents returns up to limit lifecycle events of a worker, newest first. A
+// zero limit uses the server's default.
+func (c *Client) GetWorkerEvents(ctx context.Context, id int64, limit int) ([]*WorkerEvent, error) {
+	var query url.Values
+	if limit != 0 {
+		query = url.Values{"limit": {strconv.Itoa(limit)}}
+	}
+
+	var events []*WorkerEvent
+	if err := c.do(ctx, http.MethodGet, workerPath(id, "/events"), query, nil, nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func workerPath(id int64, suffix string) string {
+	return "/workers/" + strconv.FormatInt(id, 10) + suffix
+}
@@ -17,6 +17,8 @@ export interface Queue {
   type: string;
   config: string;
   is_active: boolean;
+  state: 'active' | 'paused' | 'draining';
+  managed_by?: string;
   created_at: string;
   updated_at: string;
 }
@@ -45,6 +47,7 @@ export interface Worker {
   queue?: Queue;
   status: string;
   last_ping: string;
+  current_message_ids?: number[];
   processed_count: number;
   failed_count: number;
   created_at: string;
@@ -63,6 +66,7 @@ export interface CreateMessageRequest {
   payload: string;
   priority?: number;
   scheduled_at?: string;
+  delay_seconds?: number;
   max_retries?: number;
 }
 
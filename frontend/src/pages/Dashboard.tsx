@@ -77,6 +77,7 @@ const Dashboard: React.FC = () => {
               onChange={(e) => setNewQueue({ ...newQueue, type: e.target.value })}
             >
               <option value="fifo">FIFO</option>
+              <option value="lifo">LIFO</option>
               <option value="priority">Priority</option>
               <option value="delay">Delay</option>
             </select>
@@ -101,10 +102,14 @@ const Dashboard: React.FC = () => {
                   <CardTitle className="text-lg">{queue.name}</CardTitle>
                   <CardDescription>{queue.description}</CardDescription>
                 </div>
-                <div className={`px-2 py-1 rounded-full text-xs ${
-                  queue.is_active ? 'bg-green-100 text-green-800' : 'bg-red-100 text-red-800'
+                <div className={`px-2 py-1 rounded-full text-xs capitalize ${
+                  queue.state === 'active'
+                    ? 'bg-green-100 text-green-800'
+                    : queue.state === 'draining'
+                      ? 'bg-yellow-100 text-yellow-800'
+                      : 'bg-red-100 text-red-800'
                 }`}>
-                  {queue.is_active ? 'Active' : 'Inactive'}
+                  {queue.state}
                 </div>
               </div>
             </CardHeader>
@@ -93,6 +93,7 @@ const QueueDetail: React.FC = () => {
                         message.status === 'completed' ? 'bg-green-100 text-green-800' :
                         message.status === 'failed' ? 'bg-red-100 text-red-800' :
                         message.status === 'processing' ? 'bg-blue-100 text-blue-800' :
+                        message.status === 'scheduled' ? 'bg-gray-100 text-gray-800' :
                         'bg-yellow-100 text-yellow-800'
                       }`}>
                         {message.status}